@@ -0,0 +1,45 @@
+package ai
+
+import (
+	_ "embed"
+	"encoding/json"
+	"log"
+	"strings"
+)
+
+// airlinesJSON maps canonical airline names to their known variants and IATA codes.
+// Add regional carriers here; no code changes are needed.
+//
+//go:embed airlines.json
+var airlinesJSON []byte
+
+// airlineAliases maps a lowercased variant (or canonical name) to the canonical airline name
+var airlineAliases = loadAirlineAliases(airlinesJSON)
+
+// loadAirlineAliases builds the variant lookup table from the embedded JSON
+func loadAirlineAliases(data []byte) map[string]string {
+	var table map[string][]string
+	if err := json.Unmarshal(data, &table); err != nil {
+		log.Printf("[EXTRACT] Failed to parse airline aliases: %v", err)
+		return map[string]string{}
+	}
+
+	aliases := make(map[string]string)
+	for canonical, variants := range table {
+		aliases[strings.ToLower(canonical)] = canonical
+		for _, v := range variants {
+			aliases[strings.ToLower(strings.TrimSpace(v))] = canonical
+		}
+	}
+	return aliases
+}
+
+// normalizeAirline maps common airline name variants and IATA codes to a canonical name,
+// e.g. "United", "UA" -> "United Airlines". Unknown names are returned trimmed but otherwise unchanged.
+func normalizeAirline(raw string) string {
+	name := strings.TrimSpace(raw)
+	if canonical, ok := airlineAliases[strings.ToLower(name)]; ok {
+		return canonical
+	}
+	return name
+}
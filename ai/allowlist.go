@@ -0,0 +1,175 @@
+package ai
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// DefaultAllowedSQL lists the keywords and functions generated queries may use by default:
+// plain filtering, sorting, grouping, and the string, array, and type-check functions the
+// system prompt suggests. JOIN, EXISTS, subqueries, and user-defined functions are left out since
+// they multiply RU cost or run arbitrary code. Change it with WithAllowedSQL; the pseudo-keyword
+// SUBQUERY allows nested SELECTs.
+var DefaultAllowedSQL = []string{
+	// Keywords
+	"SELECT", "VALUE", "DISTINCT", "TOP", "FROM", "WHERE", "AND", "OR", "NOT", "IN", "BETWEEN",
+	"LIKE", "AS", "ORDER", "BY", "ASC", "DESC", "GROUP", "OFFSET", "LIMIT", "IS", "NULL", "TRUE",
+	"FALSE", "UNDEFINED",
+	// Aggregates
+	"COUNT", "SUM", "AVG", "MIN", "MAX",
+	// String functions
+	"CONTAINS", "STARTSWITH", "ENDSWITH", "UPPER", "LOWER", "TRIM", "LTRIM", "RTRIM", "LENGTH",
+	"SUBSTRING", "LEFT", "RIGHT", "CONCAT", "INDEX_OF", "REPLACE", "TOSTRING", "STRINGTONUMBER",
+	// Array and type-check functions
+	"ARRAY_CONTAINS", "ARRAY_LENGTH", "IS_DEFINED", "IS_NULL", "IS_STRING", "IS_NUMBER",
+	// Math
+	"ABS", "ROUND", "FLOOR", "CEILING",
+}
+
+// WithAllowedSQL changes the keywords and functions generated queries may use (case-insensitive).
+// Entries prefixed with "+" or "-" add to or remove from DefaultAllowedSQL; if any entry has no
+// prefix, the plain entries replace the default list instead. A nil or empty list keeps the default.
+func WithAllowedSQL(entries []string) ChatOption {
+	return func(h *ChatHandler) {
+		if len(entries) > 0 {
+			h.allowedSQL = allowedSQLSet(entries)
+		}
+	}
+}
+
+// allowedSQLSet builds the uppercase allow-list described by WithAllowedSQL
+func allowedSQLSet(entries []string) map[string]bool {
+	base := DefaultAllowedSQL
+	for _, e := range entries {
+		if e = strings.TrimSpace(e); e != "" && e[0] != '+' && e[0] != '-' {
+			base = nil
+			break
+		}
+	}
+
+	allowed := make(map[string]bool, len(DefaultAllowedSQL))
+	for _, word := range base {
+		allowed[word] = true
+	}
+	for _, e := range entries {
+		e = strings.ToUpper(strings.TrimSpace(e))
+		switch {
+		case e == "":
+		case e[0] == '-':
+			delete(allowed, strings.TrimSpace(e[1:]))
+		case e[0] == '+':
+			allowed[strings.TrimSpace(e[1:])] = true
+		default:
+			allowed[e] = true
+		}
+	}
+	return allowed
+}
+
+// sqlWord is an identifier in a query, outside string literals and not a property name after "."
+type sqlWord struct {
+	text string
+	next byte // First non-space character after the word, 0 at the end
+}
+
+// sqlWords tokenizes just enough of query to find its keywords, function names, and aliases:
+// string literals, numbers, @parameters, operators, and property names (c.toAirport) are skipped.
+func sqlWords(query string) []sqlWord {
+	var words []sqlWord
+	afterDot := false // The previous token was "."
+	for i := 0; i < len(query); {
+		ch := query[i]
+		switch {
+		case ch == '\'' || ch == '"':
+			// Skip the literal, honoring backslash escapes
+			for i++; i < len(query) && query[i] != ch; i++ {
+				if query[i] == '\\' {
+					i++
+				}
+			}
+			i++
+			afterDot = false
+		case isWordByte(ch):
+			start := i
+			for i < len(query) && isWordByte(query[i]) {
+				i++
+			}
+			rest := strings.TrimLeftFunc(query[i:], unicode.IsSpace)
+			var next byte
+			if rest != "" {
+				next = rest[0]
+			}
+			// Numbers and property names aren't words to check
+			if !afterDot && !unicode.IsDigit(rune(ch)) {
+				words = append(words, sqlWord{text: query[start:i], next: next})
+			}
+			afterDot = false
+		case ch == '@':
+			// Parameter names aren't words either
+			for i++; i < len(query) && isWordByte(query[i]); i++ {
+			}
+			afterDot = false
+		default:
+			if !unicode.IsSpace(rune(ch)) {
+				afterDot = ch == '.'
+			}
+			i++
+		}
+	}
+	return words
+}
+
+// isWordByte reports whether b can be part of an identifier
+func isWordByte(b byte) bool {
+	return b == '_' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9'
+}
+
+// checkQueryWords verifies that every keyword and function in query is in allowed, returning
+// an ErrQueryNotAllowed error that names the first offending word so the model can rewrite the query.
+// Aliases declared after FROM, JOIN, or AS are accepted; nested SELECTs need SUBQUERY in allowed.
+func checkQueryWords(query string, allowed map[string]bool) error {
+	words := sqlWords(query)
+
+	aliases := map[string]bool{}
+	for i, w := range words {
+		if i+1 < len(words) && slices.Contains([]string{"FROM", "JOIN", "AS"}, strings.ToUpper(w.text)) {
+			aliases[strings.ToLower(words[i+1].text)] = true
+		}
+	}
+
+	selects := 0
+	for _, w := range words {
+		word := strings.ToUpper(w.text)
+		switch {
+		case w.next == '(':
+			if !allowed[word] {
+				return fmt.Errorf("%w: function %s is not allowed; rewrite the query without it (allowed: %s)", ErrQueryNotAllowed, word, allowedList(allowed))
+			}
+		case aliases[strings.ToLower(w.text)]:
+		case w.next == '.' || w.next == '[':
+			// Only aliases may be dereferenced, which also rules out udf.name(...)
+			return fmt.Errorf("%w: %s is not a collection alias; refer to fields as c.field", ErrQueryNotAllowed, w.text)
+		case !allowed[word]:
+			return fmt.Errorf("%w: %s is not allowed; rewrite the query without it (allowed: %s)", ErrQueryNotAllowed, word, allowedList(allowed))
+		}
+		if word == "SELECT" {
+			selects++
+		}
+	}
+	if selects > 1 && !allowed["SUBQUERY"] {
+		return fmt.Errorf("%w: subqueries are not allowed; use a single SELECT", ErrQueryNotAllowed)
+	}
+	return nil
+}
+
+// allowedList returns the allow-list sorted and comma-separated, for error messages
+func allowedList(allowed map[string]bool) string {
+	words := make([]string, 0, len(allowed))
+	for w := range allowed {
+		words = append(words, w)
+	}
+	slices.Sort(words)
+	return strings.Join(words, ", ")
+}
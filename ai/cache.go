@@ -0,0 +1,108 @@
+package ai
+
+import (
+	"container/list"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultQueryCacheSize is the default number of query results kept per ChatHandler
+	DefaultQueryCacheSize = 256
+	// DefaultQueryCacheTTL is how long a cached query result stays fresh
+	DefaultQueryCacheTTL = 60 * time.Second
+)
+
+// queryCache is a small LRU cache of query results keyed by (email, normalized query).
+// It lets repeated chat questions skip Cosmos DB until the entry expires or the user's data changes.
+type queryCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	order   *list.List               // Most recently used at the front
+	entries map[string]*list.Element // Cache key -> element holding a *cacheEntry
+}
+
+// cacheEntry is a cached query result
+type cacheEntry struct {
+	key     string
+	email   string
+	results []json.RawMessage
+	expires time.Time
+}
+
+// newQueryCache creates a cache holding up to size entries for ttl
+func newQueryCache(size int, ttl time.Duration) *queryCache {
+	return &queryCache{
+		size:    size,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// cacheKey builds the key for a query, ignoring differences in whitespace
+func cacheKey(email, query string) string {
+	return email + "\x00" + strings.Join(strings.Fields(query), " ")
+}
+
+// Get returns the cached results for a query if present and not expired
+func (c *queryCache) Get(email, query string) ([]json.RawMessage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[cacheKey(email, query)]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if time.Now().After(entry.expires) {
+		c.remove(elem)
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return entry.results, true
+}
+
+// Put stores the results for a query, evicting the least recently used entry when full
+func (c *queryCache) Put(email, query string, results []json.RawMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := cacheKey(email, query)
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+
+	c.entries[key] = c.order.PushFront(&cacheEntry{
+		key:     key,
+		email:   email,
+		results: results,
+		expires: time.Now().Add(c.ttl),
+	})
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+// Invalidate drops every cached query for email
+func (c *queryCache) Invalidate(email string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for elem := c.order.Front(); elem != nil; {
+		next := elem.Next()
+		if elem.Value.(*cacheEntry).email == email {
+			c.remove(elem)
+		}
+		elem = next
+	}
+}
+
+// remove deletes an element; callers must hold mu
+func (c *queryCache) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*cacheEntry).key)
+}
@@ -0,0 +1,105 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/abhirockzz/flight-log-app/cosmosdb"
+	sdk "github.com/github/copilot-sdk/go"
+)
+
+// Metrics supported by the show_chart tool, besides the flight_stats ones it shares
+const chartByYear = "byYear"
+
+// Chart is a series the UI can draw next to the answer, attached to ChatResponse when the model
+// calls show_chart. The model only picks the metric and date range; the numbers are computed here.
+type Chart struct {
+	Title  string   `json:"title"`  // e.g. "Flights per month, 2026-01-01 to 2026-12-31"
+	Metric string   `json:"metric"` // byMonth, byYear, byAirline, or topDestinations
+	Labels []string `json:"labels"` // e.g. months (YYYY-MM), airlines, or airport codes
+	Values []int    `json:"values"` // Flight count for each label
+}
+
+// createChartTool creates the show_chart tool. Each call replaces *chart, so the response carries
+// the last chart the model asked for.
+func (h *ChatHandler) createChartTool(ctx context.Context, email string, callback ProgressCallback, chart **Chart, mu *sync.Mutex) sdk.Tool {
+	return sdk.DefineTool("show_chart",
+		`Show the user a chart of their flights next to your answer, and get its numbers. Use this for questions asking for a breakdown over time or by category,
+e.g. "how many flights per month this year?", "flights per year", "which airlines do I fly?", "where do I fly most?". Trashed flights are excluded.
+Metrics:
+- byMonth: flights per departure month (YYYY-MM), oldest first
+- byYear: flights per departure year (YYYY), oldest first
+- byAirline: flights per airline, most flown first
+- topDestinations: most visited arrival airports, most visited first (use limit to change how many, default 5)
+Use after and before (YYYY-MM-DD, inclusive) to limit the departure dates, e.g. this year only.`,
+		func(params ChartParams, inv sdk.ToolInvocation) (any, error) {
+			log.Printf("[CHAT] AI requested chart: %s (%s to %s)", params.Metric, params.After, params.Before)
+			callback("stats", "show_chart:"+params.Metric)
+
+			filter := cosmosdb.FlightFilter{After: params.After, Before: params.Before}
+			if err := filter.Validate(); err != nil {
+				return nil, err
+			}
+			flights, err := h.store.ListFlightsFiltered(ctx, email, filter)
+			if err != nil {
+				log.Printf("[CHAT] Chart failed: %v", err)
+				return nil, fmt.Errorf("%w: %w", ErrQueryExecution, err)
+			}
+
+			c, err := buildChart(flights, params)
+			if err != nil {
+				return nil, err
+			}
+			mu.Lock()
+			*chart = c
+			mu.Unlock()
+			return c, nil
+		})
+}
+
+// buildChart computes the series for a show_chart call over flights already filtered by date
+func buildChart(flights []cosmosdb.BoardingPass, params ChartParams) (*Chart, error) {
+	var title string
+	var groups []statBucket
+	switch params.Metric {
+	case statByMonth, chartByYear:
+		bucket, what := cosmosdb.BucketMonth, "month"
+		if params.Metric == chartByYear {
+			bucket, what = cosmosdb.BucketYear, "year"
+		}
+		timeline, err := cosmosdb.FlightsByPeriod(flights, bucket)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range timeline.Periods {
+			groups = append(groups, statBucket{Key: p.Period, Count: p.Count})
+		}
+		title = "Flights per " + what
+	case statByAirline:
+		groups = computeFlightStats(flights, statByAirline, 0)["groups"].([]statBucket)
+		title = "Flights per airline"
+	case statTopDestinations:
+		groups = computeFlightStats(flights, statTopDestinations, params.Limit)["groups"].([]statBucket)
+		title = "Top destinations"
+	default:
+		return nil, fmt.Errorf("unknown metric %q (expected byMonth, byYear, byAirline, or topDestinations)", params.Metric)
+	}
+
+	switch {
+	case params.After != "" && params.Before != "":
+		title += fmt.Sprintf(", %s to %s", params.After, params.Before)
+	case params.After != "":
+		title += " since " + params.After
+	case params.Before != "":
+		title += " until " + params.Before
+	}
+
+	chart := &Chart{Title: title, Metric: params.Metric, Labels: []string{}, Values: []int{}}
+	for _, g := range groups {
+		chart.Labels = append(chart.Labels, g.Key)
+		chart.Values = append(chart.Values, g.Count)
+	}
+	return chart, nil
+}
@@ -3,32 +3,130 @@ package ai
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"sync"
 	"time"
 
 	"github.com/abhirockzz/flight-log-app/cosmosdb"
+	"github.com/abhirockzz/flight-log-app/redact"
+	"github.com/abhirockzz/flight-log-app/tracing"
 	sdk "github.com/github/copilot-sdk/go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
 	// ChatTimeout is the timeout for chat queries
 	ChatTimeout = 60 * time.Second
+
+	// DefaultMaxQueries is how many times query_flights may run in one chat unless set with WithMaxQueries
+	DefaultMaxQueries = 5
 )
 
 // ChatHandler manages conversational queries about flights using AI-generated Cosmos DB SQL
 type ChatHandler struct {
-	client       *sdk.Client
-	cosmosClient *cosmosdb.Client
+	newSession func(*sdk.SessionConfig) (chatSession, error) // Creates the Copilot session for each chat
+	store      cosmosdb.FlightStore
+	cache      *queryCache     // Nil when query caching is disabled
+	location   *time.Location  // Timezone that decides the current date in the system prompt
+	maxQueries int             // query_flights calls allowed per chat before the tool refuses
+	allowedSQL map[string]bool // Uppercase keywords and functions generated queries may use; see WithAllowedSQL
+	clarify    *clarifications
+	confirm    *confirmations
+}
+
+// chatSession is the part of *sdk.Session a chat uses, so tests can stand in for the Copilot CLI
+type chatSession interface {
+	On(handler sdk.SessionEventHandler) func()
+	Send(options sdk.MessageOptions) (string, error)
+	Abort() error
+	Destroy() error
+}
+
+// ChatOption configures a ChatHandler
+type ChatOption func(*ChatHandler)
+
+// WithQueryCache sets the size and TTL of the query result cache. A size or TTL of 0 disables it.
+func WithQueryCache(size int, ttl time.Duration) ChatOption {
+	return func(h *ChatHandler) {
+		if size <= 0 || ttl <= 0 {
+			h.cache = nil
+			return
+		}
+		h.cache = newQueryCache(size, ttl)
+	}
+}
+
+// WithLocation sets the timezone used for "today" in the system prompt (default: server local time)
+func WithLocation(loc *time.Location) ChatOption {
+	return func(h *ChatHandler) {
+		if loc != nil {
+			h.location = loc
+		}
+	}
+}
+
+// WithMaxQueries limits how many times query_flights may run in one chat, so a model stuck
+// retrying queries can't burn RUs and tokens until the timeout. Values below 1 are ignored.
+func WithMaxQueries(n int) ChatOption {
+	return func(h *ChatHandler) {
+		if n > 0 {
+			h.maxQueries = n
+		}
+	}
+}
+
+// NewChatHandler creates a new chat handler. Query results are cached with
+// DefaultQueryCacheSize and DefaultQueryCacheTTL unless overridden by WithQueryCache.
+func NewChatHandler(client *sdk.Client, store cosmosdb.FlightStore, opts ...ChatOption) *ChatHandler {
+	h := &ChatHandler{
+		newSession: func(config *sdk.SessionConfig) (chatSession, error) {
+			session, err := client.CreateSession(config)
+			if err != nil {
+				return nil, err
+			}
+			return session, nil
+		},
+		store:      store,
+		cache:      newQueryCache(DefaultQueryCacheSize, DefaultQueryCacheTTL),
+		location:   time.Local,
+		maxQueries: DefaultMaxQueries,
+		allowedSQL: allowedSQLSet(nil),
+		clarify:    newClarifications(),
+		confirm:    newConfirmations(),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
 }
 
-// NewChatHandler creates a new chat handler
-func NewChatHandler(client *sdk.Client, cosmosClient *cosmosdb.Client) *ChatHandler {
-	return &ChatHandler{
-		client:       client,
-		cosmosClient: cosmosClient,
+// InvalidateCache drops cached query results for a user. Call it after their flights change.
+func (h *ChatHandler) InvalidateCache(email string) {
+	if h.cache != nil {
+		h.cache.Invalidate(email)
+	}
+}
+
+// executeQuery runs a generated query, serving repeated identical queries from the cache
+func (h *ChatHandler) executeQuery(ctx context.Context, query, email string) ([]json.RawMessage, error) {
+	if h.cache != nil {
+		if results, ok := h.cache.Get(email, query); ok {
+			log.Printf("[CHAT] Query served from cache")
+			return results, nil
+		}
+	}
+
+	results, err := h.store.ExecuteRawQuery(ctx, query, email)
+	if err != nil {
+		return nil, err
 	}
+	if h.cache != nil {
+		h.cache.Put(email, query, results)
+	}
+	return results, nil
 }
 
 // ChatResponse contains the AI response and any query results
@@ -37,14 +135,64 @@ type ChatResponse struct {
 	Query       string                  `json:"query,omitempty"`
 	Flights     []cosmosdb.BoardingPass `json:"flights,omitempty"`
 	FlightCount int                     `json:"flightCount,omitempty"`
+	Error       string                  `json:"error,omitempty"`      // Cosmos error for the last attempted query, if any
+	MatchedIDs  []string                `json:"matchedIds,omitempty"` // IDs of flights returned by the last query, when it selected full documents
+	Meta        *ChatMeta               `json:"meta,omitempty"`
+	Clarify     *Clarification          `json:"clarify,omitempty"` // Set when the chat paused to ask which airport was meant
+	Chart       *Chart                  `json:"chart,omitempty"`   // Series to draw when the model called show_chart
+	Confirm     *Confirmation           `json:"confirm,omitempty"` // Set when the chat paused to ask the user to approve a change
+	Applied     *Confirmation           `json:"applied,omitempty"` // The change the user just confirmed, now made
+}
+
+// ChatMeta describes how an answer was produced, e.g. "Answered by gpt-4.1 · 1 query · 240 tokens"
+type ChatMeta struct {
+	Model        string `json:"model"`                  // Model that answered, as reported by the session
+	QueryCount   int    `json:"queryCount"`             // query_flights calls, including failed ones
+	ToolCalls    int    `json:"toolCalls"`              // All tool calls, including get_flight and the stats tools
+	InputTokens  int    `json:"inputTokens,omitempty"`  // Summed over assistant.usage events; 0 if not reported
+	OutputTokens int    `json:"outputTokens,omitempty"` // Summed over assistant.usage events; 0 if not reported
+}
+
+// QueryResultsEvent is the payload of the results event, sent as soon as a generated query
+// returns so the UI can show what was found while the model writes its summary
+type QueryResultsEvent struct {
+	RowCount int      `json:"rowCount"`
+	Preview  []string `json:"preview,omitempty"` // One line per row for the first few rows
+}
+
+// maxResultPreview is how many rows a results event previews
+const maxResultPreview = 3
+
+// maxPreviewLength truncates previews of non-flight rows (projections, aggregates)
+const maxPreviewLength = 80
+
+// previewResults summarizes the first rows of a query result, e.g. "UA 1234: SFO → JFK on 2026-01-25"
+func previewResults(results []json.RawMessage) []string {
+	preview := make([]string, 0, min(len(results), maxResultPreview))
+	for _, raw := range results[:min(len(results), maxResultPreview)] {
+		var flight cosmosdb.BoardingPass
+		if json.Unmarshal(raw, &flight) == nil && flight.FlightNumber != "" {
+			preview = append(preview, fmt.Sprintf("%s: %s → %s on %s", flight.FlightNumber, flight.FromAirport, flight.ToAirport, flight.DepartureDate))
+			continue
+		}
+		line := string(raw)
+		if len(line) > maxPreviewLength {
+			line = line[:maxPreviewLength] + "…"
+		}
+		preview = append(preview, line)
+	}
+	return preview
 }
 
-// buildQueryToolDescription returns the tool description with the user's email injected
+// buildQueryToolDescription returns the tool description with the user's email injected,
+// escaped so the example queries stay valid SQL whatever the email contains
 func buildQueryToolDescription(email string) string {
+	email = escapeLiteral(email)
 	return fmt.Sprintf(`Execute a SQL query against the flights container to answer the user's question.
 The user's email is: %s (use this in the WHERE clause)
 
 IMPORTANT: Always include c.email = '%s' in the WHERE clause for security.
+IMPORTANT: Always include NOT IS_DEFINED(c.deleted) in the WHERE clause to exclude flights the user has moved to the trash.
 
 Available fields:
 - id (string): unique flight ID
@@ -56,8 +204,17 @@ Available fields:
 - departureDate (string): YYYY-MM-DD format, e.g. "2026-01-25"
 - departureTime (string): HH:MM format, e.g. "14:30"
 - seat (string): seat number, e.g. "12A"
+- seatRow (number, optional): row parsed from seat, e.g. 12 (absent when seat isn't row+letter)
+- seatColumn (string, optional): letter parsed from seat, e.g. "A". Window seats are usually A and F (A and K on wide-body aircraft); aisle seats are usually C and D
 - gate (string): gate number, e.g. "B42"
-- passenger (string): passenger name
+- passenger (string): passenger name as printed, e.g. "JANE DOE". One account may hold a family's flights, so
+  "Jane's flights" or "flights for my son Tom" filter on passenger: CONTAINS(UPPER(c.passenger), 'JANE')
+- cabinClass (string, optional): cabin as printed, e.g. "Economy", "Business" (use IS_DEFINED for older flights)
+- boardingGroup (string, optional): boarding group or zone, e.g. "Group 3"
+- confirmationCode (string, optional): uppercase booking reference (PNR), e.g. "ABC123". For "find my booking abc123" use c.confirmationCode = 'ABC123'
+- frequentFlyerNumber (string, optional): frequent flyer number as printed, e.g. "UA 1234567"; match with CONTAINS since spacing varies
+- tags (array of strings, optional): the user's lowercase labels, e.g. ["work"], ["vacation"]. Use ARRAY_CONTAINS(c.tags, 'work') for "work trips"
+- note (string, optional): the user's free-form note about the flight, e.g. "aisle was broken"
 
 IMPORTANT: In ORDER BY clauses, you MUST repeat the full expression (e.g., COUNT(1)), NOT the alias. Cosmos DB does not support referencing aliases in ORDER BY.
 
@@ -66,40 +223,81 @@ Example queries:
 - SELECT * FROM c WHERE c.email = '%s' AND c.toAirport = 'JFK'
 - SELECT * FROM c WHERE c.email = '%s' AND c.departureDate >= '2026-02-01'
 - SELECT * FROM c WHERE c.email = '%s' AND CONTAINS(c.airline, 'Delta')
+- SELECT * FROM c WHERE c.email = '%s' AND ARRAY_CONTAINS(c.tags, 'work')
+- SELECT * FROM c WHERE c.email = '%s' AND CONTAINS(UPPER(c.passenger), 'JANE')
 - SELECT VALUE COUNT(1) FROM c WHERE c.email = '%s' (for counting)
 - SELECT c.airline, COUNT(1) as count FROM c WHERE c.email = '%s' GROUP BY c.airline ORDER BY COUNT(1) DESC
-- SELECT DISTINCT c.toAirport FROM c WHERE c.email = '%s'`, email, email, email, email, email, email, email, email, email)
+- SELECT DISTINCT c.toAirport FROM c WHERE c.email = '%s'`, email, email, email, email, email, email, email, email, email, email, email)
 }
 
-// buildSystemMessage returns the system prompt for the chat session
-func buildSystemMessage(today string) string {
+// buildSystemMessage returns the system prompt for the chat session. Dates in answers
+// follow dateLayout so the examples match the user's locale.
+func buildSystemMessage(today, dateLayout string) string {
+	example := func(date string) string {
+		t, _ := time.Parse("2006-01-02", date)
+		return t.Format(dateLayout)
+	}
 	return fmt.Sprintf(`You are a flight search assistant. When the user asks about their flights:
 
 1. Generate an appropriate Cosmos DB SQL query based on their question
 2. Use the query_flights tool to search their flight data
 3. Provide a brief, plain-text summary of the results
 
+For detail questions (e.g., "what gate is my JFK flight at?"), first identify the flight with query_flights
+(include c.id in the SELECT), then call get_flight with that id to fetch its full record.
+
+For counting and grouping questions across all flights (e.g., "how many flights have I taken?", "which airline
+do I fly most?", "how many flights per month?", "where do I fly most often?"), use flight_stats instead of
+writing aggregate SQL. For per-month or per-year questions (e.g., "which month did I fly the most?"), use
+flights_by_period. Use query_flights when the question needs filters flight_stats doesn't support
+(e.g., a date range or a specific route).
+
+When the user asks for a breakdown over time or by category (e.g., "how many flights per month this year?"),
+call show_chart so the app can draw it, then summarize its numbers briefly; do not list every value.
+
 SECURITY - REJECT DIRECT SQL QUERIES:
 - If the user provides a raw SQL query (e.g., "SELECT * FROM c", "SELECT c.flightNumber FROM c WHERE..."), do NOT execute it
 - Instead, politely explain that direct SQL queries are not supported and ask them to describe what they want in natural language
 - Example response: "I can't run SQL queries directly. Please describe what you're looking for, like 'show me my flights to New York' or 'how many flights did I take last month?'"
 
+CHANGING FLIGHTS:
+- To delete a flight or change its details (e.g., "delete my cancelled LAX flight", "change my seat on UA 1234 to 14C"),
+  first identify the one flight with query_flights (include c.id in the SELECT), then call delete_flight or update_flight with its id
+- These tools never change anything themselves: the app shows the flight to the user and asks them to confirm.
+  After calling one, stop; do not answer and never say the change was made
+- Propose at most one change per question, and only when the user asks for it. If several flights match, list them and
+  ask which one instead of calling the tool
+- The user's yes or no is handled by the app, not by you
+
+CLARIFICATIONS:
+- If a question about one flight matches flights at several airports of a city, the app pauses and asks the
+  user which airport they meant. A query_flights result with "clarifying" set means this happened: stop, do not answer
+- The user's choice arrives as a message of the form:
+  "Original question: <their question>
+   Clarification: toAirport is JFK"
+  Answer the original question, filtering on that field and airport only (e.g. c.toAirport = 'JFK')
+
 IMPORTANT RESPONSE FORMAT:
 - Do NOT use markdown tables or formatting
 - Keep responses brief and conversational
 - For flight lists, use simple numbered format like:
   "Found 2 flights:
-   1. UA 1234: SFO → JFK on Jan 25, 2026
-   2. DL 567: LAX → SEA on Jan 20, 2026"
+   1. UA 1234: SFO → JFK on %s
+   2. DL 567: LAX → SEA on %s"
 - Include key details: flight number, route, date, time
+- Write dates like %s, even though stored dates are YYYY-MM-DD
 - If no results, briefly explain what was searched and suggest alternatives
 
 Query tips:
 - For "upcoming flights": use departureDate >= current date (today is %s)
 - For "past flights" or "flights taken": use departureDate < current date (today is %s)
+- departureDate and departureTime are local to the departure airport, so a flight dated today may still be hours away
 - For city names: map to airport codes (New York = JFK/LGA/EWR, Los Angeles = LAX, Chicago = ORD, Miami = MIA, Seattle = SEA, San Francisco = SFO)
+  and match all of a city's airports with IN, e.g. c.toAirport IN ('JFK', 'LGA', 'EWR')
 - Use CONTAINS() for partial airline name matching
-- For "all flights", "total flights", "how many flights" (without time context), or general flight count questions: query ALL flights (just filter by email, no date filter)`, today, today)
+- For "total flights" or "how many flights" without time context: use flight_stats with metric count
+- For "all flights": query ALL flights (just filter by email, no date filter)`,
+		example("2026-01-25"), example("2026-01-20"), example("2026-01-25"), today, today)
 }
 
 // createQueryTool creates the query_flights tool for the AI session
@@ -108,22 +306,75 @@ func (h *ChatHandler) createQueryTool(
 	email string,
 	callback ProgressCallback,
 	generatedQuery *string,
+	queryErr *string,
+	matchedIDs *[]string,
+	queryCount *int,
 	mu *sync.Mutex,
+	clarify func(query string, results []json.RawMessage) bool,
 ) sdk.Tool {
 	return sdk.DefineTool("query_flights",
 		buildQueryToolDescription(email),
 		func(params QueryFlightsParams, inv sdk.ToolInvocation) (any, error) {
-			log.Printf("[CHAT] AI generated query: %s", params.Query)
+			log.Printf("[CHAT] AI generated query: %s", redact.Query(params.Query))
 			callback("query", params.Query)
 
 			mu.Lock()
-			*generatedQuery = params.Query
+			*queryCount++
+			overLimit := *queryCount > h.maxQueries
+			if !overLimit {
+				*generatedQuery = params.Query
+				*queryErr = ""
+				*matchedIDs = nil
+			}
 			mu.Unlock()
 
-			results, err := h.cosmosClient.ExecuteRawQuery(ctx, params.Query, email)
+			// Stop a model that keeps re-querying; it should answer with the results it already has
+			if overLimit {
+				log.Printf("[CHAT] WARNING: Query limit of %d reached | Email: %s", h.maxQueries, redact.Email(email))
+				warning, _ := json.Marshal(map[string]string{
+					"warning": fmt.Sprintf("Stopped after %d queries", h.maxQueries),
+				})
+				callback("warning", string(warning))
+				return nil, fmt.Errorf("%w (%d per question): stop querying and answer using the results you already have", ErrQueryLimit, h.maxQueries)
+			}
+
+			// Reject queries that name another partition or use unexpected SQL before they reach Cosmos DB
+			if err := h.validateQuery(params.Query, email); err != nil {
+				log.Printf("[CHAT] WARNING: Rejected query | Email: %s | Reason: %v | Query: %s", redact.Email(email), err, redact.Query(params.Query))
+				mu.Lock()
+				*queryErr = err.Error()
+				mu.Unlock()
+				callback("error", err.Error())
+				return nil, err
+			}
+
+			results, err := h.executeQuery(ctx, params.Query, email)
 			if err != nil {
 				log.Printf("[CHAT] Query execution failed: %v", err)
-				return nil, fmt.Errorf("query execution failed: %w", err)
+				// Keep the failure alongside the query so the UI can show what was attempted
+				mu.Lock()
+				*queryErr = err.Error()
+				mu.Unlock()
+				callback("error", fmt.Sprintf("query failed: %v", err))
+				return nil, fmt.Errorf("%w: %w", ErrQueryExecution, err)
+			}
+
+			// Remember which flights matched so the UI can highlight them
+			ids := flightIDs(results)
+			mu.Lock()
+			*matchedIDs = ids
+			mu.Unlock()
+
+			// Let the UI show the row count before the model's summary arrives
+			event, _ := json.Marshal(QueryResultsEvent{RowCount: len(results), Preview: previewResults(results)})
+			callback("results", string(event))
+
+			// The chat is about to pause and ask the user; the model's answer is discarded
+			if clarify(params.Query, results) {
+				return map[string]interface{}{
+					"resultCount": len(results),
+					"clarifying":  "The app is asking the user which airport they meant. Do not answer.",
+				}, nil
 			}
 
 			resultJSON, _ := json.Marshal(results)
@@ -135,30 +386,174 @@ func (h *ChatHandler) createQueryTool(
 		})
 }
 
-// Chat processes a natural language query about flights
-func (h *ChatHandler) Chat(ctx context.Context, userMessage, email, model string, callback ProgressCallback) (*ChatResponse, error) {
-	log.Printf("[CHAT] Starting | Model: %s | Email: %s | Message: %s", model, email, userMessage)
+// validateQuery checks a generated query before it runs: it must stay within the user's partition
+// and use only allow-listed keywords and functions
+func (h *ChatHandler) validateQuery(query, email string) error {
+	if err := checkQueryScope(query, email); err != nil {
+		return err
+	}
+	return checkQueryWords(query, h.allowedSQL)
+}
+
+// flightIDs returns the id of each result that is a flight document. Projections and
+// aggregates without an id (e.g. SELECT VALUE COUNT(1)) yield no IDs.
+func flightIDs(results []json.RawMessage) []string {
+	var ids []string
+	for _, raw := range results {
+		var doc struct {
+			ID           string `json:"id"`
+			FlightNumber string `json:"flightNumber"`
+		}
+		if json.Unmarshal(raw, &doc) != nil || doc.ID == "" || doc.FlightNumber == "" {
+			continue
+		}
+		ids = append(ids, doc.ID)
+	}
+	return ids
+}
+
+// createGetFlightTool creates the get_flight tool for fetching a single flight's full record
+func (h *ChatHandler) createGetFlightTool(ctx context.Context, email string) sdk.Tool {
+	return sdk.DefineTool("get_flight",
+		"Fetch the full details of a single flight by its id. Use this after identifying a flight from a query_flights result to look up details such as gate, seat, or departure time.",
+		func(params GetFlightParams, inv sdk.ToolInvocation) (any, error) {
+			log.Printf("[CHAT] AI requested flight details: %s", params.FlightID)
+
+			// Always scoped to the user's partition, so other users' flights can't be fetched
+			flight, err := h.store.GetFlight(ctx, params.FlightID, email)
+			if errors.Is(err, cosmosdb.ErrNotFound) {
+				return nil, fmt.Errorf("no flight found with id %s", params.FlightID)
+			}
+			if err != nil {
+				log.Printf("[CHAT] Get flight failed: %v", err)
+				return nil, fmt.Errorf("get flight failed: %w", err)
+			}
+
+			return flight, nil
+		})
+}
+
+// Chat processes a natural language query about flights.
+// If the AI generated a query, the returned ChatResponse is non-nil even when err is set,
+// so callers can surface the attempted query alongside the failure. Errors wrap
+// ErrModelUnavailable, ErrChatTimeout, or ErrQueryExecution where applicable.
+// Dates in the answer are written for locale (see DateLayout); empty means US style.
+func (h *ChatHandler) Chat(ctx context.Context, userMessage, email, model, locale string, callback ProgressCallback) (*ChatResponse, error) {
+	ctx, span := tracer.Start(ctx, "ai.Chat", trace.WithAttributes(attribute.String("ai.model", model)))
+	resp, err := h.chat(ctx, userMessage, email, model, locale, callback)
+	if resp != nil && resp.Meta != nil {
+		span.SetAttributes(attribute.Int("ai.query_count", resp.Meta.QueryCount))
+	}
+	tracing.End(span, err)
+	return resp, err
+}
+
+// chat implements Chat in a single Copilot session
+func (h *ChatHandler) chat(ctx context.Context, userMessage, email, model, locale string, callback ProgressCallback) (*ChatResponse, error) {
+	// Tools run their Cosmos DB queries under the session span
+	ctx, sessionSpan := startSessionSpan(ctx, "chat", model)
+	defer sessionSpan.End()
+
+	log.Printf("[CHAT] Starting | Model: %s | Email: %s | Message: %s", model, redact.Email(email), redact.Text(userMessage))
+
+	// A yes or no to a proposed change is answered here; the model never makes the change
+	if resp, handled, err := h.answerConfirmation(ctx, email, userMessage, callback); handled {
+		return resp, err
+	}
 
-	var generatedQuery string
+	// Refuse SQL written by the user without spending a model call on it
+	if looksLikeSQL(userMessage) {
+		log.Printf("[CHAT] WARNING: Refused raw SQL message | Email: %s", redact.Email(email))
+		return &ChatResponse{Message: rawSQLRefusal}, nil
+	}
+
+	var generatedQuery, queryErr string
+	var matchedIDs []string
+	var chart *Chart
+	meta := ChatMeta{Model: model}
 	var mu sync.Mutex
 
-	queryTool := h.createQueryTool(ctx, email, callback, &generatedQuery, &mu)
+	// A reply to a clarification question is sent as the original question with the choice attached
+	prompt, clarified := h.clarify.resolve(email, userMessage)
+	if clarified {
+		log.Printf("[CHAT] Resolved clarification | Email: %s | Prompt: %s", redact.Email(email), redact.Text(prompt))
+	}
+
+	// Ask which airport was meant when a question about one flight matched several; answers
+	// to a clarification are never paused again
+	clarifyCh := make(chan *Clarification, 1)
+	clarify := func(query string, results []json.RawMessage) bool {
+		if clarified || !asksForOneFlight(userMessage) {
+			return false
+		}
+		c := detectAmbiguity(query, results)
+		if c == nil {
+			return false
+		}
+		select {
+		case clarifyCh <- c:
+			return true
+		default:
+			return false
+		}
+	}
+
+	// Pause to ask the user before deleting or changing a flight the model picked
+	confirmCh := make(chan *Confirmation, 1)
+	propose := func(c *Confirmation) bool {
+		select {
+		case confirmCh <- c:
+			return true
+		default:
+			return false
+		}
+	}
+
+	queryTool := h.createQueryTool(ctx, email, callback, &generatedQuery, &queryErr, &matchedIDs, &meta.QueryCount, &mu, clarify)
+	getFlightTool := h.createGetFlightTool(ctx, email)
+	statsTool := h.createStatsTool(ctx, email, callback)
+	periodTool := h.createPeriodTool(ctx, email, callback)
+	chartTool := h.createChartTool(ctx, email, callback, &chart, &mu)
+	changeTools := h.createChangeTools(ctx, email, propose)
+
+	// buildResponse snapshots the query state captured by the tool so far
+	buildResponse := func(message string) *ChatResponse {
+		mu.Lock()
+		defer mu.Unlock()
+		metaCopy := meta
+		return &ChatResponse{
+			Message:    message,
+			Query:      generatedQuery,
+			Error:      queryErr,
+			MatchedIDs: matchedIDs,
+			Meta:       &metaCopy,
+			Chart:      chart,
+		}
+	}
+
+	// partialResponse returns nil unless a query was attempted before the failure
+	partialResponse := func() *ChatResponse {
+		if resp := buildResponse(""); resp.Query != "" {
+			return resp
+		}
+		return nil
+	}
 
 	// Get current date for the system prompt
-	today := time.Now().Format("2006-01-02")
+	today := time.Now().In(h.location).Format("2006-01-02")
 
-	// Create session with the query tool
-	session, err := h.client.CreateSession(&sdk.SessionConfig{
+	// Create session with the query, detail lookup, statistics, and change proposal tools
+	session, err := h.newSession(&sdk.SessionConfig{
 		Model:     model,
 		Streaming: true,
-		Tools:     []sdk.Tool{queryTool},
+		Tools:     append([]sdk.Tool{queryTool, getFlightTool, statsTool, periodTool, chartTool}, changeTools...),
 		SystemMessage: &sdk.SystemMessageConfig{
 			Mode:    "replace",
-			Content: buildSystemMessage(today),
+			Content: buildSystemMessage(today, DateLayout(locale)),
 		},
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create session: %w", err)
+		return nil, fmt.Errorf("%w: failed to create session: %w", ErrModelUnavailable, err)
 	}
 	defer session.Destroy()
 
@@ -176,6 +571,22 @@ func (h *ChatHandler) Chat(ctx context.Context, userMessage, email, model string
 			if event.Data.Content != nil {
 				callback("delta", *event.Data.Content)
 			}
+		case "tool.execution_start":
+			mu.Lock()
+			meta.ToolCalls++
+			mu.Unlock()
+		case "assistant.usage":
+			mu.Lock()
+			if event.Data.Model != nil {
+				meta.Model = *event.Data.Model
+			}
+			if event.Data.InputTokens != nil {
+				meta.InputTokens += int(*event.Data.InputTokens)
+			}
+			if event.Data.OutputTokens != nil {
+				meta.OutputTokens += int(*event.Data.OutputTokens)
+			}
+			mu.Unlock()
 		case "session.idle":
 			close(responseCh)
 		case "session.error":
@@ -187,22 +598,57 @@ func (h *ChatHandler) Chat(ctx context.Context, userMessage, email, model string
 
 	// Send the user's question
 	_, err = session.Send(sdk.MessageOptions{
-		Prompt: userMessage,
+		Prompt: prompt,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to send message: %w", err)
+		return nil, fmt.Errorf("%w: failed to send message: %w", ErrModelUnavailable, err)
 	}
 
-	// Wait for completion
+	// Wait for completion. When giving up, abort the turn so the model stops generating
+	// (and consuming quota) right away rather than running on until the session is destroyed.
+	timer := time.NewTimer(ChatTimeout)
+	defer timer.Stop()
 	select {
 	case <-ctx.Done():
-		return nil, ctx.Err()
-	case <-time.After(ChatTimeout):
-		return nil, fmt.Errorf("chat timed out after %v", ChatTimeout)
+		log.Printf("[CHAT] Cancelled | Email: %s | %v", redact.Email(email), ctx.Err())
+		abortSession(session)
+		return partialResponse(), ctx.Err()
+	case <-timer.C:
+		abortSession(session)
+		resp := partialResponse()
+		// A failing generated query is the likelier culprit than the model itself
+		if resp != nil && resp.Error != "" {
+			return resp, fmt.Errorf("%w after %v: %w: %s", ErrChatTimeout, ChatTimeout, ErrQueryExecution, resp.Error)
+		}
+		return resp, fmt.Errorf("%w after %v", ErrChatTimeout, ChatTimeout)
+	case c := <-clarifyCh:
+		// Pause rather than let the model pick an airport; the user's next message resolves it
+		abortSession(session)
+		log.Printf("[CHAT] Asking for clarification | Email: %s | Options: %d", redact.Email(email), len(c.Options))
+		h.clarify.put(email, userMessage, c)
+		event, _ := json.Marshal(c)
+		callback("clarify", string(event))
+		resp := buildResponse(c.Question)
+		resp.Clarify = c
+		return resp, nil
+	case c := <-confirmCh:
+		// Pause so only the user's own reply can approve the change
+		abortSession(session)
+		log.Printf("[CHAT] Asking to confirm %s | Flight: %s | Email: %s", c.Action, c.Flight.ID, redact.Email(email))
+		h.confirm.put(email, c)
+		event, _ := json.Marshal(c)
+		callback("confirm", string(event))
+		resp := buildResponse(c.Question)
+		resp.Confirm = c
+		return resp, nil
 	case <-responseCh:
-		return &ChatResponse{
-			Message: finalResponse,
-			Query:   generatedQuery,
-		}, nil
+		return buildResponse(finalResponse), nil
+	}
+}
+
+// abortSession stops the session's in-progress turn. The session is still destroyed by the caller.
+func abortSession(session chatSession) {
+	if err := session.Abort(); err != nil {
+		log.Printf("[CHAT] Failed to abort session: %v", err)
 	}
 }
@@ -0,0 +1,116 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/abhirockzz/flight-log-app/cosmosdb"
+	"github.com/abhirockzz/flight-log-app/inmemory"
+	sdk "github.com/github/copilot-sdk/go"
+)
+
+func TestQueryToolLimit(t *testing.T) {
+	const email = "alice@example.com"
+	const maxQueries = 3
+	ctx := context.Background()
+
+	store := inmemory.New()
+	if _, err := store.SaveFlight(ctx, &cosmosdb.BoardingPass{Email: email, FlightNumber: "UA 1234", DepartureDate: "2026-03-14"}); err != nil {
+		t.Fatalf("SaveFlight: %v", err)
+	}
+	h := NewChatHandler(nil, store, WithMaxQueries(maxQueries))
+
+	var events []string
+	callback := func(eventType, data string) { events = append(events, eventType) }
+	var generatedQuery, queryErr string
+	var matchedIDs []string
+	var queryCount int
+	var mu sync.Mutex
+	noClarify := func(string, []json.RawMessage) bool { return false }
+	tool := h.createQueryTool(ctx, email, callback, &generatedQuery, &queryErr, &matchedIDs, &queryCount, &mu, noClarify)
+
+	query := "SELECT * FROM c WHERE c.email = 'alice@example.com'"
+	for i := 1; i <= maxQueries; i++ {
+		events = nil
+		if _, err := tool.Handler(sdk.ToolInvocation{Arguments: map[string]any{"query": query}}); err != nil {
+			t.Fatalf("query %d: %v", i, err)
+		}
+		for _, e := range events {
+			if e == "warning" {
+				t.Fatalf("query %d emitted a warning before the limit", i)
+			}
+		}
+	}
+
+	events = nil
+	_, err := tool.Handler(sdk.ToolInvocation{Arguments: map[string]any{"query": query}})
+	if !errors.Is(err, ErrQueryLimit) {
+		t.Fatalf("query %d = %v, want ErrQueryLimit", maxQueries+1, err)
+	}
+	if len(events) != 2 || events[1] != "warning" {
+		t.Fatalf("events = %v, want [query warning]", events)
+	}
+	if queryCount != maxQueries+1 {
+		t.Fatalf("queryCount = %d, want %d", queryCount, maxQueries+1)
+	}
+	if len(matchedIDs) != 1 {
+		t.Fatalf("matchedIDs = %v, want the last allowed query's results kept", matchedIDs)
+	}
+}
+
+// fakeSession accepts a message and never answers, like a model that is still thinking
+type fakeSession struct {
+	sent    chan struct{}
+	aborted chan struct{}
+}
+
+func (s *fakeSession) On(handler sdk.SessionEventHandler) func() { return func() {} }
+
+func (s *fakeSession) Send(options sdk.MessageOptions) (string, error) {
+	close(s.sent)
+	return "message-1", nil
+}
+
+func (s *fakeSession) Abort() error {
+	close(s.aborted)
+	return nil
+}
+
+func (s *fakeSession) Destroy() error { return nil }
+
+func TestChatCancelled(t *testing.T) {
+	session := &fakeSession{sent: make(chan struct{}), aborted: make(chan struct{})}
+	h := NewChatHandler(nil, inmemory.New())
+	h.newSession = func(*sdk.SessionConfig) (chatSession, error) { return session, nil }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-session.sent
+		cancel()
+	}()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := h.Chat(ctx, "how many flights did I take last year?", "alice@example.com", "gpt-4.1", "", func(string, string) {})
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Chat = %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Chat did not return after the context was cancelled")
+	}
+	select {
+	case <-session.aborted:
+	default:
+		t.Fatal("session was not aborted")
+	}
+}
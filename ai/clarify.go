@@ -0,0 +1,206 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// clarifyTTL is how long a clarification question waits for the user's answer
+const clarifyTTL = 10 * time.Minute
+
+// Clarification asks the user which of several airports a loose question meant. It is sent as
+// the clarify SSE event and on ChatResponse; the chat pauses instead of letting the model guess.
+type Clarification struct {
+	Question string          `json:"question"`
+	Field    string          `json:"field"` // toAirport or fromAirport
+	Options  []ClarifyOption `json:"options"`
+}
+
+// ClarifyOption is one answer to a Clarification
+type ClarifyOption struct {
+	Value string `json:"value"` // Airport code; sending it as the next message answers the question
+	Label string `json:"label"` // e.g. "JFK (2 flights)"
+	Count int    `json:"count"` // Matching flights at this airport
+}
+
+var (
+	// airportInList matches c.toAirport IN ('JFK', 'LGA', ...)
+	airportInList = regexp.MustCompile(`(?i)\bc\.(toAirport|fromAirport)\s+IN\s*\(([^)]*)\)`)
+	// airportEquals matches c.toAirport = 'JFK', which a query ORs together for a city
+	airportEquals = regexp.MustCompile(`(?i)\bc\.(toAirport|fromAirport)\s*=\s*'([A-Za-z0-9]{3,4})'`)
+	// quotedCode matches one code in an IN list
+	quotedCode = regexp.MustCompile(`'([A-Za-z0-9]{3,4})'`)
+	// oneFlight and manyFlights tell "my flight to New York" from "my flights to New York"
+	oneFlight   = regexp.MustCompile(`(?i)\b(flight|trip)\b`)
+	manyFlights = regexp.MustCompile(`(?i)\b(flights|trips|all|every|how many|count|total)\b`)
+)
+
+// asksForOneFlight reports whether message asks about a single flight, where matching
+// several airports means the question was ambiguous rather than broad
+func asksForOneFlight(message string) bool {
+	return oneFlight.MatchString(message) && !manyFlights.MatchString(message)
+}
+
+// detectAmbiguity returns a Clarification when query expanded one place into several airports
+// (e.g. New York into JFK, LGA, and EWR) and results include flights at more than one of them.
+// It returns nil for projections that don't select the airport field.
+func detectAmbiguity(query string, results []json.RawMessage) *Clarification {
+	codes := map[string][]string{}
+	add := func(field, code string) {
+		field = canonicalAirportField(field)
+		code = strings.ToUpper(code)
+		for _, c := range codes[field] {
+			if c == code {
+				return
+			}
+		}
+		codes[field] = append(codes[field], code)
+	}
+	for _, m := range airportInList.FindAllStringSubmatch(query, -1) {
+		for _, c := range quotedCode.FindAllStringSubmatch(m[2], -1) {
+			add(m[1], c[1])
+		}
+	}
+	for _, m := range airportEquals.FindAllStringSubmatch(query, -1) {
+		add(m[1], m[2])
+	}
+
+	for _, field := range []string{"toAirport", "fromAirport"} {
+		if len(codes[field]) < 2 {
+			continue
+		}
+		counts := map[string]int{}
+		for _, raw := range results {
+			var doc map[string]any
+			if json.Unmarshal(raw, &doc) != nil {
+				continue
+			}
+			code, _ := doc[field].(string)
+			code = strings.ToUpper(code)
+			for _, c := range codes[field] {
+				if c == code {
+					counts[code]++
+				}
+			}
+		}
+		if len(counts) < 2 {
+			continue
+		}
+		return newClarification(field, counts)
+	}
+	return nil
+}
+
+// canonicalAirportField maps a field name in any case to its stored spelling
+func canonicalAirportField(field string) string {
+	if strings.EqualFold(field, "fromAirport") {
+		return "fromAirport"
+	}
+	return "toAirport"
+}
+
+// newClarification builds the question for the airports in counts, busiest first
+func newClarification(field string, counts map[string]int) *Clarification {
+	c := &Clarification{Field: field}
+	for code, n := range counts {
+		noun := "flights"
+		if n == 1 {
+			noun = "flight"
+		}
+		c.Options = append(c.Options, ClarifyOption{Value: code, Label: fmt.Sprintf("%s (%d %s)", code, n, noun), Count: n})
+	}
+	sort.Slice(c.Options, func(i, j int) bool {
+		if c.Options[i].Count != c.Options[j].Count {
+			return c.Options[i].Count > c.Options[j].Count
+		}
+		return c.Options[i].Value < c.Options[j].Value
+	})
+
+	values := make([]string, len(c.Options))
+	for i, o := range c.Options {
+		values[i] = o.Value
+	}
+	direction := "to"
+	if field == "fromAirport" {
+		direction = "from"
+	}
+	list := strings.Join(values[:len(values)-1], ", ") + " and " + values[len(values)-1]
+	c.Question = fmt.Sprintf("You have flights %s %s. Which airport did you mean?", direction, list)
+	return c
+}
+
+// pendingClarification is a question waiting for the user's next message
+type pendingClarification struct {
+	question      string // The user's original message
+	clarification *Clarification
+	expires       time.Time
+}
+
+// clarifications remembers the last unanswered clarification per user, which is all the
+// conversation memory the chat keeps. Any next message from the user consumes it.
+type clarifications struct {
+	mu      sync.Mutex
+	pending map[string]pendingClarification // Keyed by lowercased email
+}
+
+func newClarifications() *clarifications {
+	return &clarifications{pending: make(map[string]pendingClarification)}
+}
+
+// put records that the user's question was paused with c
+func (s *clarifications) put(email, question string, c *Clarification) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for key, p := range s.pending {
+		if now.After(p.expires) {
+			delete(s.pending, key)
+		}
+	}
+	s.pending[strings.ToLower(email)] = pendingClarification{question: question, clarification: c, expires: now.Add(clarifyTTL)}
+}
+
+// resolve consumes the user's pending clarification. If message picks one of its options, it
+// returns the prompt to send instead: the original question with the choice attached.
+// Otherwise message is a new question and is returned unchanged with false.
+func (s *clarifications) resolve(email, message string) (string, bool) {
+	s.mu.Lock()
+	p, ok := s.pending[strings.ToLower(email)]
+	delete(s.pending, strings.ToLower(email))
+	s.mu.Unlock()
+	if !ok || time.Now().After(p.expires) {
+		return message, false
+	}
+
+	choice, ok := p.clarification.choose(message)
+	if !ok {
+		return message, false
+	}
+	return fmt.Sprintf("Original question: %s\nClarification: %s is %s", p.question, p.clarification.Field, choice), true
+}
+
+// choose returns the option message picks: its code, its position in the list (e.g. "2"),
+// or a reply naming exactly one of the codes (e.g. "the JFK one")
+func (c *Clarification) choose(message string) (string, bool) {
+	message = strings.TrimSpace(message)
+	if n, err := strconv.Atoi(message); err == nil && n >= 1 && n <= len(c.Options) {
+		return c.Options[n-1].Value, true
+	}
+
+	var picked []string
+	for _, o := range c.Options {
+		if regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(o.Value) + `\b`).MatchString(message) {
+			picked = append(picked, o.Value)
+		}
+	}
+	if len(picked) != 1 {
+		return "", false
+	}
+	return picked[0], true
+}
@@ -0,0 +1,225 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abhirockzz/flight-log-app/cosmosdb"
+	"github.com/abhirockzz/flight-log-app/redact"
+	sdk "github.com/github/copilot-sdk/go"
+)
+
+// confirmTTL is how long a proposed change waits for the user to confirm it
+const confirmTTL = 5 * time.Minute
+
+// Changes the chat can propose
+const (
+	ActionDelete = "delete"
+	ActionUpdate = "update"
+)
+
+// Confirmation is a change to one flight proposed by the model through delete_flight or
+// update_flight. It is sent as the confirm SSE event and on ChatResponse. Nothing is changed
+// until the user's next message confirms it; the model never makes the change itself.
+type Confirmation struct {
+	Action   string                 `json:"action"` // ActionDelete or ActionUpdate
+	Question string                 `json:"question"`
+	Flight   *cosmosdb.BoardingPass `json:"flight"`
+	Changes  map[string]string      `json:"changes,omitempty"` // Fields set by an update, keyed by JSON name
+}
+
+var (
+	// confirmYes and confirmNo match short replies to a confirmation question
+	confirmYes = regexp.MustCompile(`(?i)^(y|yes|yep|yeah|sure|ok|okay|confirm|confirmed|do it|go ahead|please do|yes,? (please|delete it|do it|change it|update it))[.!]*$`)
+	confirmNo  = regexp.MustCompile(`(?i)^(n|no|nope|cancel|stop|don'?t|never ?mind|keep it|no,? (thanks|keep it|don'?t|cancel))[.!]*$`)
+)
+
+// pendingConfirmation is a proposed change waiting for the user's next message
+type pendingConfirmation struct {
+	confirmation *Confirmation
+	expires      time.Time
+}
+
+// confirmations remembers the last unconfirmed change per user. It waits for a yes or no until
+// it expires, so a question asked in between doesn't lose it; a newer proposal replaces it.
+type confirmations struct {
+	mu      sync.Mutex
+	pending map[string]pendingConfirmation // Keyed by lowercased email
+}
+
+func newConfirmations() *confirmations {
+	return &confirmations{pending: make(map[string]pendingConfirmation)}
+}
+
+// put records that the chat is waiting for the user to confirm c
+func (s *confirmations) put(email string, c *Confirmation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for key, p := range s.pending {
+		if now.After(p.expires) {
+			delete(s.pending, key)
+		}
+	}
+	s.pending[strings.ToLower(email)] = pendingConfirmation{confirmation: c, expires: now.Add(confirmTTL)}
+}
+
+// peek returns the user's pending confirmation, if one hasn't expired, without consuming it
+func (s *confirmations) peek(email string) (*Confirmation, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.pending[strings.ToLower(email)]
+	if !ok || time.Now().After(p.expires) {
+		return nil, false
+	}
+	return p.confirmation, true
+}
+
+// remove drops the user's pending confirmation once it has been answered
+func (s *confirmations) remove(email string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.pending, strings.ToLower(email))
+}
+
+// newConfirmation builds the question asking the user to approve a change to flight
+func newConfirmation(action string, flight *cosmosdb.BoardingPass, changes map[string]string) *Confirmation {
+	desc := fmt.Sprintf("%s (%s → %s on %s)", flight.FlightNumber, flight.FromAirport, flight.ToAirport, flight.DepartureDate)
+	c := &Confirmation{Action: action, Flight: flight, Changes: changes}
+	if action == ActionDelete {
+		c.Question = fmt.Sprintf("Delete %s? It moves to the trash. Reply yes to confirm or no to keep it.", desc)
+		return c
+	}
+
+	fields := make([]string, 0, len(changes))
+	for field := range changes {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	sets := make([]string, len(fields))
+	for i, field := range fields {
+		sets[i] = fmt.Sprintf("%s to %q", field, changes[field])
+	}
+	c.Question = fmt.Sprintf("Change %s on %s? Reply yes to confirm or no to cancel.", strings.Join(sets, ", "), desc)
+	return c
+}
+
+// createChangeTools creates the delete_flight and update_flight tools. Neither changes anything:
+// they look up the flight in the user's partition and hand a Confirmation to propose, which
+// pauses the chat until the user answers.
+func (h *ChatHandler) createChangeTools(ctx context.Context, email string, propose func(*Confirmation) bool) []sdk.Tool {
+	lookup := func(id string) (*cosmosdb.BoardingPass, error) {
+		flight, err := h.store.GetFlight(ctx, id, email)
+		if errors.Is(err, cosmosdb.ErrNotFound) {
+			return nil, fmt.Errorf("no flight found with id %s", id)
+		}
+		if err != nil {
+			log.Printf("[CHAT] Get flight failed: %v", err)
+			return nil, fmt.Errorf("get flight failed: %w", err)
+		}
+		return flight, nil
+	}
+	confirming := func(c *Confirmation) (any, error) {
+		if !propose(c) {
+			return nil, errors.New("a change is already waiting for confirmation; propose only one change per question")
+		}
+		return map[string]string{
+			"confirming": "The app is asking the user to confirm this change. Nothing has been changed yet. Do not answer.",
+		}, nil
+	}
+
+	deleteTool := sdk.DefineTool("delete_flight",
+		"Propose deleting one flight by its id, taken from a query_flights result. This does not delete anything: the app asks the user to confirm first.",
+		func(params DeleteFlightParams, inv sdk.ToolInvocation) (any, error) {
+			log.Printf("[CHAT] AI proposed deleting flight: %s", params.FlightID)
+			flight, err := lookup(params.FlightID)
+			if err != nil {
+				return nil, err
+			}
+			return confirming(newConfirmation(ActionDelete, flight, nil))
+		})
+
+	updateTool := sdk.DefineTool("update_flight",
+		"Propose changing fields of one flight by its id, taken from a query_flights result. This does not change anything: the app asks the user to confirm first.",
+		func(params UpdateFlightParams, inv sdk.ToolInvocation) (any, error) {
+			log.Printf("[CHAT] AI proposed updating flight: %s | Changes: %s", params.FlightID, redact.Text(fmt.Sprint(params.Changes)))
+			ops := make(map[string]any, len(params.Changes))
+			for field, value := range params.Changes {
+				ops[field] = value
+			}
+			if _, err := cosmosdb.ValidatePatch(ops); err != nil {
+				return nil, err
+			}
+			flight, err := lookup(params.FlightID)
+			if err != nil {
+				return nil, err
+			}
+			return confirming(newConfirmation(ActionUpdate, flight, params.Changes))
+		})
+
+	return []sdk.Tool{deleteTool, updateTool}
+}
+
+// answerConfirmation handles the user's reply to a pending proposed change without involving the
+// model. A yes applies the change and a no drops it. It returns false when nothing is pending or
+// message is neither, which is then treated as a new question and leaves the proposal pending.
+// If the flight was deleted or edited since it was proposed, the error wraps cosmosdb.ErrNotFound
+// or cosmosdb.ErrPreconditionFailed, alongside a response explaining that nothing was changed.
+func (h *ChatHandler) answerConfirmation(ctx context.Context, email, message string, callback ProgressCallback) (*ChatResponse, bool, error) {
+	c, ok := h.confirm.peek(email)
+	if !ok {
+		return nil, false, nil
+	}
+	message = strings.TrimSpace(message)
+	switch {
+	case confirmNo.MatchString(message):
+		h.confirm.remove(email)
+		log.Printf("[CHAT] Change declined | Action: %s | Flight: %s | Email: %s", c.Action, c.Flight.ID, redact.Email(email))
+		return &ChatResponse{Message: "OK, I left the flight unchanged."}, true, nil
+	case !confirmYes.MatchString(message):
+		return nil, false, nil
+	}
+	h.confirm.remove(email)
+
+	// The write is conditional on the ETag the user saw, so a flight changed (or deleted) since is left alone
+	var reply string
+	var err error
+	switch c.Action {
+	case ActionDelete:
+		_, err = h.store.DeleteFlight(ctx, c.Flight.ID, email, c.Flight.ETag)
+		reply = fmt.Sprintf("Deleted %s. You can restore it from the trash.", c.Flight.FlightNumber)
+	case ActionUpdate:
+		ops := make(map[string]any, len(c.Changes))
+		for field, value := range c.Changes {
+			ops[field] = value
+		}
+		err = h.store.PatchFlight(ctx, c.Flight.ID, email, ops, c.Flight.ETag)
+		reply = fmt.Sprintf("Updated %s.", c.Flight.FlightNumber)
+	}
+	if errors.Is(err, cosmosdb.ErrNotFound) {
+		return &ChatResponse{Message: "That flight no longer exists, so there was nothing to change."}, true,
+			fmt.Errorf("flight %s was deleted before the %s was confirmed: %w", c.Flight.ID, c.Action, err)
+	}
+	if errors.Is(err, cosmosdb.ErrPreconditionFailed) {
+		return &ChatResponse{Message: "That flight was edited since I asked, so I didn't change it. Please ask again."}, true,
+			fmt.Errorf("flight %s was edited before the %s was confirmed: %w", c.Flight.ID, c.Action, err)
+	}
+	if err != nil {
+		log.Printf("[CHAT] Confirmed %s failed: %v", c.Action, err)
+		return nil, true, fmt.Errorf("failed to %s flight: %w", c.Action, err)
+	}
+	h.InvalidateCache(email)
+
+	log.Printf("[CHAT] Change applied | Action: %s | Flight: %s | Email: %s", c.Action, c.Flight.ID, redact.Email(email))
+	event, _ := json.Marshal(c)
+	callback("applied", string(event))
+	return &ChatResponse{Message: reply, Applied: c, MatchedIDs: []string{c.Flight.ID}}, true, nil
+}
@@ -0,0 +1,172 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/abhirockzz/flight-log-app/cosmosdb"
+	"github.com/abhirockzz/flight-log-app/inmemory"
+)
+
+const confirmEmail = "alice@example.com"
+
+// newConfirmTest saves a flight to an in-memory store and returns a chat handler with a
+// pending change of action proposed for it
+func newConfirmTest(t *testing.T, action string, changes map[string]string) (*ChatHandler, *inmemory.Store, *cosmosdb.BoardingPass) {
+	t.Helper()
+	store := inmemory.New()
+	flight, err := store.SaveFlight(context.Background(), &cosmosdb.BoardingPass{
+		Email:         confirmEmail,
+		FlightNumber:  "UA 1234",
+		FromAirport:   "SFO",
+		ToAirport:     "LAX",
+		DepartureDate: "2026-03-14",
+		Note:          "window seat",
+	})
+	if err != nil {
+		t.Fatalf("SaveFlight: %v", err)
+	}
+
+	h := NewChatHandler(nil, store)
+	h.confirm.put(confirmEmail, newConfirmation(action, flight, changes))
+	return h, store, flight
+}
+
+// recordEvents returns a ProgressCallback that collects event types
+func recordEvents(events *[]string) ProgressCallback {
+	return func(eventType, data string) {
+		*events = append(*events, eventType)
+	}
+}
+
+func TestAnswerConfirmationYes(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("delete", func(t *testing.T) {
+		h, store, flight := newConfirmTest(t, ActionDelete, nil)
+		h.cache.Put(confirmEmail, "SELECT * FROM c", []json.RawMessage{json.RawMessage(`{}`)})
+
+		var events []string
+		resp, handled, err := h.answerConfirmation(ctx, confirmEmail, "yes", recordEvents(&events))
+		if !handled || err != nil {
+			t.Fatalf("answerConfirmation = handled %v, err %v; want handled, nil", handled, err)
+		}
+		if resp.Applied == nil || resp.Applied.Action != ActionDelete {
+			t.Fatalf("Applied = %+v, want the delete", resp.Applied)
+		}
+		if _, err := store.GetFlight(ctx, flight.ID, confirmEmail); !errors.Is(err, cosmosdb.ErrNotFound) {
+			t.Fatalf("GetFlight after delete = %v, want ErrNotFound", err)
+		}
+		if _, ok := h.cache.Get(confirmEmail, "SELECT * FROM c"); ok {
+			t.Fatal("query cache still holds the user's results after the change")
+		}
+		if len(events) != 1 || events[0] != "applied" {
+			t.Fatalf("events = %v, want [applied]", events)
+		}
+		if _, ok := h.confirm.peek(confirmEmail); ok {
+			t.Fatal("confirmation still pending after yes")
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+		h, store, flight := newConfirmTest(t, ActionUpdate, map[string]string{"note": "aisle seat"})
+
+		if _, handled, err := h.answerConfirmation(ctx, confirmEmail, "Yes, please", func(string, string) {}); !handled || err != nil {
+			t.Fatalf("answerConfirmation = handled %v, err %v; want handled, nil", handled, err)
+		}
+		current, err := store.GetFlight(ctx, flight.ID, confirmEmail)
+		if err != nil {
+			t.Fatalf("GetFlight: %v", err)
+		}
+		if current.Note != "aisle seat" {
+			t.Fatalf("Note = %q, want %q", current.Note, "aisle seat")
+		}
+	})
+}
+
+func TestAnswerConfirmationNo(t *testing.T) {
+	ctx := context.Background()
+	h, store, flight := newConfirmTest(t, ActionDelete, nil)
+
+	resp, handled, err := h.answerConfirmation(ctx, confirmEmail, "no", func(string, string) {})
+	if !handled || err != nil {
+		t.Fatalf("answerConfirmation = handled %v, err %v; want handled, nil", handled, err)
+	}
+	if resp.Applied != nil {
+		t.Fatalf("Applied = %+v, want nil", resp.Applied)
+	}
+	if _, err := store.GetFlight(ctx, flight.ID, confirmEmail); err != nil {
+		t.Fatalf("GetFlight after no = %v, want the flight kept", err)
+	}
+	if _, ok := h.confirm.peek(confirmEmail); ok {
+		t.Fatal("confirmation still pending after no")
+	}
+}
+
+func TestAnswerConfirmationUnrelated(t *testing.T) {
+	ctx := context.Background()
+	h, store, flight := newConfirmTest(t, ActionDelete, nil)
+
+	resp, handled, err := h.answerConfirmation(ctx, confirmEmail, "how many flights did I take last year?", func(string, string) {})
+	if handled || resp != nil || err != nil {
+		t.Fatalf("answerConfirmation = %+v, %v, %v; want nil, false, nil", resp, handled, err)
+	}
+	if _, err := store.GetFlight(ctx, flight.ID, confirmEmail); err != nil {
+		t.Fatalf("GetFlight = %v, want the flight kept", err)
+	}
+	c, ok := h.confirm.peek(confirmEmail)
+	if !ok || c.Flight.ID != flight.ID {
+		t.Fatalf("pending confirmation = %+v, %v; want the delete still pending", c, ok)
+	}
+
+	// The change can still be confirmed afterwards
+	if _, handled, err := h.answerConfirmation(ctx, confirmEmail, "yes", func(string, string) {}); !handled || err != nil {
+		t.Fatalf("answerConfirmation = handled %v, err %v; want handled, nil", handled, err)
+	}
+}
+
+func TestAnswerConfirmationEdited(t *testing.T) {
+	ctx := context.Background()
+	for _, action := range []string{ActionDelete, ActionUpdate} {
+		t.Run(action, func(t *testing.T) {
+			h, store, flight := newConfirmTest(t, action, map[string]string{"note": "aisle seat"})
+			if err := store.PatchFlight(ctx, flight.ID, confirmEmail, map[string]any{"note": "edited elsewhere"}, ""); err != nil {
+				t.Fatalf("PatchFlight: %v", err)
+			}
+
+			var events []string
+			resp, handled, err := h.answerConfirmation(ctx, confirmEmail, "yes", recordEvents(&events))
+			if !handled || !errors.Is(err, cosmosdb.ErrPreconditionFailed) {
+				t.Fatalf("answerConfirmation = handled %v, err %v; want handled, ErrPreconditionFailed", handled, err)
+			}
+			if resp == nil || resp.Applied != nil {
+				t.Fatalf("response = %+v, want a message and nothing applied", resp)
+			}
+			current, err := store.GetFlight(ctx, flight.ID, confirmEmail)
+			if err != nil || current.Note != "edited elsewhere" {
+				t.Fatalf("GetFlight = %+v, %v; want the other edit kept", current, err)
+			}
+			if len(events) != 0 {
+				t.Fatalf("events = %v, want none", events)
+			}
+		})
+	}
+}
+
+func TestAnswerConfirmationDeleted(t *testing.T) {
+	ctx := context.Background()
+	h, store, flight := newConfirmTest(t, ActionUpdate, map[string]string{"note": "aisle seat"})
+	if _, err := store.DeleteFlight(ctx, flight.ID, confirmEmail, ""); err != nil {
+		t.Fatalf("DeleteFlight: %v", err)
+	}
+
+	resp, handled, err := h.answerConfirmation(ctx, confirmEmail, "yes", func(string, string) {})
+	if !handled || !errors.Is(err, cosmosdb.ErrNotFound) {
+		t.Fatalf("answerConfirmation = handled %v, err %v; want handled, ErrNotFound", handled, err)
+	}
+	if resp == nil || resp.Applied != nil {
+		t.Fatalf("response = %+v, want a message and nothing applied", resp)
+	}
+}
@@ -0,0 +1,31 @@
+package ai
+
+import "errors"
+
+// Sentinel errors returned (wrapped) by Chat and Extract so callers can tell
+// backend failures apart from problems with the generated query or the image.
+var (
+	// ErrModelUnavailable means the Copilot session could not be created or the message could not be sent
+	ErrModelUnavailable = errors.New("model unavailable")
+
+	// ErrQueryExecution means the AI-generated Cosmos DB query failed to run
+	ErrQueryExecution = errors.New("query execution failed")
+
+	// ErrQueryScope means the AI-generated query was rejected for reaching outside the user's partition
+	ErrQueryScope = errors.New("query not scoped to the user's partition")
+
+	// ErrQueryNotAllowed means the AI-generated query used a keyword or function outside the allow-list
+	ErrQueryNotAllowed = errors.New("query uses a disallowed keyword or function")
+
+	// ErrQueryLimit means the model called query_flights more times than allowed in one chat
+	ErrQueryLimit = errors.New("query limit reached")
+
+	// ErrExtractionTimeout means the model did not capture flight details within DefaultExtractionTimeout
+	ErrExtractionTimeout = errors.New("extraction timed out")
+
+	// ErrChatTimeout means the model did not finish answering within ChatTimeout
+	ErrChatTimeout = errors.New("chat timed out")
+
+	// ErrNoVisionModel means none of the requested extraction models accept image input
+	ErrNoVisionModel = errors.New("model does not support image input")
+)
@@ -2,31 +2,111 @@ package ai
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/abhirockzz/flight-log-app/airports"
 	"github.com/abhirockzz/flight-log-app/cosmosdb"
+	"github.com/abhirockzz/flight-log-app/redact"
+	"github.com/abhirockzz/flight-log-app/tracing"
 	sdk "github.com/github/copilot-sdk/go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
 	// DefaultExtractionTimeout is the default timeout for boarding pass extraction
 	DefaultExtractionTimeout = 60 * time.Second
+
+	// captureToolName is the tool the model calls with the extracted fields
+	captureToolName = "capture_flight_details"
 )
 
 // BoardingPassExtractor handles the extraction of flight details from boarding pass images
 // using the Copilot SDK's vision capabilities.
 type BoardingPassExtractor struct {
-	client *sdk.Client
+	client       *sdk.Client
+	systemPrompt string                        // Overrides the built-in extraction prompt when set
+	onAttempt    func(model string, err error) // Called after each model attempt; nil if unset
+	catalog      ModelCatalog                  // Looks up vision support; nil trusts every model
+}
+
+// ModelCatalog reports whether a model accepts image input. known is false for models
+// missing from the catalog, which are tried as requested.
+type ModelCatalog func(model string) (vision, known bool)
+
+// ExtractorOption configures a BoardingPassExtractor
+type ExtractorOption func(*BoardingPassExtractor)
+
+// WithSystemPrompt replaces the built-in extraction prompt, e.g. for train or event tickets.
+// The prompt must pass ValidateExtractionPrompt; invalid prompts are ignored with a log message.
+func WithSystemPrompt(prompt string) ExtractorOption {
+	return func(e *BoardingPassExtractor) {
+		if err := ValidateExtractionPrompt(prompt); err != nil {
+			log.Printf("[EXTRACT] Ignoring custom system prompt: %v", err)
+			return
+		}
+		e.systemPrompt = prompt
+	}
+}
+
+// WithAttemptObserver registers fn to be called with the outcome of every model attempt,
+// including ones that are retried on a fallback model (e.g. to record metrics)
+func WithAttemptObserver(fn func(model string, err error)) ExtractorOption {
+	return func(e *BoardingPassExtractor) {
+		e.onAttempt = fn
+	}
+}
+
+// WithModelCatalog lets Extract skip models the catalog knows can't read images,
+// substituting the next vision-capable model instead of failing deep in the session
+func WithModelCatalog(catalog ModelCatalog) ExtractorOption {
+	return func(e *BoardingPassExtractor) {
+		e.catalog = catalog
+	}
+}
+
+// ValidateExtractionPrompt checks that a custom prompt still instructs the model to call
+// the capture tool, since extraction never completes otherwise.
+func ValidateExtractionPrompt(prompt string) error {
+	if strings.TrimSpace(prompt) == "" {
+		return errors.New("prompt is empty")
+	}
+	if !strings.Contains(prompt, captureToolName) {
+		return fmt.Errorf("prompt must instruct the model to call the %s tool", captureToolName)
+	}
+	return nil
 }
 
 // NewBoardingPassExtractor creates a new extractor using the provided Copilot client.
-func NewBoardingPassExtractor(client *sdk.Client) *BoardingPassExtractor {
-	return &BoardingPassExtractor{
+func NewBoardingPassExtractor(client *sdk.Client, opts ...ExtractorOption) *BoardingPassExtractor {
+	e := &BoardingPassExtractor{
 		client: client,
 	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// FallbackEvent is the payload of a fallback event, sent when extraction is retried on another model
+type FallbackEvent struct {
+	From   string `json:"from"`   // Model that failed
+	To     string `json:"to"`     // Model being tried next
+	Reason string `json:"reason"` // Why the first model failed
+}
+
+// SubstitutedEvent is the payload of a substituted event, sent when the requested model
+// can't read images and extraction runs on a vision-capable model instead
+type SubstitutedEvent struct {
+	Requested string `json:"requested"` // Model the client asked for
+	Model     string `json:"model"`     // Model used instead
+	Reason    string `json:"reason"`
 }
 
 // Extract analyzes a boarding pass image and extracts flight details.
@@ -36,18 +116,97 @@ func NewBoardingPassExtractor(client *sdk.Client) *BoardingPassExtractor {
 //   - ctx: Context for cancellation
 //   - imagePath: Path to the boarding pass image file
 //   - email: User's email address (used as partition key)
+//   - models: Models to use in priority order; if the first one's session errors or times out,
+//     extraction is retried once on the next, after a "fallback" event. Models the catalog (see
+//     WithModelCatalog) marks as non-vision are skipped, after a "substituted" event if the first is
 //   - callback: Function called with progress updates (eventType, data)
 //
-// Returns the extracted BoardingPass or an error if extraction fails.
-func (e *BoardingPassExtractor) Extract(ctx context.Context, imagePath, email, model string, callback ProgressCallback) (*cosmosdb.BoardingPass, error) {
-	log.Printf("[EXTRACT] Starting | Model: %s | Email: %s | Image: %s", model, email, imagePath)
+// Returns the extracted BoardingPass or an error if extraction fails. Errors wrap
+// ErrModelUnavailable, ErrExtractionTimeout, or ErrNoVisionModel where applicable.
+func (e *BoardingPassExtractor) Extract(ctx context.Context, imagePath, email string, models []string, callback ProgressCallback) (*cosmosdb.BoardingPass, error) {
+	ctx, span := tracer.Start(ctx, "ai.Extract", trace.WithAttributes(attribute.StringSlice("ai.models", models)))
+	flight, err := e.extractWithFallback(ctx, imagePath, email, models, callback)
+	tracing.End(span, err)
+	return flight, err
+}
+
+// extractWithFallback implements Extract
+func (e *BoardingPassExtractor) extractWithFallback(ctx context.Context, imagePath, email string, models []string, callback ProgressCallback) (*cosmosdb.BoardingPass, error) {
+	if len(models) == 0 {
+		return nil, errors.New("no model to extract with")
+	}
+	requested := models[0]
+	models = e.VisionModels(models)
+	if len(models) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoVisionModel, requested)
+	}
+	if models[0] != requested {
+		log.Printf("[EXTRACT] %s does not support image input, using %s", requested, models[0])
+		data, _ := json.Marshal(SubstitutedEvent{
+			Requested: requested,
+			Model:     models[0],
+			Reason:    fmt.Sprintf("model %s does not support image input", requested),
+		})
+		callback("substituted", string(data))
+	}
+
+	flight, err := e.attempt(ctx, imagePath, email, models[0], callback)
+	if err == nil || len(models) < 2 || ctx.Err() != nil || !isRetryable(err) {
+		return flight, err
+	}
+
+	log.Printf("[EXTRACT] %s failed, retrying with %s: %v", models[0], models[1], err)
+	data, _ := json.Marshal(FallbackEvent{From: models[0], To: models[1], Reason: err.Error()})
+	callback("fallback", string(data))
+	return e.attempt(ctx, imagePath, email, models[1], callback)
+}
+
+// VisionModels returns models without the ones the catalog knows can't read images, keeping their order
+func (e *BoardingPassExtractor) VisionModels(models []string) []string {
+	if e.catalog == nil {
+		return models
+	}
+	usable := make([]string, 0, len(models))
+	for _, model := range models {
+		if vision, known := e.catalog(model); vision || !known {
+			usable = append(usable, model)
+		}
+	}
+	return usable
+}
+
+// isRetryable reports whether an extraction error is worth retrying on another model
+func isRetryable(err error) bool {
+	return errors.Is(err, ErrModelUnavailable) || errors.Is(err, ErrExtractionTimeout)
+}
+
+// attempt runs one extraction session with model and reports the outcome to the attempt observer
+func (e *BoardingPassExtractor) attempt(ctx context.Context, imagePath, email, model string, callback ProgressCallback) (*cosmosdb.BoardingPass, error) {
+	ctx, span := startSessionSpan(ctx, "extract", model)
+	flight, err := e.extract(ctx, imagePath, email, model, callback)
+	tracing.End(span, err)
+	if e.onAttempt != nil {
+		e.onAttempt(model, err)
+	}
+	return flight, err
+}
+
+// extract runs a single extraction session with model
+func (e *BoardingPassExtractor) extract(ctx context.Context, imagePath, email, model string, callback ProgressCallback) (*cosmosdb.BoardingPass, error) {
+	log.Printf("[EXTRACT] Starting | Model: %s | Email: %s | Image: %s", model, redact.Email(email), imagePath)
 
 	// Variable to capture extracted flight
 	var extractedFlight *cosmosdb.BoardingPass
 	var extractMu sync.Mutex
 
+	// Time each phase so step events can report progress
+	timer := newExtractionTimer()
+
+	// Stream individual fields to the UI as the model reports them
+	fields := newFieldStreamer(callback)
+
 	// Define the extraction tool - this captures flight data without saving
-	extractTool := e.createExtractionTool(&extractedFlight, &extractMu, callback)
+	extractTool := e.createExtractionTool(&extractedFlight, &extractMu, fields, timer, callback)
 
 	// Create session with streaming enabled
 	session, err := e.client.CreateSession(&sdk.SessionConfig{
@@ -57,22 +216,36 @@ func (e *BoardingPassExtractor) Extract(ctx context.Context, imagePath, email, m
 		SystemMessage: e.buildSystemMessage(),
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create session: %w", err)
+		return nil, fmt.Errorf("%w: failed to create session: %w", ErrModelUnavailable, err)
 	}
 	defer session.Destroy()
 
-	// Set up error channel for goroutine communication
+	// Set up error channel for goroutine communication; only the first error is kept
 	errCh := make(chan error, 1)
+	fail := func(err error) {
+		select {
+		case errCh <- err:
+		default:
+		}
+	}
 
-	// Set up event handler for streaming
+	// Set up event handler for streaming. A session error ends the attempt so it can be retried.
 	session.On(func(event sdk.SessionEvent) {
-		e.handleSessionEvent(event, callback)
+		if event.Type == "session.error" {
+			msg := "session error"
+			if event.Data.Content != nil {
+				msg = *event.Data.Content
+			}
+			fail(fmt.Errorf("%w: %s", ErrModelUnavailable, msg))
+			return
+		}
+		e.handleSessionEvent(event, fields, timer, callback)
 	})
 
 	// Send the image with extraction prompt in a goroutine
 	go func() {
 		// Step 2: Analyzing image (AI processing starts)
-		callback("step", `{"step":2,"status":"active"}`)
+		callback("step", timer.step(2, "active", ""))
 
 		prompt := fmt.Sprintf("Please analyze this boarding pass image and extract the flight details. The user's email is: %s", email)
 
@@ -86,9 +259,10 @@ func (e *BoardingPassExtractor) Extract(ctx context.Context, imagePath, email, m
 			},
 		})
 		if sendErr != nil {
-			errCh <- fmt.Errorf("failed to send message: %w", sendErr)
+			fail(fmt.Errorf("%w: failed to send message: %w", ErrModelUnavailable, sendErr))
 			return
 		}
+		timer.mark(&timer.sent)
 	}()
 
 	// Wait for session to become idle (using a polling approach since we need to handle context)
@@ -104,12 +278,13 @@ func (e *BoardingPassExtractor) Extract(ctx context.Context, imagePath, email, m
 		case err := <-errCh:
 			return nil, err
 		case <-timeout:
-			return nil, fmt.Errorf("extraction timed out after %v", DefaultExtractionTimeout)
+			return nil, fmt.Errorf("%w after %v", ErrExtractionTimeout, DefaultExtractionTimeout)
 		case <-ticker.C:
 			extractMu.Lock()
 			if extractedFlight != nil {
 				flight := extractedFlight
 				extractMu.Unlock()
+				callback("timing", timer.timing())
 				return flight, nil
 			}
 			extractMu.Unlock()
@@ -119,25 +294,45 @@ func (e *BoardingPassExtractor) Extract(ctx context.Context, imagePath, email, m
 
 // createExtractionTool creates the tool that captures extracted flight data.
 // Note: This tool captures data for user confirmation - it does NOT save to the database.
-func (e *BoardingPassExtractor) createExtractionTool(result **cosmosdb.BoardingPass, mu *sync.Mutex, callback ProgressCallback) sdk.Tool {
-	return sdk.DefineTool("capture_flight_details", "Capture extracted boarding pass data for user confirmation",
+func (e *BoardingPassExtractor) createExtractionTool(result **cosmosdb.BoardingPass, mu *sync.Mutex, fields *fieldStreamer, timer *extractionTimer, callback ProgressCallback) sdk.Tool {
+	return sdk.DefineTool(captureToolName, "Capture extracted boarding pass data for user confirmation",
 		func(params SaveFlightParams, inv sdk.ToolInvocation) (any, error) {
+			// The tool.execution_start event usually marks this first; this covers SDKs that skip it
+			timer.mark(&timer.toolStart)
+
 			// Step 4: Ready for confirmation
-			callback("step", `{"step":4,"status":"active"}`)
+			callback("step", timer.step(4, "active", ""))
 
+			// Coerce dates, times, and codes the model formatted its own way
+			params = normalizeParams(params)
 			flight := &cosmosdb.BoardingPass{
-				Email:         params.Email,
-				FlightNumber:  params.FlightNumber,
-				Airline:       params.Airline,
-				FromAirport:   params.FromAirport,
-				ToAirport:     params.ToAirport,
-				DepartureDate: params.DepartureDate,
-				DepartureTime: params.DepartureTime,
-				Seat:          params.Seat,
-				Gate:          params.Gate,
-				Passenger:     params.Passenger,
+				Email:               params.Email,
+				FlightNumber:        params.FlightNumber,
+				Airline:             normalizeAirline(params.Airline),
+				FromAirport:         params.FromAirport,
+				ToAirport:           params.ToAirport,
+				DepartureDate:       params.DepartureDate,
+				DepartureTime:       params.DepartureTime,
+				Seat:                params.Seat,
+				Gate:                params.Gate,
+				Passenger:           params.Passenger,
+				CabinClass:          params.CabinClass,
+				BoardingGroup:       params.BoardingGroup,
+				ConfirmationCode:    params.ConfirmationCode,
+				FrequentFlyerNumber: params.FrequentFlyerNumber,
 			}
 
+			// Report any fields not already streamed from the assistant text
+			fields.Flush(flight)
+
+			// Warn about airport codes we don't recognize; the user can still fix them before saving
+			for _, unknown := range airports.ValidateRoute(flight.FromAirport, flight.ToAirport) {
+				log.Printf("[EXTRACT] Unrecognized airport code %s=%q", unknown.Field, unknown.Code)
+				data, _ := json.Marshal(unknown)
+				callback("warning", string(data))
+			}
+
+			timer.mark(&timer.captured)
 			mu.Lock()
 			*result = flight
 			mu.Unlock()
@@ -151,6 +346,12 @@ func (e *BoardingPassExtractor) createExtractionTool(result **cosmosdb.BoardingP
 
 // buildSystemMessage returns the system message configuration for the extraction session
 func (e *BoardingPassExtractor) buildSystemMessage() *sdk.SystemMessageConfig {
+	if e.systemPrompt != "" {
+		return &sdk.SystemMessageConfig{
+			Mode:    "replace",
+			Content: e.systemPrompt,
+		}
+	}
 	return &sdk.SystemMessageConfig{
 		Mode: "replace",
 		Content: `You are a boarding pass analyzer. When given an image of a boarding pass:
@@ -165,31 +366,39 @@ func (e *BoardingPassExtractor) buildSystemMessage() *sdk.SystemMessageConfig {
    - Seat number
    - Gate number
    - Passenger name
+   - Cabin class (e.g., "Economy", "Main Cabin", "Business")
+   - Boarding group or zone (e.g., "Group 3", "Zone B")
+   - Booking reference / confirmation code (PNR, e.g., "ABC123")
+   - Frequent flyer number (e.g., "UA 1234567")
 
-2. Once you have extracted the information, call the capture_flight_details tool with ALL the extracted data.
+2. As you read each field, write it on its own line as "fieldName: value" using these exact names:
+   flightNumber, airline, fromAirport, toAirport, departureDate, departureTime, seat, gate, passenger,
+   cabinClass, boardingGroup, confirmationCode, frequentFlyerNumber
+
+3. Once you have extracted the information, call the capture_flight_details tool with ALL the extracted data.
    Use the provided email address for the email field.
 
-3. If any field is not visible or unclear, use an empty string for that field.
+4. If any field is not visible or unclear, use an empty string for that field.
 
 Be thorough and extract only what is clearly visible on the boarding pass.`,
 	}
 }
 
 // handleSessionEvent processes session events and forwards relevant ones to the callback
-func (e *BoardingPassExtractor) handleSessionEvent(event sdk.SessionEvent, callback ProgressCallback) {
+func (e *BoardingPassExtractor) handleSessionEvent(event sdk.SessionEvent, fields *fieldStreamer, timer *extractionTimer, callback ProgressCallback) {
 	switch event.Type {
 	case "assistant.message_delta":
-		// Skip delta events - don't flood UI with AI thinking text
+		// Don't flood UI with AI thinking text - only surface recognized "field: value" lines
+		if event.Data.DeltaContent != nil {
+			fields.Write(*event.Data.DeltaContent)
+		}
 	case "tool.execution_start":
 		// Step 3: Extracting details - include tool name for educational display
 		toolName := "tool"
 		if event.Data.ToolName != nil {
 			toolName = *event.Data.ToolName
 		}
-		callback("step", fmt.Sprintf(`{"step":3,"status":"active","detail":"Tool: %s"}`, toolName))
-	case "session.error":
-		if event.Data.Content != nil {
-			callback("error", *event.Data.Content)
-		}
+		timer.mark(&timer.toolStart)
+		callback("step", timer.step(3, "active", "Tool: "+toolName))
 	}
 }
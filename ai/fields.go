@@ -0,0 +1,107 @@
+package ai
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/abhirockzz/flight-log-app/cosmosdb"
+)
+
+// streamedFields lists the boarding pass fields reported as incremental "field" events
+var streamedFields = []string{
+	"flightNumber", "airline", "fromAirport", "toAirport",
+	"departureDate", "departureTime", "seat", "gate", "passenger",
+	"cabinClass", "boardingGroup", "confirmationCode", "frequentFlyerNumber",
+}
+
+// fieldStreamer parses "fieldName: value" lines out of streamed assistant text and
+// emits a "field" event for each boarding pass field as soon as it is seen.
+type fieldStreamer struct {
+	mu       sync.Mutex
+	buf      strings.Builder
+	sent     map[string]string
+	callback ProgressCallback
+}
+
+// newFieldStreamer creates a field streamer that reports via callback
+func newFieldStreamer(callback ProgressCallback) *fieldStreamer {
+	return &fieldStreamer{
+		sent:     make(map[string]string),
+		callback: callback,
+	}
+}
+
+// Write consumes a chunk of assistant text, emitting events for each complete line
+func (f *fieldStreamer) Write(delta string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.buf.WriteString(delta)
+	text := f.buf.String()
+
+	// Only process complete lines; keep the trailing partial line buffered
+	idx := strings.LastIndex(text, "\n")
+	if idx == -1 {
+		return
+	}
+	f.buf.Reset()
+	f.buf.WriteString(text[idx+1:])
+
+	for _, line := range strings.Split(text[:idx], "\n") {
+		f.parseLine(line)
+	}
+}
+
+// Flush emits the final value of every field from the captured flight,
+// covering fields the model did not list before calling the tool
+func (f *fieldStreamer) Flush(flight *cosmosdb.BoardingPass) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	values := map[string]string{
+		"flightNumber":        flight.FlightNumber,
+		"airline":             flight.Airline,
+		"fromAirport":         flight.FromAirport,
+		"toAirport":           flight.ToAirport,
+		"departureDate":       flight.DepartureDate,
+		"departureTime":       flight.DepartureTime,
+		"seat":                flight.Seat,
+		"gate":                flight.Gate,
+		"passenger":           flight.Passenger,
+		"cabinClass":          flight.CabinClass,
+		"boardingGroup":       flight.BoardingGroup,
+		"confirmationCode":    flight.ConfirmationCode,
+		"frequentFlyerNumber": flight.FrequentFlyerNumber,
+	}
+	for _, name := range streamedFields {
+		f.emit(name, values[name])
+	}
+}
+
+// parseLine extracts a "fieldName: value" pair from a line, tolerating list markers
+func (f *fieldStreamer) parseLine(line string) {
+	line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "-*•"))
+	name, value, ok := strings.Cut(line, ":")
+	if !ok {
+		return
+	}
+	name = strings.TrimSpace(name)
+	for _, canonical := range streamedFields {
+		if strings.EqualFold(canonical, name) {
+			f.emit(canonical, strings.TrimSpace(value))
+			return
+		}
+	}
+}
+
+// emit sends a field event unless the same value was already sent. Caller must hold f.mu.
+func (f *fieldStreamer) emit(name, value string) {
+	if value == "" || f.sent[name] == value {
+		return
+	}
+	f.sent[name] = value
+
+	data, _ := json.Marshal(map[string]string{"field": name, "value": value})
+	f.callback("field", string(data))
+}
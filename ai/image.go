@@ -0,0 +1,157 @@
+package ai
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// exifOrientationTag is the TIFF tag holding the EXIF orientation (1-8)
+const exifOrientationTag = 0x0112
+
+// NormalizeImage rotates a JPEG upright according to its EXIF orientation, since phone photos
+// are often stored sideways with only a flag saying how to display them.
+// It returns the path of a normalized copy next to the original, or path itself when no change
+// is needed (non-JPEG files, no EXIF, or already upright). Callers should remove a returned
+// path that differs from the input once they are done with it. On error, path is returned
+// alongside the error so callers can fall back to the original.
+func NormalizeImage(path string) (string, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext != ".jpg" && ext != ".jpeg" {
+		return path, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return path, err
+	}
+
+	orientation := jpegOrientation(data)
+	if orientation <= 1 || orientation > 8 {
+		return path, nil
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return path, err
+	}
+
+	// Name the copy after the original so it shares its prefix (e.g. for temp file cleanup)
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	out, err := os.CreateTemp(filepath.Dir(path), base+"-normalized-*"+ext)
+	if err != nil {
+		return path, err
+	}
+	if err := jpeg.Encode(out, applyOrientation(img, orientation), &jpeg.Options{Quality: 95}); err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return path, err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(out.Name())
+		return path, err
+	}
+	return out.Name(), nil
+}
+
+// jpegOrientation returns the EXIF orientation of a JPEG, or 0 if it has none
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 0
+	}
+
+	// Walk the marker segments until the APP1 (Exif) segment or the start of image data
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return 0
+		}
+		marker := data[pos+1]
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if marker == 0xDA || length < 2 || pos+2+length > len(data) {
+			return 0
+		}
+		segment := data[pos+4 : pos+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+		pos += 2 + length
+	}
+	return 0
+}
+
+// tiffOrientation reads the orientation tag from IFD0 of a TIFF-formatted EXIF block
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 0
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 0
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+		if order.Uint16(tiff[entry:]) == exifOrientationTag {
+			return int(order.Uint16(tiff[entry+8:]))
+		}
+	}
+	return 0
+}
+
+// applyOrientation returns img transformed so that EXIF orientation becomes 1 (upright)
+func applyOrientation(img image.Image, orientation int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	// Orientations 5-8 swap width and height
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // mirrored horizontally
+				sx, sy = w-1-x, y
+			case 3: // rotated 180°
+				sx, sy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				sx, sy = x, h-1-y
+			case 5: // transposed
+				sx, sy = y, x
+			case 6: // needs 90° clockwise rotation
+				sx, sy = y, h-1-x
+			case 7: // transversed
+				sx, sy = w-1-y, h-1-x
+			case 8: // needs 90° counter-clockwise rotation
+				sx, sy = w-1-y, x
+			default:
+				sx, sy = x, y
+			}
+			dst.Set(x, y, img.At(b.Min.X+sx, b.Min.Y+sy))
+		}
+	}
+	return dst
+}
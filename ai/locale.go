@@ -0,0 +1,52 @@
+package ai
+
+import "strings"
+
+const (
+	// monthFirstLayout is the US style and the default for unknown locales
+	monthFirstLayout = "Jan 2, 2006"
+
+	// dayFirstLayout is used by most of the world
+	dayFirstLayout = "2 Jan 2006"
+
+	// yearFirstLayout is used where dates are written largest unit first
+	yearFirstLayout = "2006-01-02"
+)
+
+// monthFirstRegions write the month before the day
+var monthFirstRegions = map[string]bool{"US": true, "PH": true, "FM": true, "MH": true, "PW": true}
+
+// yearFirstLanguages write the year first regardless of region
+var yearFirstLanguages = map[string]bool{"zh": true, "ja": true, "ko": true, "hu": true, "lt": true, "mn": true, "sv": true}
+
+// DateLayout returns the Go time layout for dates in chat answers for a BCP 47 locale
+// such as "en-GB" or "de". Empty or malformed locales get the US layout.
+func DateLayout(locale string) string {
+	lang, rest, _ := strings.Cut(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"), "-")
+	lang = strings.ToLower(lang)
+	if len(lang) < 2 || len(lang) > 3 {
+		return monthFirstLayout
+	}
+
+	// The region is the first two-letter or three-digit subtag after the language (script subtags are four letters)
+	var region string
+	for _, subtag := range strings.Split(rest, "-") {
+		if len(subtag) == 2 || (len(subtag) == 3 && subtag[0] >= '0' && subtag[0] <= '9') {
+			region = strings.ToUpper(subtag)
+			break
+		}
+	}
+
+	switch {
+	case yearFirstLanguages[lang]:
+		return yearFirstLayout
+	case lang == "fr" && region == "CA":
+		return yearFirstLayout
+	case region != "" && monthFirstRegions[region]:
+		return monthFirstLayout
+	case lang == "en" && region == "":
+		// Bare "en" is most often a US browser
+		return monthFirstLayout
+	}
+	return dayFirstLayout
+}
@@ -0,0 +1,117 @@
+package ai
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/abhirockzz/flight-log-app/airports"
+	"github.com/abhirockzz/flight-log-app/cosmosdb"
+)
+
+// dateLayouts are the date formats models commonly return despite the prompt asking for YYYY-MM-DD.
+// Month and day names match case-insensitively. Slash dates other than year-first are left
+// alone since 01/02 is ambiguous between US and European order.
+var dateLayouts = []string{
+	"2006-01-02",
+	"2006/01/02",
+	"2006.01.02",
+	"Jan 2 2006",
+	"Jan 2, 2006",
+	"January 2 2006",
+	"January 2, 2006",
+	"2 Jan 2006",
+	"2 January 2006",
+	"02Jan2006",
+	"02Jan06",
+	"Mon Jan 2 2006",
+	"Mon, Jan 2, 2006",
+	"Monday, January 2, 2006",
+}
+
+// timeLayouts are the time formats coerced to HH:MM
+var timeLayouts = []string{
+	"15:04",
+	"15:04:05",
+	"15.04",
+	"1504",
+	"3:04 PM",
+	"3:04PM",
+	"3 PM",
+	"3PM",
+}
+
+// normalizeParams cleans up captured extraction params: dates become YYYY-MM-DD, times HH:MM,
+// airport codes and confirmation codes are uppercased (recognized ICAO airport codes become IATA), and flight numbers are trimmed with single spaces. Values that
+// can't be parsed are kept (trimmed) so the user can fix them before saving.
+func normalizeParams(p SaveFlightParams) SaveFlightParams {
+	p.FlightNumber = strings.ToUpper(strings.Join(strings.Fields(p.FlightNumber), " "))
+	p.FromAirport = airports.ToIATA(strings.ToUpper(strings.TrimSpace(p.FromAirport)))
+	p.ToAirport = airports.ToIATA(strings.ToUpper(strings.TrimSpace(p.ToAirport)))
+	p.DepartureDate = normalizeDate(p.DepartureDate)
+	p.DepartureTime = normalizeTime(p.DepartureTime)
+	p.Seat = strings.TrimSpace(p.Seat)
+	p.Gate = strings.TrimSpace(p.Gate)
+	p.Passenger = strings.TrimSpace(p.Passenger)
+	p.ConfirmationCode = strings.ToUpper(strings.TrimSpace(p.ConfirmationCode))
+	p.FrequentFlyerNumber = strings.TrimSpace(p.FrequentFlyerNumber)
+	return p
+}
+
+// NormalizeFlight applies the same clean-up as extraction to a stored flight: the fields covered by
+// normalizeParams, the canonical airline name, seat row and column, and tags. It reports whether
+// anything changed, so flights saved before a normalization rule existed can be migrated.
+func NormalizeFlight(f *cosmosdb.BoardingPass) bool {
+	before := *f
+	before.Tags = append([]string(nil), f.Tags...)
+
+	p := normalizeParams(SaveFlightParams{
+		FlightNumber:        f.FlightNumber,
+		FromAirport:         f.FromAirport,
+		ToAirport:           f.ToAirport,
+		DepartureDate:       f.DepartureDate,
+		DepartureTime:       f.DepartureTime,
+		Seat:                f.Seat,
+		Gate:                f.Gate,
+		Passenger:           f.Passenger,
+		ConfirmationCode:    f.ConfirmationCode,
+		FrequentFlyerNumber: f.FrequentFlyerNumber,
+	})
+	f.FlightNumber = p.FlightNumber
+	f.Airline = normalizeAirline(f.Airline)
+	f.FromAirport = p.FromAirport
+	f.ToAirport = p.ToAirport
+	f.DepartureDate = p.DepartureDate
+	f.DepartureTime = p.DepartureTime
+	f.Seat = p.Seat
+	f.Gate = p.Gate
+	f.Passenger = p.Passenger
+	f.ConfirmationCode = p.ConfirmationCode
+	f.FrequentFlyerNumber = p.FrequentFlyerNumber
+	f.DeriveSeatFields()
+	f.NormalizeTags()
+
+	return !reflect.DeepEqual(before, *f)
+}
+
+// normalizeDate converts a date in one of dateLayouts to YYYY-MM-DD
+func normalizeDate(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format("2006-01-02")
+		}
+	}
+	return s
+}
+
+// normalizeTime converts a time in one of timeLayouts to 24-hour HH:MM
+func normalizeTime(s string) string {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format("15:04")
+		}
+	}
+	return s
+}
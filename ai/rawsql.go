@@ -0,0 +1,31 @@
+package ai
+
+import "regexp"
+
+// rawSQLRefusal is the answer to a message that is itself SQL, matching the reply the system prompt asks for
+const rawSQLRefusal = "I can't run SQL queries directly. Please describe what you're looking for, like 'show me my flights to New York' or 'how many flights did I take last month?'"
+
+// rawSQLPatterns match messages written as SQL rather than a question. They are deliberately narrow
+// so questions such as "delete my cancelled LAX flight" or "select my last trip" still reach the model.
+var rawSQLPatterns = []*regexp.Regexp{
+	// SELECT with a SQL projection: SELECT *, SELECT VALUE, SELECT TOP 5, SELECT c.flightNumber, ...
+	regexp.MustCompile(`(?i)^\s*SELECT\s+(\*|VALUE\b|TOP\s+\d|DISTINCT\b|COUNT\s*\(|[a-z_]\w*\.\w+)`),
+	// Statements that change data or schema
+	regexp.MustCompile(`(?i)^\s*(INSERT\s+INTO|UPSERT\s+INTO|DELETE\s+FROM|UPDATE\s+\w+\s+SET|REPLACE\s+INTO|MERGE\s+INTO|TRUNCATE\s+TABLE|(DROP|CREATE|ALTER)\s+(TABLE|DATABASE|CONTAINER|INDEX|VIEW))\b`),
+	// The container alias anywhere, e.g. "list flights FROM c WHERE c.email = '...'"
+	regexp.MustCompile(`(?i)\bFROM\s+c\s*(\bWHERE\b|\bJOIN\b|\bORDER\s+BY\b|\bGROUP\s+BY\b|\bOFFSET\b|;|$)`),
+	// A predicate on a document field, e.g. "show flights where c.airline = 'United'"
+	regexp.MustCompile(`(?i)\bWHERE\s+c\.\w+\s*(=|!=|<>|<|>|\bIN\b|\bLIKE\b)`),
+}
+
+// looksLikeSQL reports whether a chat message is a SQL statement (or embeds one) rather than a
+// question in natural language. Such messages are refused before the model sees them, since the
+// prompt's instruction to refuse them can be talked around.
+func looksLikeSQL(message string) bool {
+	for _, p := range rawSQLPatterns {
+		if p.MatchString(message) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package ai
+
+import "testing"
+
+func TestLooksLikeSQL(t *testing.T) {
+	tests := []struct {
+		message string
+		want    bool
+	}{
+		// SQL statements
+		{"SELECT * FROM c", true},
+		{"select value count(1) from c", true},
+		{"SELECT TOP 5 c.flightNumber FROM c", true},
+		{"SELECT DISTINCT c.airline FROM c", true},
+		{"SELECT COUNT(1) FROM c", true},
+		{"  SELECT c.flightNumber, c.airline FROM c", true},
+		{"DELETE FROM c WHERE c.airline = 'United'", true},
+		{"UPDATE flights SET airline = 'Delta'", true},
+		{"INSERT INTO c VALUES (1)", true},
+		{"DROP TABLE flights", true},
+		{"CREATE CONTAINER flights", true},
+		{"truncate table flights", true},
+		{"list my flights FROM c WHERE c.email = 'bob@example.com'", true},
+		{"show flights where c.airline = 'United'", true},
+		{"everything FROM c;", true},
+
+		// Questions in plain English
+		{"delete my cancelled LAX flight", false},
+		{"select my last trip", false},
+		{"show me my flights to New York", false},
+		{"how many flights did I take last month?", false},
+		{"update the seat on my Delta flight to 12A", false},
+		{"which flights did I take from Chicago where the delay was long?", false},
+		{"drop the flight to Denver", false},
+		{"create a summary of my trips", false},
+	}
+
+	for _, tt := range tests {
+		if got := looksLikeSQL(tt.message); got != tt.want {
+			t.Errorf("looksLikeSQL(%q) = %v, want %v", tt.message, got, tt.want)
+		}
+	}
+}
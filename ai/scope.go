@@ -0,0 +1,63 @@
+package ai
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// bracketEmailPattern matches c["email"] / c['email'], which is normalized to c.email before checking
+	bracketEmailPattern = regexp.MustCompile(`(?i)\bc\s*\[\s*["']email["']\s*\]`)
+
+	// emailEqualsPattern matches c.email = '<literal>' in either order, capturing the literal
+	emailEqualsPattern = regexp.MustCompile(`(?i)\bc\.email\s*=\s*'((?:[^'\\]|\\.)*)'|'((?:[^'\\]|\\.)*)'\s*=\s*c\.email\b`)
+
+	// emailMisusePattern matches ways of filtering on c.email other than equality: IN lists,
+	// inequalities, LIKE, and function calls such as CONTAINS(c.email, ...)
+	emailMisusePattern = regexp.MustCompile(`(?i)\bc\.email\s*(?:NOT\s+)?IN\s*\(|\bc\.email\s*(?:!=|<>|<|>)|\bc\.email\s+(?:NOT\s+)?LIKE\b|\w\s*\(\s*c\.email\b|,\s*c\.email\s*\)`)
+)
+
+// checkQueryScope verifies that an AI-generated query targets only the caller's partition.
+// ExecuteRawQuery always passes the caller's email as the partition key, so a query naming a
+// different email or filtering on the email with IN, LIKE, and so on would either fail or
+// silently return partial results, and one without the partition predicate may fan out.
+func checkQueryScope(query, email string) error {
+	query = bracketEmailPattern.ReplaceAllString(query, "c.email")
+
+	if loc := emailMisusePattern.FindStringIndex(query); loc != nil {
+		return fmt.Errorf("%w: c.email may only be compared with '=' to the user's email, found %q", ErrQueryScope, query[loc[0]:loc[1]])
+	}
+
+	matches := emailEqualsPattern.FindAllStringSubmatch(query, -1)
+	if len(matches) == 0 {
+		return fmt.Errorf("%w: query must filter on c.email = '%s'", ErrQueryScope, escapeLiteral(email))
+	}
+	for _, m := range matches {
+		literal := m[1] + m[2] // Only one of the groups participates
+		if !strings.EqualFold(unescapeLiteral(literal), email) {
+			return fmt.Errorf("%w: query filters on another user's email %q", ErrQueryScope, literal)
+		}
+	}
+	return nil
+}
+
+// escapeLiteral backslash-escapes quotes and backslashes so s can be placed inside a SQL string literal
+func escapeLiteral(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s)
+}
+
+// unescapeLiteral removes backslash escapes from a SQL string literal
+func unescapeLiteral(s string) string {
+	var b strings.Builder
+	escaped := false
+	for _, r := range s {
+		if r == '\\' && !escaped {
+			escaped = true
+			continue
+		}
+		escaped = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,53 @@
+package ai
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckQueryScope(t *testing.T) {
+	const email = "alice@example.com"
+
+	tests := []struct {
+		name  string
+		query string
+		ok    bool
+	}{
+		{"own email", "SELECT * FROM c WHERE c.email = 'alice@example.com'", true},
+		{"own email reversed and mixed case", "SELECT * FROM c WHERE 'Alice@Example.com' = c.email AND c.airline = 'United'", true},
+		{"own email with bracket access", `SELECT * FROM c WHERE c["email"] = 'alice@example.com'`, true},
+		{"another user's email", "SELECT * FROM c WHERE c.email = 'bob@example.com'", false},
+		{"another user's email alongside own", "SELECT * FROM c WHERE c.email = 'alice@example.com' OR c.email = 'bob@example.com'", false},
+		{"another user's email with bracket access", `SELECT * FROM c WHERE c['email'] = 'bob@example.com'`, false},
+		{"IN list", "SELECT * FROM c WHERE c.email IN ('alice@example.com', 'bob@example.com')", false},
+		{"NOT IN list", "SELECT * FROM c WHERE c.email NOT IN ('bob@example.com')", false},
+		{"bracket access IN list", `SELECT * FROM c WHERE c["email"] IN ('alice@example.com')`, false},
+		{"CONTAINS", "SELECT * FROM c WHERE CONTAINS(c.email, 'example.com')", false},
+		{"CONTAINS with bracket access", `SELECT * FROM c WHERE CONTAINS(c["email"], 'example.com')`, false},
+		{"LIKE", "SELECT * FROM c WHERE c.email LIKE '%@example.com'", false},
+		{"inequality", "SELECT * FROM c WHERE c.email != 'alice@example.com'", false},
+		{"no email predicate", "SELECT * FROM c WHERE c.airline = 'United'", false},
+		{"no WHERE clause", "SELECT VALUE COUNT(1) FROM c", false},
+		{"escaped quote in another user's email", `SELECT * FROM c WHERE c.email = 'o\'brien@example.com'`, false},
+		{"escaped quote hiding a second predicate", `SELECT * FROM c WHERE c.email = 'alice@example.com\' OR c.email = \'bob@example.com'`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkQueryScope(tt.query, email)
+			if tt.ok && err != nil {
+				t.Fatalf("checkQueryScope(%q) = %v, want nil", tt.query, err)
+			}
+			if !tt.ok && !errors.Is(err, ErrQueryScope) {
+				t.Fatalf("checkQueryScope(%q) = %v, want ErrQueryScope", tt.query, err)
+			}
+		})
+	}
+
+	t.Run("own email with escaped quote", func(t *testing.T) {
+		query := `SELECT * FROM c WHERE c.email = 'o\'brien@example.com'`
+		if err := checkQueryScope(query, "o'brien@example.com"); err != nil {
+			t.Fatalf("checkQueryScope(%q) = %v, want nil", query, err)
+		}
+	})
+}
@@ -0,0 +1,124 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/abhirockzz/flight-log-app/cosmosdb"
+	sdk "github.com/github/copilot-sdk/go"
+)
+
+// Metrics supported by the flight_stats tool
+const (
+	statCount           = "count"
+	statByAirline       = "byAirline"
+	statByMonth         = "byMonth"
+	statTopDestinations = "topDestinations"
+)
+
+// defaultTopDestinations is how many airports topDestinations returns when no limit is given
+const defaultTopDestinations = 5
+
+// statBucket is one group in a flight_stats result
+type statBucket struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+// createStatsTool creates the flight_stats tool, which answers counting and grouping questions
+// in Go so the model doesn't have to write aggregate SQL
+func (h *ChatHandler) createStatsTool(ctx context.Context, email string, callback ProgressCallback) sdk.Tool {
+	return sdk.DefineTool("flight_stats",
+		`Compute statistics over the user's flights (trashed flights are excluded). Prefer this over query_flights for counting and grouping questions.
+Metrics:
+- count: total number of flights
+- byAirline: number of flights per airline, most flown first
+- byMonth: number of flights per departure month (YYYY-MM), oldest first
+- topDestinations: most visited arrival airports, most visited first (use limit to change how many, default 5)`,
+		func(params FlightStatsParams, inv sdk.ToolInvocation) (any, error) {
+			log.Printf("[CHAT] AI requested flight stats: %s", params.Metric)
+			callback("stats", params.Metric)
+
+			switch params.Metric {
+			case statCount, statByAirline, statByMonth, statTopDestinations:
+			default:
+				return nil, fmt.Errorf("unknown metric %q (expected count, byAirline, byMonth, or topDestinations)", params.Metric)
+			}
+
+			flights, err := h.store.ListFlights(ctx, email)
+			if err != nil {
+				log.Printf("[CHAT] Flight stats failed: %v", err)
+				return nil, fmt.Errorf("%w: %w", ErrQueryExecution, err)
+			}
+			return computeFlightStats(flights, params.Metric, params.Limit), nil
+		})
+}
+
+// computeFlightStats computes a flight_stats metric; the metric must already be validated
+func computeFlightStats(flights []cosmosdb.BoardingPass, metric string, limit int) map[string]any {
+	switch metric {
+	case statByAirline:
+		return map[string]any{"metric": metric, "groups": countBy(flights, func(f cosmosdb.BoardingPass) string { return f.Airline }, byCountDesc)}
+	case statByMonth:
+		timeline, _ := cosmosdb.FlightsByPeriod(flights, cosmosdb.BucketMonth)
+		groups := make([]statBucket, 0, len(timeline.Periods))
+		for _, p := range timeline.Periods {
+			groups = append(groups, statBucket{Key: p.Period, Count: p.Count})
+		}
+		return map[string]any{"metric": metric, "groups": groups}
+	case statTopDestinations:
+		if limit <= 0 {
+			limit = defaultTopDestinations
+		}
+		groups := countBy(flights, func(f cosmosdb.BoardingPass) string { return f.ToAirport }, byCountDesc)
+		return map[string]any{"metric": metric, "groups": groups[:min(limit, len(groups))]}
+	default:
+		return map[string]any{"metric": metric, "count": len(flights)}
+	}
+}
+
+// countBy groups flights by key, skipping empty keys, and sorts the groups with less
+func countBy(flights []cosmosdb.BoardingPass, key func(cosmosdb.BoardingPass) string, less func(a, b statBucket) bool) []statBucket {
+	counts := make(map[string]int)
+	for _, f := range flights {
+		if k := key(f); k != "" {
+			counts[k]++
+		}
+	}
+
+	buckets := make([]statBucket, 0, len(counts))
+	for k, n := range counts {
+		buckets = append(buckets, statBucket{Key: k, Count: n})
+	}
+	sort.Slice(buckets, func(i, j int) bool { return less(buckets[i], buckets[j]) })
+	return buckets
+}
+
+// byCountDesc orders buckets by count, highest first, breaking ties alphabetically
+func byCountDesc(a, b statBucket) bool {
+	if a.Count != b.Count {
+		return a.Count > b.Count
+	}
+	return a.Key < b.Key
+}
+
+// createPeriodTool creates the flights_by_period tool, which counts flights per month or year
+// so questions like "which month did I fly the most?" don't depend on model-written date SQL
+func (h *ChatHandler) createPeriodTool(ctx context.Context, email string, callback ProgressCallback) sdk.Tool {
+	return sdk.DefineTool("flights_by_period",
+		`Count the user's flights per departure month (YYYY-MM) or year (YYYY), oldest first. Use this for questions like "which month did I fly the most?" or "how many flights per year?".
+Flights with malformed departure dates are reported in an "unparseable" count instead of a period.`,
+		func(params FlightsByPeriodParams, inv sdk.ToolInvocation) (any, error) {
+			log.Printf("[CHAT] AI requested flights by period: %s", params.Bucket)
+			callback("stats", "flights_by_period:"+params.Bucket)
+
+			flights, err := h.store.ListFlights(ctx, email)
+			if err != nil {
+				log.Printf("[CHAT] Flights by period failed: %v", err)
+				return nil, fmt.Errorf("%w: %w", ErrQueryExecution, err)
+			}
+			return cosmosdb.FlightsByPeriod(flights, params.Bucket)
+		})
+}
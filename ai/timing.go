@@ -0,0 +1,89 @@
+package ai
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// StepEvent is the payload of an extraction "step" event
+type StepEvent struct {
+	Step      int    `json:"step"`
+	Status    string `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	ElapsedMs int64  `json:"elapsedMs"` // Time since extraction started
+}
+
+// String encodes the event as JSON for the progress callback
+func (e StepEvent) String() string {
+	data, _ := json.Marshal(e)
+	return string(data)
+}
+
+// ExtractionTiming is the payload of the final "timing" event, breaking an extraction into phases
+type ExtractionTiming struct {
+	UploadMs   int64 `json:"uploadMs"`   // Session creation and sending the image
+	AnalysisMs int64 `json:"analysisMs"` // Model reading the image until it calls the capture tool
+	CaptureMs  int64 `json:"captureMs"`  // Capture tool execution
+	TotalMs    int64 `json:"totalMs"`
+}
+
+// extractionTimer records when each extraction phase ends, relative to the start of Extract
+type extractionTimer struct {
+	mu        sync.Mutex
+	start     time.Time
+	sent      time.Time // Image sent to the model
+	toolStart time.Time // Capture tool invoked
+	captured  time.Time // Flight details captured
+}
+
+// newExtractionTimer starts timing an extraction
+func newExtractionTimer() *extractionTimer {
+	return &extractionTimer{start: time.Now()}
+}
+
+// step builds a step event stamped with the elapsed time
+func (t *extractionTimer) step(step int, status, detail string) string {
+	return StepEvent{
+		Step:      step,
+		Status:    status,
+		Detail:    detail,
+		ElapsedMs: time.Since(t.start).Milliseconds(),
+	}.String()
+}
+
+// mark records the first time a phase boundary is reached
+func (t *extractionTimer) mark(at *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if at.IsZero() {
+		*at = time.Now()
+	}
+}
+
+// timing returns the phase breakdown. Phases that were never marked end where the next one does.
+func (t *extractionTimer) timing() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	end := t.captured
+	if end.IsZero() {
+		end = time.Now()
+	}
+	toolStart := t.toolStart
+	if toolStart.IsZero() {
+		toolStart = end
+	}
+	sent := t.sent
+	if sent.IsZero() {
+		sent = toolStart
+	}
+
+	data, _ := json.Marshal(ExtractionTiming{
+		UploadMs:   sent.Sub(t.start).Milliseconds(),
+		AnalysisMs: toolStart.Sub(sent).Milliseconds(),
+		CaptureMs:  end.Sub(toolStart).Milliseconds(),
+		TotalMs:    end.Sub(t.start).Milliseconds(),
+	})
+	return string(data)
+}
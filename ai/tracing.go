@@ -0,0 +1,23 @@
+package ai
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates spans for extraction, chat, and Copilot sessions; it is a no-op unless
+// tracing.Setup installed a provider
+var tracer = otel.Tracer("github.com/abhirockzz/flight-log-app/ai")
+
+// startSessionSpan starts a client span covering one Copilot session, from creation to destroy
+func startSessionSpan(ctx context.Context, purpose, model string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "copilot.session",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("ai.session.purpose", purpose),
+			attribute.String("ai.model", model),
+		))
+}
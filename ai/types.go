@@ -2,16 +2,20 @@ package ai
 
 // SaveFlightParams defines the parameters for the save_flight tool
 type SaveFlightParams struct {
-	Email         string `json:"email" jsonschema:"User email (partition key)"`
-	FlightNumber  string `json:"flightNumber" jsonschema:"Flight number, e.g. UA 1234"`
-	Airline       string `json:"airline" jsonschema:"Airline name"`
-	FromAirport   string `json:"fromAirport" jsonschema:"Departure airport code"`
-	ToAirport     string `json:"toAirport" jsonschema:"Arrival airport code"`
-	DepartureDate string `json:"departureDate" jsonschema:"Date in YYYY-MM-DD format"`
-	DepartureTime string `json:"departureTime" jsonschema:"Time in HH:MM format"`
-	Seat          string `json:"seat" jsonschema:"Seat number"`
-	Gate          string `json:"gate" jsonschema:"Gate number"`
-	Passenger     string `json:"passenger" jsonschema:"Passenger name"`
+	Email               string `json:"email" jsonschema:"User email (partition key)"`
+	FlightNumber        string `json:"flightNumber" jsonschema:"Flight number, e.g. UA 1234"`
+	Airline             string `json:"airline" jsonschema:"Airline name"`
+	FromAirport         string `json:"fromAirport" jsonschema:"Departure airport code"`
+	ToAirport           string `json:"toAirport" jsonschema:"Arrival airport code"`
+	DepartureDate       string `json:"departureDate" jsonschema:"Date in YYYY-MM-DD format"`
+	DepartureTime       string `json:"departureTime" jsonschema:"Time in HH:MM format"`
+	Seat                string `json:"seat" jsonschema:"Seat number"`
+	Gate                string `json:"gate" jsonschema:"Gate number"`
+	Passenger           string `json:"passenger" jsonschema:"Passenger name"`
+	CabinClass          string `json:"cabinClass,omitempty" jsonschema:"Cabin or fare class as printed, e.g. Economy, Main Cabin, Business"`
+	BoardingGroup       string `json:"boardingGroup,omitempty" jsonschema:"Boarding group or zone as printed, e.g. Group 3, Zone B"`
+	ConfirmationCode    string `json:"confirmationCode,omitempty" jsonschema:"Booking reference or confirmation code (PNR), usually 6 letters and digits, e.g. ABC123"`
+	FrequentFlyerNumber string `json:"frequentFlyerNumber,omitempty" jsonschema:"Frequent flyer or loyalty number as printed, e.g. UA 1234567"`
 }
 
 // QueryFlightsParams defines the parameters for the AI-generated SQL query tool
@@ -19,5 +23,40 @@ type QueryFlightsParams struct {
 	Query string `json:"query" jsonschema:"The complete Cosmos DB SQL query to execute. Must include c.email filter."`
 }
 
+// GetFlightParams defines the parameters for the get_flight tool
+type GetFlightParams struct {
+	FlightID string `json:"flightId" jsonschema:"The id of the flight to fetch, taken from a previous query_flights result"`
+}
+
+// DeleteFlightParams defines the parameters for the delete_flight tool
+type DeleteFlightParams struct {
+	FlightID string `json:"flightId" jsonschema:"The id of the flight to delete, taken from a previous query_flights result"`
+}
+
+// UpdateFlightParams defines the parameters for the update_flight tool
+type UpdateFlightParams struct {
+	FlightID string            `json:"flightId" jsonschema:"The id of the flight to change, taken from a previous query_flights result"`
+	Changes  map[string]string `json:"changes" jsonschema:"New values keyed by field name, e.g. {\"seat\": \"14C\"}. Fields: flightNumber, airline, fromAirport, toAirport, departureDate (YYYY-MM-DD), departureTime (HH:MM), seat, gate, passenger, cabinClass, boardingGroup, confirmationCode, frequentFlyerNumber, note"`
+}
+
 // ProgressCallback is called with extraction progress updates
 type ProgressCallback func(eventType, data string)
+
+// FlightStatsParams defines the parameters for the flight_stats tool
+type FlightStatsParams struct {
+	Metric string `json:"metric" jsonschema:"One of: count, byAirline, byMonth, topDestinations"`
+	Limit  int    `json:"limit,omitempty" jsonschema:"For topDestinations, how many airports to return (default 5)"`
+}
+
+// ChartParams defines the parameters for the show_chart tool
+type ChartParams struct {
+	Metric string `json:"metric" jsonschema:"One of: byMonth, byYear, byAirline, topDestinations"`
+	After  string `json:"after,omitempty" jsonschema:"Only flights departing on or after this date (YYYY-MM-DD)"`
+	Before string `json:"before,omitempty" jsonschema:"Only flights departing on or before this date (YYYY-MM-DD)"`
+	Limit  int    `json:"limit,omitempty" jsonschema:"For topDestinations, how many airports to include (default 5)"`
+}
+
+// FlightsByPeriodParams defines the parameters for the flights_by_period tool
+type FlightsByPeriodParams struct {
+	Bucket string `json:"bucket" jsonschema:"Period to group by: month or year"`
+}
@@ -0,0 +1,94 @@
+// Package airports looks up IATA airport codes in an embedded dataset.
+package airports
+
+import (
+	_ "embed"
+	"encoding/json"
+	"log"
+	"strings"
+)
+
+// Airport is an entry in the embedded IATA dataset
+type Airport struct {
+	Code    string  `json:"code"`    // 3-letter IATA code, e.g. "SFO"
+	ICAO    string  `json:"icao"`    // 4-letter ICAO code, e.g. "KSFO"
+	Name    string  `json:"name"`    // e.g. "San Francisco International Airport"
+	City    string  `json:"city"`    // e.g. "San Francisco"
+	Country string  `json:"country"` // ISO 3166-1 alpha-2 code, e.g. "US"
+	Lat     float64 `json:"lat"`     // Latitude in degrees
+	Lon     float64 `json:"lon"`     // Longitude in degrees
+	TZ      string  `json:"tz"`      // IANA timezone, e.g. "America/Los_Angeles"
+}
+
+// airportsJSON lists known airports. Add airports here; no code changes are needed.
+//
+//go:embed airports.json
+var airportsJSON []byte
+
+// byCode indexes the dataset by uppercase IATA code
+var byCode = loadAirports(airportsJSON)
+
+// byICAO maps uppercase ICAO codes to IATA codes
+var byICAO = indexICAO(byCode)
+
+// loadAirports builds the code lookup table from the embedded JSON
+func loadAirports(data []byte) map[string]Airport {
+	var list []Airport
+	if err := json.Unmarshal(data, &list); err != nil {
+		log.Printf("[AIRPORTS] Failed to parse airport dataset: %v", err)
+		return map[string]Airport{}
+	}
+
+	index := make(map[string]Airport, len(list))
+	for _, a := range list {
+		index[strings.ToUpper(a.Code)] = a
+	}
+	return index
+}
+
+// indexICAO builds the ICAO to IATA lookup table from the airports that have an ICAO code
+func indexICAO(airports map[string]Airport) map[string]string {
+	index := make(map[string]string, len(airports))
+	for code, a := range airports {
+		if a.ICAO != "" {
+			index[strings.ToUpper(a.ICAO)] = code
+		}
+	}
+	return index
+}
+
+// ToIATA returns the IATA code for a recognized ICAO code (case-insensitive), e.g. "KSFO" -> "SFO".
+// Some boarding passes print ICAO codes; normalizing them keeps queries on 3-letter codes consistent.
+// Any other code, including unknown ones, is returned unchanged.
+func ToIATA(code string) string {
+	if iata, ok := byICAO[strings.ToUpper(strings.TrimSpace(code))]; ok {
+		return iata
+	}
+	return code
+}
+
+// Lookup returns the airport for an IATA code (case-insensitive)
+func Lookup(code string) (Airport, bool) {
+	a, ok := byCode[strings.ToUpper(strings.TrimSpace(code))]
+	return a, ok
+}
+
+// UnknownCode is an airport code on a flight that is not in the dataset
+type UnknownCode struct {
+	Field string `json:"field"` // "fromAirport" or "toAirport"
+	Code  string `json:"code"`
+}
+
+// ValidateRoute reports which of a flight's airport codes are unrecognized. Empty codes are skipped.
+func ValidateRoute(fromAirport, toAirport string) []UnknownCode {
+	unknown := []UnknownCode{}
+	for _, f := range []UnknownCode{{"fromAirport", fromAirport}, {"toAirport", toAirport}} {
+		if f.Code == "" {
+			continue
+		}
+		if _, ok := Lookup(f.Code); !ok {
+			unknown = append(unknown, f)
+		}
+	}
+	return unknown
+}
@@ -0,0 +1,49 @@
+package airports
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	// earthRadiusMiles is the mean radius of the Earth in statute miles
+	earthRadiusMiles = 3958.8
+
+	// cruiseSpeedMph and taxiOverhead give a rough gate-to-gate flight time from distance
+	cruiseSpeedMph = 500
+	taxiOverhead   = 30 * time.Minute
+)
+
+// Distance returns the great-circle distance in statute miles between two airports,
+// using the haversine formula. It fails if either code is not in the dataset.
+func Distance(from, to string) (float64, error) {
+	a, ok := Lookup(from)
+	if !ok {
+		return 0, fmt.Errorf("unknown airport code %q", from)
+	}
+	b, ok := Lookup(to)
+	if !ok {
+		return 0, fmt.Errorf("unknown airport code %q", to)
+	}
+
+	lat1, lat2 := radians(a.Lat), radians(b.Lat)
+	dLat := lat2 - lat1
+	dLon := radians(b.Lon - a.Lon)
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusMiles * math.Asin(math.Sqrt(h)), nil
+}
+
+// EstimatedFlightTime roughly estimates gate-to-gate time for a flight of the given distance
+func EstimatedFlightTime(miles float64) time.Duration {
+	if miles <= 0 {
+		return 0
+	}
+	return taxiOverhead + time.Duration(miles/cruiseSpeedMph*float64(time.Hour))
+}
+
+// radians converts degrees to radians
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
@@ -0,0 +1,45 @@
+package airports
+
+import (
+	"time"
+	_ "time/tzdata" // The runtime image has no zoneinfo database
+)
+
+// Earliest and latest UTC offsets in use, which bound how far any airport's local date can be from UTC
+const (
+	minUTCOffset = -12 * time.Hour
+	maxUTCOffset = 14 * time.Hour
+)
+
+// Location returns the IANA timezone of an airport, or false if the code or its timezone is unknown
+func Location(code string) (*time.Location, bool) {
+	a, ok := Lookup(code)
+	if !ok || a.TZ == "" {
+		return nil, false
+	}
+	loc, err := time.LoadLocation(a.TZ)
+	if err != nil {
+		return nil, false
+	}
+	return loc, true
+}
+
+// LocalTime returns now in the airport's timezone, or in fallback if the airport's timezone is unknown
+func LocalTime(code string, now time.Time, fallback *time.Location) time.Time {
+	if loc, ok := Location(code); ok {
+		return now.In(loc)
+	}
+	return now.In(fallback)
+}
+
+// LocalDate returns the date (YYYY-MM-DD) at the airport at instant now, using fallback for unknown airports
+func LocalDate(code string, now time.Time, fallback *time.Location) string {
+	return LocalTime(code, now, fallback).Format("2006-01-02")
+}
+
+// DateRange returns the earliest and latest local dates (YYYY-MM-DD) anywhere on Earth at instant now.
+// Every airport's local date falls within this range.
+func DateRange(now time.Time) (earliest, latest string) {
+	now = now.UTC()
+	return now.Add(minUTCOffset).Format("2006-01-02"), now.Add(maxUTCOffset).Format("2006-01-02")
+}
@@ -0,0 +1,107 @@
+// Package blobstore keeps boarding pass images in an Azure Blob Storage container,
+// so flight records in Cosmos DB only carry the image's URL.
+package blobstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+)
+
+// createTimeout bounds container creation at startup
+const createTimeout = 30 * time.Second
+
+// Client stores images as blobs in one container
+type Client struct {
+	client    *azblob.Client
+	container string
+	baseURL   string // Container URL with a trailing slash; every stored image URL starts with it
+}
+
+// NewClient connects with a storage account connection string and creates the container if it
+// doesn't exist. The container is created private; images are served through the app, not directly.
+func NewClient(connectionString, container string) (*Client, error) {
+	client, err := azblob.NewClientFromConnectionString(connectionString, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create blob client: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), createTimeout)
+	defer cancel()
+	if _, err := client.CreateContainer(ctx, container, nil); err != nil && !bloberror.HasCode(err, bloberror.ContainerAlreadyExists) {
+		return nil, fmt.Errorf("failed to create container %s: %w", container, err)
+	}
+
+	return &Client{
+		client:    client,
+		container: container,
+		baseURL:   strings.TrimSuffix(client.URL(), "/") + "/" + container + "/",
+	}, nil
+}
+
+// Upload stores the file at path as the blob name and returns its URL
+func (c *Client) Upload(ctx context.Context, name, path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	// Sniff the type so downloads are served with it
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(file, head)
+	contentType := http.DetectContentType(head[:n])
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	_, err = c.client.UploadFile(ctx, c.container, name, file, &azblob.UploadFileOptions{
+		HTTPHeaders: &blob.HTTPHeaders{BlobContentType: &contentType},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to upload blob %s: %w", name, err)
+	}
+	return c.baseURL + name, nil
+}
+
+// Name returns the blob name of an image URL returned by Upload, or false if the URL
+// points outside this client's container
+func (c *Client) Name(url string) (string, bool) {
+	name, ok := strings.CutPrefix(url, c.baseURL)
+	return name, ok && name != ""
+}
+
+// Open returns the content and content type of the named blob. The error wraps fs.ErrNotExist
+// if there is no such blob.
+func (c *Client) Open(ctx context.Context, name string) (io.ReadCloser, string, error) {
+	resp, err := c.client.DownloadStream(ctx, c.container, name, nil)
+	if bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return nil, "", fmt.Errorf("blob %s: %w", name, fs.ErrNotExist)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to download blob %s: %w", name, err)
+	}
+	var contentType string
+	if resp.ContentType != nil {
+		contentType = *resp.ContentType
+	}
+	return resp.Body, contentType, nil
+}
+
+// Delete removes the named blob; deleting a missing blob is not an error
+func (c *Client) Delete(ctx context.Context, name string) error {
+	_, err := c.client.DeleteBlob(ctx, c.container, name, nil)
+	if err != nil && !bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return fmt.Errorf("failed to delete blob %s: %w", name, err)
+	}
+	return nil
+}
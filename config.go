@@ -0,0 +1,237 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azcosmos"
+	"github.com/abhirockzz/flight-log-app/cosmosdb"
+	"github.com/abhirockzz/flight-log-app/server"
+)
+
+// Config is the app's whole configuration. loadConfig fills it from the environment once at startup;
+// apart from the standard OTEL_* tracing variables (read by the tracing package), nothing else reads
+// the environment. Each field notes the variable it comes from.
+type Config struct {
+	Port      string // PORT (default 8080)
+	LogLevel  string // LOG_LEVEL: debug, info, warn, or error; see logLevels
+	LogRedact bool   // LOG_REDACT (default true); false logs emails, messages, and queries in full for local debugging
+	Store     string // STORE: "cosmos" (the default) or "memory"
+
+	CosmosEndpoint       string                    // COSMOS_ENDPOINT, required unless STORE=memory
+	CosmosDatabase       string                    // COSMOS_DATABASE (default flightlog)
+	CosmosContainer      string                    // COSMOS_CONTAINER (default boardingPasses)
+	CosmosConsistency    azcosmos.ConsistencyLevel // COSMOS_CONSISTENCY; empty uses the account default
+	UseEmulator          bool                      // USE_EMULATOR=true
+	EmulatorCert         string                    // COSMOS_EMULATOR_CERT, required by EMULATOR_TLS=true
+	CreateIfNotExists    bool                      // CREATE_IF_NOT_EXISTS=true
+	BlobConnection       string                    // BLOB_CONNECTION_STRING; empty discards boarding pass images
+	BlobContainer        string                    // BLOB_CONTAINER (default boarding-passes)
+	CopilotCLIURL        string                    // COPILOT_CLI_URL; empty spawns the CLI as a child process
+	AuthMode             string                    // AUTH_MODE: "header" (the default) or "jwt"
+	AuthJWTSecret        string                    // AUTH_JWT_SECRET, required by AUTH_MODE=jwt
+	AuthJWTEmailClaim    string                    // AUTH_JWT_EMAIL_CLAIM (default email)
+	OrphanSweep          time.Duration             // ORPHAN_SWEEP_INTERVAL (default 15m)
+	OrphanMaxAge         time.Duration             // ORPHAN_MAX_AGE (default 1h)
+	ExtractionPromptFile string                    // EXTRACTION_PROMPT_FILE; its contents become Server.ExtractionPrompt
+	AviationStackKey     string                    // AVIATIONSTACK_API_KEY; empty skips checking extractions against airline schedules
+
+	// Server settings: UPLOAD_DIR, ALLOWED_ORIGINS, ADMIN_TOKEN, IMAGE_URL_ALLOWED_HOSTS,
+	// EXTRACTION_FALLBACK_MODELS, MODELS_REFRESH_INTERVAL, DISABLE_COMPRESSION, METRICS_ENABLED,
+	// CHAT_CACHE_SIZE, CHAT_CACHE_TTL, CHAT_MAX_QUERIES, CHAT_ALLOWED_SQL, APP_TIMEZONE, MODEL_FAILURE_THRESHOLD,
+	// MODEL_FAILURE_WINDOW, MODEL_COOLDOWN, TRASH_RETENTION_DAYS, SAMPLE_DEFAULT_COUNT,
+	// SAMPLE_MAX_COUNT, SAMPLE_DATA_TTL, and SEAT_LAYOUT
+	Server server.Config
+}
+
+// loadConfig reads Config from the environment, applying defaults for unset variables.
+// Invalid or missing required values are all reported together in the returned error.
+func loadConfig() (Config, error) {
+	e := &envReader{}
+	cfg := Config{
+		Port:                 e.string("PORT", "8080"),
+		LogLevel:             os.Getenv("LOG_LEVEL"),
+		Store:                e.string("STORE", "cosmos"),
+		CosmosEndpoint:       os.Getenv("COSMOS_ENDPOINT"),
+		CosmosDatabase:       e.string("COSMOS_DATABASE", defaultDatabase),
+		CosmosContainer:      e.string("COSMOS_CONTAINER", defaultContainer),
+		UseEmulator:          e.bool("USE_EMULATOR"),
+		CreateIfNotExists:    e.bool("CREATE_IF_NOT_EXISTS"),
+		BlobConnection:       os.Getenv("BLOB_CONNECTION_STRING"),
+		BlobContainer:        e.string("BLOB_CONTAINER", defaultBlobContainer),
+		CopilotCLIURL:        os.Getenv("COPILOT_CLI_URL"),
+		AuthMode:             e.string("AUTH_MODE", "header"),
+		AuthJWTSecret:        os.Getenv("AUTH_JWT_SECRET"),
+		AuthJWTEmailClaim:    os.Getenv("AUTH_JWT_EMAIL_CLAIM"),
+		OrphanSweep:          e.duration("ORPHAN_SWEEP_INTERVAL", defaultOrphanSweepInterval, false),
+		OrphanMaxAge:         e.duration("ORPHAN_MAX_AGE", defaultOrphanMaxAge, false),
+		ExtractionPromptFile: os.Getenv("EXTRACTION_PROMPT_FILE"),
+		AviationStackKey:     os.Getenv("AVIATIONSTACK_API_KEY"),
+	}
+
+	cfg.LogRedact = os.Getenv("LOG_REDACT") == "" || e.bool("LOG_REDACT")
+
+	switch cfg.Store {
+	case "cosmos":
+		if cfg.CosmosEndpoint == "" {
+			e.fail("COSMOS_ENDPOINT is required (or set STORE=memory to run without Cosmos DB)")
+		}
+	case "memory":
+	default:
+		e.fail("invalid STORE %q (expected cosmos or memory)", cfg.Store)
+	}
+	if v := os.Getenv("COSMOS_CONSISTENCY"); v != "" {
+		level, err := cosmosdb.ParseConsistencyLevel(v)
+		if err != nil {
+			e.fail("invalid COSMOS_CONSISTENCY: %v", err)
+		}
+		cfg.CosmosConsistency = level
+	}
+	if e.bool("EMULATOR_TLS") {
+		cfg.EmulatorCert = os.Getenv("COSMOS_EMULATOR_CERT")
+		if cfg.EmulatorCert == "" {
+			e.fail("EMULATOR_TLS=true requires COSMOS_EMULATOR_CERT (path to the emulator's PEM certificate)")
+		}
+		if cfg.UseEmulator && !strings.HasPrefix(cfg.CosmosEndpoint, "https://") {
+			e.fail("EMULATOR_TLS=true requires an https:// COSMOS_ENDPOINT")
+		}
+	}
+	switch cfg.AuthMode {
+	case "header":
+	case "jwt":
+		if cfg.AuthJWTSecret == "" {
+			e.fail("AUTH_MODE=jwt requires AUTH_JWT_SECRET")
+		}
+	default:
+		e.fail("invalid AUTH_MODE %q (expected header or jwt)", cfg.AuthMode)
+	}
+
+	s := server.DefaultConfig()
+	s.UploadDir = e.string("UPLOAD_DIR", s.UploadDir)
+	if origins := e.list("ALLOWED_ORIGINS"); origins != nil {
+		s.AllowedOrigins = origins
+	}
+	s.AdminToken = os.Getenv("ADMIN_TOKEN")
+	s.ImageURLAllowedHosts = e.list("IMAGE_URL_ALLOWED_HOSTS")
+	s.FallbackModels = e.list("EXTRACTION_FALLBACK_MODELS")
+	s.ModelsRefresh = e.duration("MODELS_REFRESH_INTERVAL", s.ModelsRefresh, false)
+	s.DisableCompression = e.bool("DISABLE_COMPRESSION")
+	s.MetricsEnabled = e.bool("METRICS_ENABLED")
+	s.ChatCacheSize = e.int("CHAT_CACHE_SIZE", s.ChatCacheSize, 0)
+	s.ChatCacheTTL = e.duration("CHAT_CACHE_TTL", s.ChatCacheTTL, true)
+	s.ChatMaxQueries = e.int("CHAT_MAX_QUERIES", s.ChatMaxQueries, 1)
+	s.ChatAllowedSQL = e.list("CHAT_ALLOWED_SQL")
+	if tz := os.Getenv("APP_TIMEZONE"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			e.fail("invalid APP_TIMEZONE %q: %v", tz, err)
+		} else {
+			s.Location = loc
+		}
+	}
+	s.ModelFailureThreshold = e.int("MODEL_FAILURE_THRESHOLD", s.ModelFailureThreshold, 0)
+	s.ModelFailureWindow = e.duration("MODEL_FAILURE_WINDOW", s.ModelFailureWindow, false)
+	s.ModelCooldown = e.duration("MODEL_COOLDOWN", s.ModelCooldown, false)
+	s.TrashRetention = time.Duration(e.int("TRASH_RETENTION_DAYS", 0, 0)) * 24 * time.Hour
+	s.SampleDefaultCount = e.int("SAMPLE_DEFAULT_COUNT", s.SampleDefaultCount, 1)
+	s.SampleMaxCount = e.int("SAMPLE_MAX_COUNT", s.SampleMaxCount, 1)
+	s.SampleDataTTL = e.duration("SAMPLE_DATA_TTL", s.SampleDataTTL, true)
+	if v := os.Getenv("SEAT_LAYOUT"); v != "" {
+		layout, err := cosmosdb.ParseSeatLayout(v)
+		if err != nil {
+			e.fail("invalid SEAT_LAYOUT: %v", err)
+		} else {
+			s.SeatLayout = layout
+		}
+	}
+	if cfg.ExtractionPromptFile != "" {
+		prompt, err := os.ReadFile(cfg.ExtractionPromptFile)
+		if err != nil {
+			e.fail("failed to read EXTRACTION_PROMPT_FILE: %v", err)
+		}
+		s.ExtractionPrompt = string(prompt)
+	}
+	cfg.Server = s
+
+	return cfg, errors.Join(e.errs...)
+}
+
+// envReader reads typed environment variables, collecting an error for each invalid value
+// so they can all be reported at once
+type envReader struct {
+	errs []error
+}
+
+// fail records a configuration error
+func (e *envReader) fail(format string, args ...any) {
+	e.errs = append(e.errs, fmt.Errorf(format, args...))
+}
+
+// string returns the variable name, or def when it is unset
+func (e *envReader) string(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
+
+// bool reports whether the variable name is "true"; anything but "", "true", or "false" is an error
+func (e *envReader) bool(name string) bool {
+	switch v := os.Getenv(name); v {
+	case "", "false":
+		return false
+	case "true":
+		return true
+	default:
+		e.fail("invalid %s %q (expected true or false)", name, v)
+		return false
+	}
+}
+
+// int returns the variable name as an integer of at least least, or def when it is unset
+func (e *envReader) int(name string, def, least int) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < least {
+		e.fail("invalid %s %q (expected an integer of at least %d)", name, v, least)
+		return def
+	}
+	return n
+}
+
+// duration returns the variable name as a duration such as "30m", or def when it is unset.
+// Zero is accepted only when allowZero is set; negative durations never are.
+func (e *envReader) duration(name string, def time.Duration, allowZero bool) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 || (d == 0 && !allowZero) {
+		want := "a positive duration such as 30m"
+		if allowZero {
+			want = "a duration such as 30m, or 0"
+		}
+		e.fail("invalid %s %q (expected %s)", name, v, want)
+		return def
+	}
+	return d
+}
+
+// list returns the comma-separated variable name with blank entries dropped, or nil when it is unset
+func (e *envReader) list(name string) []string {
+	var items []string
+	for _, item := range strings.Split(os.Getenv(name), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
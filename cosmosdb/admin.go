@@ -0,0 +1,88 @@
+package cosmosdb
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azcosmos"
+	"github.com/abhirockzz/flight-log-app/metrics"
+)
+
+// topRoutesLimit is the number of routes returned in GlobalStats
+const topRoutesLimit = 10
+
+// RouteCount is the number of flights on a route
+type RouteCount struct {
+	FromAirport string `json:"fromAirport"`
+	ToAirport   string `json:"toAirport"`
+	Count       int    `json:"count"`
+}
+
+// GlobalStats are aggregate statistics across all users
+type GlobalStats struct {
+	TotalFlights  int          `json:"totalFlights"`
+	UniqueUsers   int          `json:"uniqueUsers"`
+	TopRoutes     []RouteCount `json:"topRoutes"`
+	RequestCharge float32      `json:"requestCharge"` // Total RU consumed computing the stats
+}
+
+// AdminStats computes statistics across ALL users.
+//
+// This is intentionally a cross-partition (fan-out) query: it reads every partition and costs
+// far more RU than the per-user methods. Only expose it to operators. Aggregation happens here
+// rather than in SQL because the gateway can't serve cross-partition GROUP BY queries.
+func (c *Client) AdminStats(ctx context.Context) (*GlobalStats, error) {
+	// SELECT * (rather than a projection) so the configured partition value extractor always has its field
+	query := "SELECT * FROM c WHERE " + notDeletedFilter
+
+	// An empty partition key fans the query out across all partitions
+	pager := c.container.NewQueryItemsPager(query, azcosmos.NewPartitionKey(), nil)
+
+	start := time.Now()
+	ctx, span := startQuerySpan(ctx, "admin_stats", query)
+	stats := &GlobalStats{}
+	users := make(map[string]bool)
+	routes := make(map[[2]string]int)
+
+	for pager.More() {
+		response, err := pager.NextPage(ctx)
+		if err != nil {
+			metrics.ObserveCosmos("admin_stats", start, stats.RequestCharge, err)
+			endQuerySpan(span, stats.RequestCharge, err)
+			return nil, fmt.Errorf("cross-partition query failed: %w", err)
+		}
+		stats.RequestCharge += response.RequestCharge
+
+		for _, flight := range decodeFlights(response.Items) {
+			stats.TotalFlights++
+			users[c.partitionValue(&flight)] = true
+			routes[[2]string{flight.FromAirport, flight.ToAirport}]++
+		}
+	}
+
+	metrics.ObserveCosmos("admin_stats", start, stats.RequestCharge, nil)
+	endQuerySpan(span, stats.RequestCharge, nil)
+
+	stats.UniqueUsers = len(users)
+	stats.TopRoutes = make([]RouteCount, 0, len(routes))
+	for route, count := range routes {
+		stats.TopRoutes = append(stats.TopRoutes, RouteCount{
+			FromAirport: route[0],
+			ToAirport:   route[1],
+			Count:       count,
+		})
+	}
+	sort.Slice(stats.TopRoutes, func(i, j int) bool {
+		if stats.TopRoutes[i].Count != stats.TopRoutes[j].Count {
+			return stats.TopRoutes[i].Count > stats.TopRoutes[j].Count
+		}
+		return stats.TopRoutes[i].FromAirport+stats.TopRoutes[i].ToAirport < stats.TopRoutes[j].FromAirport+stats.TopRoutes[j].ToAirport
+	})
+	if len(stats.TopRoutes) > topRoutesLimit {
+		stats.TopRoutes = stats.TopRoutes[:topRoutesLimit]
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,196 @@
+package cosmosdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azcosmos"
+	"github.com/abhirockzz/flight-log-app/metrics"
+	"github.com/google/uuid"
+)
+
+// maxBatchOperations is the Cosmos DB limit on operations in one transactional batch
+const maxBatchOperations = 100
+
+// SaveFlightsBatch inserts flights that share a partition key using transactional batches of up
+// to 100 items. Flights matching an existing flight (same flight number and departure date) are
+// skipped with ErrDuplicateFlight. A batch is atomic, so when one item fails it is dropped and
+// the rest of its batch is retried. The returned error joins the per-item failures; the flights
+// that were saved are returned either way.
+func (c *Client) SaveFlightsBatch(ctx context.Context, flights []*BoardingPass) ([]BoardingPass, error) {
+	if len(flights) == 0 {
+		return []BoardingPass{}, nil
+	}
+
+	pkValue := c.partitionValue(flights[0])
+	if pkValue == "" {
+		return nil, fmt.Errorf("%s is required", c.partitionField)
+	}
+	for _, flight := range flights {
+		if c.partitionValue(flight) != pkValue {
+			return nil, fmt.Errorf("all flights in a batch must share the same %s", c.partitionField)
+		}
+	}
+
+	existing, err := c.existingFlightKeys(ctx, pkValue)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	pending := make([]*BoardingPass, 0, len(flights))
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, flight := range flights {
+		key := flight.FlightNumber + "|" + flight.DepartureDate
+		if existing[key] {
+			errs = append(errs, itemError(flight, ErrDuplicateFlight))
+			continue
+		}
+		existing[key] = true
+
+		if flight.ID == "" {
+			flight.ID = uuid.New().String()
+		}
+		if flight.CreatedAt == "" {
+			flight.CreatedAt = now
+		}
+		flight.Deleted = false
+		flight.DeletedAt = ""
+		flight.DeriveSeatFields()
+		flight.NormalizeTags()
+		pending = append(pending, flight)
+	}
+
+	saved := make([]BoardingPass, 0, len(pending))
+	pk := azcosmos.NewPartitionKeyString(pkValue)
+	for start := 0; start < len(pending); start += maxBatchOperations {
+		chunk := pending[start:min(start+maxBatchOperations, len(pending))]
+		chunkSaved, chunkErrs, err := c.executeCreateBatch(ctx, pk, chunk)
+		saved = append(saved, chunkSaved...)
+		errs = append(errs, chunkErrs...)
+		if err != nil {
+			return saved, errors.Join(append(errs, err)...)
+		}
+	}
+
+	return saved, errors.Join(errs...)
+}
+
+// executeCreateBatch creates the flights in a single transactional batch. If the batch fails,
+// the item that caused it is reported and removed and the remaining items are retried.
+// The returned error is set only when the batch request itself fails.
+func (c *Client) executeCreateBatch(ctx context.Context, pk azcosmos.PartitionKey, flights []*BoardingPass) ([]BoardingPass, []error, error) {
+	var errs []error
+	for len(flights) > 0 {
+		batch := c.container.NewTransactionalBatch(pk)
+		for _, flight := range flights {
+			data, err := json.Marshal(flight)
+			if err != nil {
+				return nil, errs, err
+			}
+			batch.CreateItem(data, nil)
+		}
+
+		start := time.Now()
+		response, err := c.container.ExecuteTransactionalBatch(ctx, batch, nil)
+		metrics.ObserveCosmos("batch_create", start, response.RequestCharge, err)
+		if err != nil {
+			return nil, errs, err
+		}
+
+		if response.Success {
+			saved := make([]BoardingPass, len(flights))
+			for i, flight := range flights {
+				if i < len(response.OperationResults) {
+					flight.ETag = string(response.OperationResults[i].ETag)
+				}
+				saved[i] = *flight
+			}
+			return saved, errs, nil
+		}
+
+		// The first result that isn't a failed dependency caused the rollback
+		failed := -1
+		for i, result := range response.OperationResults {
+			if result.StatusCode != http.StatusFailedDependency {
+				failed = i
+				break
+			}
+		}
+		if failed < 0 || failed >= len(flights) {
+			return nil, errs, errors.New("transactional batch failed without a cause")
+		}
+
+		status := response.OperationResults[failed].StatusCode
+		var cause error
+		if status == http.StatusConflict {
+			cause = ErrDuplicateFlight
+		} else {
+			cause = fmt.Errorf("create failed with status %d", status)
+		}
+		errs = append(errs, itemError(flights[failed], cause))
+		flights = append(flights[:failed:failed], flights[failed+1:]...)
+	}
+	return []BoardingPass{}, errs, nil
+}
+
+// existingFlightKeys returns "flightNumber|departureDate" keys for the partition's active flights
+func (c *Client) existingFlightKeys(ctx context.Context, pkValue string) (map[string]bool, error) {
+	pk := azcosmos.NewPartitionKeyString(pkValue)
+
+	query := "SELECT c.flightNumber, c.departureDate FROM c WHERE " + c.partitionFilter() + " AND " + notDeletedFilter
+	queryOptions := &azcosmos.QueryOptions{
+		QueryParameters: []azcosmos.QueryParameter{
+			{Name: "@pk", Value: pkValue},
+		},
+	}
+
+	items, err := c.queryItems(ctx, "find_existing", query, pk, queryOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]bool, len(items))
+	for _, flight := range decodeFlights(items) {
+		keys[flight.FlightNumber+"|"+flight.DepartureDate] = true
+	}
+	return keys, nil
+}
+
+// ItemError is a batch failure for a single flight. SaveFlightsBatch joins one per failed
+// item, so callers can match failures to their input flights with ItemErrors.
+type ItemError struct {
+	Flight *BoardingPass // The flight as passed to SaveFlightsBatch
+	Err    error
+}
+
+func (e *ItemError) Error() string {
+	return fmt.Sprintf("flight %s on %s: %v", e.Flight.FlightNumber, e.Flight.DepartureDate, e.Err)
+}
+
+func (e *ItemError) Unwrap() error { return e.Err }
+
+// itemError describes a batch failure for a single flight
+func itemError(flight *BoardingPass, err error) error {
+	return &ItemError{Flight: flight, Err: err}
+}
+
+// ItemErrors returns the per-item failures joined into an error returned by SaveFlightsBatch
+func ItemErrors(err error) []*ItemError {
+	var items []*ItemError
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			items = append(items, ItemErrors(e)...)
+		}
+		return items
+	}
+	var itemErr *ItemError
+	if errors.As(err, &itemErr) {
+		items = append(items, itemErr)
+	}
+	return items
+}
@@ -0,0 +1,88 @@
+package cosmosdb
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azcosmos"
+)
+
+// Cosmos DB REST headers used by sessionPolicy
+const (
+	headerPartitionKey     = "x-ms-documentdb-partitionkey"
+	headerSessionToken     = "x-ms-session-token"
+	headerConsistencyLevel = "x-ms-consistency-level"
+	headerIsQuery          = "x-ms-documentdb-query"
+)
+
+// WithConsistency overrides the account's default consistency level for this client's requests.
+// Cosmos DB only allows relaxing the default (e.g. a Session account can use Eventual, not Strong).
+//
+// RU implications: Strong and Bounded Staleness reads cost twice the RUs of the other levels,
+// since they read from two replicas. Session, Consistent Prefix, and Eventual reads cost the same;
+// Eventual only saves latency, at the price of lists that may briefly miss a just-saved flight.
+func WithConsistency(level azcosmos.ConsistencyLevel) ClientOption {
+	return func(c *Client) {
+		c.consistency = level
+	}
+}
+
+// ParseConsistencyLevel parses a consistency level name case-insensitively, e.g. "session" or "Eventual"
+func ParseConsistencyLevel(s string) (azcosmos.ConsistencyLevel, error) {
+	for _, level := range azcosmos.ConsistencyLevelValues() {
+		if strings.EqualFold(s, string(level)) {
+			return level, nil
+		}
+	}
+	return "", fmt.Errorf("unknown consistency level %q (expected Strong, BoundedStaleness, Session, ConsistentPrefix, or Eventual)", s)
+}
+
+// sessionPolicy is an azcore pipeline policy that applies the configured consistency level and
+// provides read-your-writes within this process. The Go SDK doesn't track session tokens itself,
+// so the policy remembers the token returned by each write per partition key and sends it on
+// later requests to the same partition; a ListFlights right after SaveFlight then sees the new
+// flight even when it's served by a replica that hasn't caught up otherwise.
+type sessionPolicy struct {
+	consistency azcosmos.ConsistencyLevel // Empty uses the account default
+	tokens      sync.Map                  // Partition key header value → latest write's session token
+}
+
+// Do implements policy.Policy
+func (p *sessionPolicy) Do(req *policy.Request) (*http.Response, error) {
+	raw := req.Raw()
+	if p.consistency != "" && raw.Header.Get(headerConsistencyLevel) == "" {
+		raw.Header.Set(headerConsistencyLevel, string(p.consistency))
+	}
+
+	// Session tokens only mean something under Session consistency (the usual account default)
+	pk := raw.Header.Get(headerPartitionKey)
+	useSession := p.consistency == "" || p.consistency == azcosmos.ConsistencyLevelSession
+	if useSession && pk != "" && raw.Header.Get(headerSessionToken) == "" {
+		if token, ok := p.tokens.Load(pk); ok {
+			raw.Header.Set(headerSessionToken, token.(string))
+		}
+	}
+
+	resp, err := req.Next()
+
+	// Only writes advance the session; queries are POSTs too but carry the query header
+	isWrite := raw.Method != http.MethodGet && raw.Header.Get(headerIsQuery) == ""
+	if err == nil && useSession && pk != "" && isWrite && resp.StatusCode < http.StatusBadRequest {
+		if token := resp.Header.Get(headerSessionToken); token != "" {
+			p.tokens.Store(pk, token)
+		}
+	}
+	return resp, err
+}
+
+// withSessionPolicy adds a sessionPolicy for the client's consistency level to opts, which may be nil
+func (c *Client) withSessionPolicy(opts *azcosmos.ClientOptions) *azcosmos.ClientOptions {
+	if opts == nil {
+		opts = &azcosmos.ClientOptions{}
+	}
+	opts.PerCallPolicies = append(opts.PerCallPolicies, &sessionPolicy{consistency: c.consistency})
+	return opts
+}
@@ -2,16 +2,23 @@ package cosmosdb
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
-	"os"
+	"log/slog"
+	"net/http"
 	"sort"
+	"strings"
 	"time"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/data/azcosmos"
+	"github.com/abhirockzz/flight-log-app/airports"
+	"github.com/abhirockzz/flight-log-app/metrics"
+	"github.com/abhirockzz/flight-log-app/redact"
 	"github.com/google/uuid"
 )
 
@@ -19,75 +26,322 @@ import (
 // See: https://learn.microsoft.com/en-us/azure/cosmos-db/emulator-linux
 const emulatorKey = "C2y6yDjf5/R+ob0N8A7Cgv30VRDJIWEHLM+4QDU5DE2nQ9nDuVTqobD4b8mGGyPMbIZnqyMsEcaGQy67XIw/Jw=="
 
+const (
+	// defaultPartitionField is the default partition key property (user email)
+	defaultPartitionField = "email"
+	// defaultThroughput is the manual throughput used when creating the container
+	defaultThroughput = 400
+	// createTimeout bounds database/container creation at startup
+	createTimeout = 30 * time.Second
+)
+
 // BoardingPass represents a flight extracted from a boarding pass image
 type BoardingPass struct {
-	ID            string `json:"id"`
-	Email         string `json:"email"`
-	FlightNumber  string `json:"flightNumber"`
-	Airline       string `json:"airline"`
-	FromAirport   string `json:"fromAirport"`
-	ToAirport     string `json:"toAirport"`
-	DepartureDate string `json:"departureDate"`
-	DepartureTime string `json:"departureTime"`
-	Seat          string `json:"seat"`
-	Gate          string `json:"gate"`
-	Passenger     string `json:"passenger"`
-	CreatedAt     string `json:"createdAt"`
+	ID                  string   `json:"id"`
+	Email               string   `json:"email"`
+	FlightNumber        string   `json:"flightNumber"`
+	Airline             string   `json:"airline"`
+	FromAirport         string   `json:"fromAirport"`
+	ToAirport           string   `json:"toAirport"`
+	DepartureDate       string   `json:"departureDate"`
+	DepartureTime       string   `json:"departureTime"`
+	Seat                string   `json:"seat"`
+	SeatRow             int      `json:"seatRow,omitempty"`    // Derived from Seat, e.g. 12 for "12A"; 0 if Seat doesn't parse
+	SeatColumn          string   `json:"seatColumn,omitempty"` // Derived from Seat, e.g. "A" for "12A"; empty if Seat doesn't parse
+	Gate                string   `json:"gate"`
+	Passenger           string   `json:"passenger"`
+	CabinClass          string   `json:"cabinClass,omitempty"`          // e.g. "Economy", "Business"; empty on older records
+	BoardingGroup       string   `json:"boardingGroup,omitempty"`       // e.g. "Group 3", "Zone B"; empty on older records
+	ConfirmationCode    string   `json:"confirmationCode,omitempty"`    // Booking reference (PNR), e.g. "ABC123"
+	FrequentFlyerNumber string   `json:"frequentFlyerNumber,omitempty"` // Loyalty number as printed, e.g. "UA 1234567"
+	Tags                []string `json:"tags,omitempty"`                // User labels, e.g. "work"; lowercased by NormalizeTags
+	Note                string   `json:"note,omitempty"`                // Free-form user note, e.g. "aisle was broken"
+	ImageURL            string   `json:"imageUrl,omitempty"`            // Blob URL of the boarding pass image, when image storage is configured
+	CreatedAt           string   `json:"createdAt"`
+	Deleted             bool     `json:"deleted,omitempty"`   // Set when the flight is in the trash
+	DeletedAt           string   `json:"deletedAt,omitempty"` // RFC3339 time the flight was trashed
+	// TTL is the item's time-to-live in seconds. Cosmos DB only honors it when the container has
+	// DefaultTimeToLive enabled (e.g. -1 for "on, no default expiry"); otherwise it is ignored.
+	TTL int `json:"ttl,omitempty"`
+	// ETag is the Cosmos DB _etag of the stored item; pass it to UpdateFlight to detect concurrent edits
+	ETag string `json:"_etag,omitempty"`
+}
+
+// ErrInvalidFlight is returned by BoardingPass.Validate for a flight missing required fields or with malformed values
+var ErrInvalidFlight = errors.New("invalid flight")
+
+// Validate checks that a flight has its identifying fields and that the date and time are well formed
+func (b *BoardingPass) Validate() error {
+	required := []struct{ name, value string }{
+		{"email", b.Email},
+		{"flightNumber", b.FlightNumber},
+		{"fromAirport", b.FromAirport},
+		{"toAirport", b.ToAirport},
+		{"departureDate", b.DepartureDate},
+	}
+	for _, field := range required {
+		if strings.TrimSpace(field.value) == "" {
+			return fmt.Errorf("%w: %s is required", ErrInvalidFlight, field.name)
+		}
+	}
+	if _, err := time.Parse("2006-01-02", b.DepartureDate); err != nil {
+		return fmt.Errorf("%w: departureDate must be a date in YYYY-MM-DD format", ErrInvalidFlight)
+	}
+	if b.DepartureTime != "" {
+		if _, err := time.Parse("15:04", b.DepartureTime); err != nil {
+			return fmt.Errorf("%w: departureTime must be a time in HH:MM format", ErrInvalidFlight)
+		}
+	}
+	return b.ValidateAnnotations()
 }
 
 // Client wraps the Azure Cosmos DB client
 type Client struct {
-	client    *azcosmos.Client
-	container *azcosmos.ContainerClient
+	client         *azcosmos.Client
+	container      *azcosmos.ContainerClient
+	partitionField string                     // Partition key property name, e.g. "email"
+	partitionValue func(*BoardingPass) string // Extracts the partition key value from a flight
+	consistency    azcosmos.ConsistencyLevel  // Overrides the account default when set; see WithConsistency
+	emulator       bool                       // Authenticate with the emulator key; see WithEmulator
+	emulatorCert   string                     // PEM certificate of an HTTPS emulator; empty uses HTTP
+	create         bool                       // Create the database and container on startup; see WithCreateIfNotExists
+}
+
+// ClientOption configures a Client
+type ClientOption func(*Client)
+
+// WithPartitionKey partitions flights on a different property than email.
+// field is the JSON property name used in queries (and /field as the container's partition key path);
+// value extracts that property's value from a flight. Methods that take an email parameter
+// treat it as the partition key value.
+func WithPartitionKey(field string, value func(*BoardingPass) string) ClientOption {
+	return func(c *Client) {
+		c.partitionField = field
+		c.partitionValue = value
+	}
+}
+
+// WithEmulator connects to the Cosmos DB Emulator with its well-known key instead of Azure credentials.
+// With an empty certPath requests use plain HTTP; otherwise they go over HTTPS and trust the
+// emulator's self-signed certificate, read as PEM from certPath.
+func WithEmulator(certPath string) ClientOption {
+	return func(c *Client) {
+		c.emulator = true
+		c.emulatorCert = certPath
+	}
+}
+
+// WithCreateIfNotExists creates the database and container on startup instead of expecting them to exist
+func WithCreateIfNotExists() ClientOption {
+	return func(c *Client) {
+		c.create = true
+	}
+}
+
+// partitionFilter returns the WHERE condition scoping a query to the @pk partition
+func (c *Client) partitionFilter() string {
+	return "c." + c.partitionField + " = @pk"
 }
 
 // NewClient creates a new Cosmos DB client.
-// When USE_EMULATOR=true, uses key-based auth with the well-known emulator key (HTTP only).
+// With WithEmulator, uses key-based auth with the well-known emulator key
+// (HTTP by default, or HTTPS given a certificate; see emulatorClientOptions).
 // Otherwise, uses DefaultAzureCredential for Azure service authentication.
-// Expects the database and container to already exist.
-func NewClient(endpoint, database, container string) (*Client, error) {
+// Expects the database and container to already exist unless WithCreateIfNotExists is given.
+func NewClient(endpoint, database, container string, opts ...ClientOption) (*Client, error) {
+	c := &Client{
+		partitionField: defaultPartitionField,
+		partitionValue: func(f *BoardingPass) string { return f.Email },
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
 	var cosmosClient *azcosmos.Client
 	var err error
 
-	if os.Getenv("USE_EMULATOR") == "true" {
-		// Emulator mode: use well-known key (HTTP only, no TLS)
+	if c.emulator {
+		// Emulator mode: use well-known key (HTTP unless a certificate is given)
 		keyCred, keyErr := azcosmos.NewKeyCredential(emulatorKey)
 		if keyErr != nil {
 			return nil, fmt.Errorf("failed to create key credential: %w", keyErr)
 		}
-		cosmosClient, err = azcosmos.NewClientWithKey(endpoint, keyCred, nil)
+		clientOpts, optsErr := emulatorClientOptions(endpoint, c.emulatorCert)
+		if optsErr != nil {
+			return nil, optsErr
+		}
+		useTLS := clientOpts != nil
+		cosmosClient, err = azcosmos.NewClientWithKey(endpoint, keyCred, c.withSessionPolicy(clientOpts))
 		if err != nil {
 			return nil, fmt.Errorf("failed to create Cosmos client (emulator): %w", err)
 		}
-		log.Println("Using Cosmos DB Emulator (HTTP mode)")
+		if useTLS {
+			log.Println("Using Cosmos DB Emulator (HTTPS mode)")
+		} else {
+			log.Println("Using Cosmos DB Emulator (HTTP mode)")
+		}
 	} else {
 		// Azure mode: use DefaultAzureCredential (supports Azure CLI, managed identity, etc.)
 		cred, credErr := azidentity.NewDefaultAzureCredential(nil)
 		if credErr != nil {
 			return nil, fmt.Errorf("failed to create credential: %w", credErr)
 		}
-		cosmosClient, err = azcosmos.NewClient(endpoint, cred, nil)
+		cosmosClient, err = azcosmos.NewClient(endpoint, cred, c.withSessionPolicy(nil))
 		if err != nil {
 			return nil, fmt.Errorf("failed to create Cosmos client: %w", err)
 		}
 	}
 
-	// Note: Database and container must be pre-created via Azure CLI, Portal, or Emulator Data Explorer
+	// By default, database and container must be pre-created via Azure CLI, Portal, or Emulator Data Explorer.
+	// WithCreateIfNotExists creates them on startup instead.
+	if c.create {
+		ctx, cancel := context.WithTimeout(context.Background(), createTimeout)
+		defer cancel()
+		if err := createIfNotExists(ctx, cosmosClient, database, container, "/"+c.partitionField); err != nil {
+			return nil, err
+		}
+	}
+
 	containerClient, err := cosmosClient.NewContainer(database, container)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get container client: %w", err)
 	}
 
-	return &Client{
-		client:    cosmosClient,
-		container: containerClient,
-	}, nil
+	c.client = cosmosClient
+	c.container = containerClient
+	return c, nil
 }
 
-// SaveFlight saves a boarding pass to Cosmos DB
+// ErrDuplicateFlight is returned by SaveFlight when a flight with the same
+// flight number and departure date already exists for the user
+var ErrDuplicateFlight = errors.New("flight already exists")
+
+// createIfNotExists creates the database and container (partitioned on partitionKeyPath) if they don't exist.
+// It is idempotent: 409 Conflict responses for existing resources are ignored.
+func createIfNotExists(ctx context.Context, cosmosClient *azcosmos.Client, database, container, partitionKeyPath string) error {
+	_, err := cosmosClient.CreateDatabase(ctx, azcosmos.DatabaseProperties{ID: database}, nil)
+	switch {
+	case err == nil:
+		log.Printf("Created Cosmos DB database %q", database)
+	case !isConflict(err):
+		return fmt.Errorf("failed to create database: %w", err)
+	}
+
+	databaseClient, err := cosmosClient.NewDatabase(database)
+	if err != nil {
+		return fmt.Errorf("failed to get database client: %w", err)
+	}
+
+	throughput := azcosmos.NewManualThroughputProperties(defaultThroughput)
+	// Enable TTL with no default expiry so items with a ttl field (e.g. sample data) auto-expire
+	noDefaultTTL := int32(-1)
+	_, err = databaseClient.CreateContainer(ctx, azcosmos.ContainerProperties{
+		ID: container,
+		PartitionKeyDefinition: azcosmos.PartitionKeyDefinition{
+			Paths: []string{partitionKeyPath},
+		},
+		DefaultTimeToLive: &noDefaultTTL,
+	}, &azcosmos.CreateContainerOptions{ThroughputProperties: &throughput})
+	switch {
+	case err == nil:
+		log.Printf("Created Cosmos DB container %q (partition key %s, %d RU/s)", container, partitionKeyPath, defaultThroughput)
+	case !isConflict(err):
+		return fmt.Errorf("failed to create container: %w", err)
+	}
+
+	return nil
+}
+
+// queryItems runs a single-partition query, returning the raw items from all pages.
+// Latency and total RU charge are recorded under the given operation name.
+func (c *Client) queryItems(ctx context.Context, operation, query string, pk azcosmos.PartitionKey, opts *azcosmos.QueryOptions) ([][]byte, error) {
+	start := time.Now()
+	var requestCharge float32
+	ctx, span := startQuerySpan(ctx, operation, query)
+
+	pager := c.container.NewQueryItemsPager(query, pk, opts)
+
+	var items [][]byte
+	pageCount := 0
+	for pager.More() {
+		pageCount++
+		response, err := pager.NextPage(ctx)
+		if err != nil {
+			log.Printf("[COSMOS] %s failed on page %d: %v", operation, pageCount, err)
+			metrics.ObserveCosmos(operation, start, requestCharge, err)
+			endQuerySpan(span, requestCharge, err)
+			return nil, err
+		}
+		requestCharge += response.RequestCharge
+		items = append(items, response.Items...)
+	}
+
+	metrics.ObserveCosmos(operation, start, requestCharge, nil)
+	endQuerySpan(span, requestCharge, nil)
+	return items, nil
+}
+
+// decodeFlights unmarshals query items into boarding passes, skipping items that don't decode
+func decodeFlights(items [][]byte) []BoardingPass {
+	flights := make([]BoardingPass, 0, len(items))
+	for _, item := range items {
+		var flight BoardingPass
+		if err := json.Unmarshal(item, &flight); err != nil {
+			continue
+		}
+		flights = append(flights, flight)
+	}
+	return flights
+}
+
+// SaveFlight saves a boarding pass to Cosmos DB.
+// If the user already has a flight with the same flight number and departure date,
+// the existing record is returned along with ErrDuplicateFlight.
 func (c *Client) SaveFlight(ctx context.Context, flight *BoardingPass) (*BoardingPass, error) {
-	if flight.Email == "" {
-		return nil, errors.New("email is required")
+	if c.partitionValue(flight) == "" {
+		return nil, fmt.Errorf("%s is required", c.partitionField)
+	}
+
+	existing, err := c.FindDuplicate(ctx, flight)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return existing, ErrDuplicateFlight
+	}
+
+	return c.InsertFlight(ctx, flight)
+}
+
+// FindDuplicate looks up an active flight with the same flight number and departure date in the user's partition.
+// It returns nil if there is none.
+func (c *Client) FindDuplicate(ctx context.Context, flight *BoardingPass) (*BoardingPass, error) {
+	pk := azcosmos.NewPartitionKeyString(c.partitionValue(flight))
+
+	query := "SELECT * FROM c WHERE " + c.partitionFilter() + " AND c.flightNumber = @flightNumber AND c.departureDate = @departureDate AND " + notDeletedFilter
+	queryOptions := &azcosmos.QueryOptions{
+		QueryParameters: []azcosmos.QueryParameter{
+			{Name: "@pk", Value: c.partitionValue(flight)},
+			{Name: "@flightNumber", Value: flight.FlightNumber},
+			{Name: "@departureDate", Value: flight.DepartureDate},
+		},
+	}
+
+	items, err := c.queryItems(ctx, "find_duplicate", query, pk, queryOptions)
+	if err != nil {
+		return nil, fmt.Errorf("duplicate check failed: %w", err)
+	}
+
+	if existing := decodeFlights(items); len(existing) > 0 {
+		return &existing[0], nil
+	}
+	return nil, nil
+}
+
+// InsertFlight saves a boarding pass to Cosmos DB without checking for duplicates
+func (c *Client) InsertFlight(ctx context.Context, flight *BoardingPass) (*BoardingPass, error) {
+	if c.partitionValue(flight) == "" {
+		return nil, fmt.Errorf("%s is required", c.partitionField)
 	}
 
 	// Generate ID if not provided
@@ -99,6 +353,11 @@ func (c *Client) SaveFlight(ctx context.Context, flight *BoardingPass) (*Boardin
 	if flight.CreatedAt == "" {
 		flight.CreatedAt = time.Now().UTC().Format(time.RFC3339)
 	}
+	// New flights are never in the trash; only DeleteFlight puts them there
+	flight.Deleted = false
+	flight.DeletedAt = ""
+	flight.DeriveSeatFields()
+	flight.NormalizeTags()
 
 	// Marshal to JSON
 	data, err := json.Marshal(flight)
@@ -107,14 +366,39 @@ func (c *Client) SaveFlight(ctx context.Context, flight *BoardingPass) (*Boardin
 	}
 
 	// Create partition key from email
-	pk := azcosmos.NewPartitionKeyString(flight.Email)
+	pk := azcosmos.NewPartitionKeyString(c.partitionValue(flight))
 
 	// Create item in Cosmos DB
-	_, err = c.container.CreateItem(ctx, pk, data, nil)
+	start := time.Now()
+	response, err := c.container.CreateItem(ctx, pk, data, nil)
+	metrics.ObserveCosmos("create_item", start, response.RequestCharge, err)
+	if isConflict(err) {
+		return c.resolveIDConflict(ctx, flight)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	flight.ETag = string(response.ETag)
+	return flight, nil
+}
+
+// resolveIDConflict handles a create for an ID that already exists. Re-saving a trashed
+// flight (e.g. undoing a delete) replaces it; a live flight is reported as a duplicate.
+func (c *Client) resolveIDConflict(ctx context.Context, flight *BoardingPass) (*BoardingPass, error) {
+	existing, err := c.readFlight(ctx, flight.ID, c.partitionValue(flight))
 	if err != nil {
 		return nil, err
 	}
+	if !existing.Deleted {
+		return existing, ErrDuplicateFlight
+	}
 
+	flight.Deleted = false
+	flight.DeletedAt = ""
+	if err := c.replaceFlight(ctx, "restore", flight, ""); err != nil {
+		return nil, err
+	}
 	return flight, nil
 }
 
@@ -127,31 +411,260 @@ func (c *Client) ListFlights(ctx context.Context, email string) ([]BoardingPass,
 	pk := azcosmos.NewPartitionKeyString(email)
 
 	// Query all items in the partition
-	query := "SELECT * FROM c WHERE c.email = @email"
+	query := "SELECT * FROM c WHERE " + c.partitionFilter() + " AND " + notDeletedFilter
 	queryOptions := &azcosmos.QueryOptions{
 		QueryParameters: []azcosmos.QueryParameter{
-			{Name: "@email", Value: email},
+			{Name: "@pk", Value: email},
 		},
 	}
 
-	pager := c.container.NewQueryItemsPager(query, pk, queryOptions)
+	items, err := c.queryItems(ctx, "list_flights", query, pk, queryOptions)
+	if err != nil {
+		return nil, err
+	}
+	flights := decodeFlights(items)
 
-	var flights []BoardingPass
+	// Sort by departure date descending
+	sort.Slice(flights, func(i, j int) bool {
+		return flights[i].DepartureDate > flights[j].DepartureDate
+	})
+
+	return flights, nil
+}
+
+// StreamFlights calls fn for each non-deleted flight for a user, newest departure first, as pages
+// arrive from Cosmos DB, so callers can process large histories without holding them in memory.
+// Iteration stops at the first error returned by fn or the pager.
+func (c *Client) StreamFlights(ctx context.Context, email string, fn func(BoardingPass) error) error {
+	if email == "" {
+		return errors.New("email is required")
+	}
+
+	start := time.Now()
+	var requestCharge float32
+
+	pk := azcosmos.NewPartitionKeyString(email)
+	// Sorting happens server-side since pages are handed off before the full result is known
+	query := "SELECT * FROM c WHERE " + c.partitionFilter() + " AND " + notDeletedFilter + " ORDER BY c.departureDate DESC"
+	queryOptions := &azcosmos.QueryOptions{
+		QueryParameters: []azcosmos.QueryParameter{
+			{Name: "@pk", Value: email},
+		},
+	}
+
+	ctx, span := startQuerySpan(ctx, "stream_flights", query)
+	pager := c.container.NewQueryItemsPager(query, pk, queryOptions)
+	pageCount := 0
 	for pager.More() {
+		pageCount++
 		response, err := pager.NextPage(ctx)
 		if err != nil {
-			return nil, err
+			log.Printf("[COSMOS] stream_flights failed on page %d: %v", pageCount, err)
+			metrics.ObserveCosmos("stream_flights", start, requestCharge, err)
+			endQuerySpan(span, requestCharge, err)
+			return err
 		}
+		requestCharge += response.RequestCharge
 
-		for _, item := range response.Items {
-			var flight BoardingPass
-			if err := json.Unmarshal(item, &flight); err != nil {
-				continue
+		for _, flight := range decodeFlights(response.Items) {
+			if err := fn(flight); err != nil {
+				metrics.ObserveCosmos("stream_flights", start, requestCharge, nil)
+				endQuerySpan(span, requestCharge, nil)
+				return err
 			}
-			flights = append(flights, flight)
 		}
 	}
 
+	metrics.ObserveCosmos("stream_flights", start, requestCharge, nil)
+	endQuerySpan(span, requestCharge, nil)
+	return nil
+}
+
+// FlightFilter narrows ListFlightsFiltered. Empty fields are ignored.
+type FlightFilter struct {
+	Airline     string // Case-insensitive substring of the airline name
+	FromAirport string // Departure airport code, case-insensitive
+	ToAirport   string // Arrival airport code, case-insensitive
+	After       string // YYYY-MM-DD; flights departing on or after this date
+	Before      string // YYYY-MM-DD; flights departing on or before this date
+	Tag         string // Flights carrying this tag, case-insensitive
+	Passenger   string // Passenger name, case-insensitive, e.g. one family member on a shared account
+}
+
+// Validate checks that the date bounds are YYYY-MM-DD
+func (f FlightFilter) Validate() error {
+	for name, date := range map[string]string{"after": f.After, "before": f.Before} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return fmt.Errorf("%s must be a date in YYYY-MM-DD format", name)
+		}
+	}
+	return nil
+}
+
+// ListFlightsFiltered retrieves the user's flights matching filter, most recent first.
+// Filter values are bound as query parameters, never concatenated into the SQL.
+func (c *Client) ListFlightsFiltered(ctx context.Context, email string, filter FlightFilter) ([]BoardingPass, error) {
+	if email == "" {
+		return nil, errors.New("email is required")
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	pk := azcosmos.NewPartitionKeyString(email)
+	where, params := c.filterClause(email, filter)
+	query := "SELECT * FROM c WHERE " + where
+	queryOptions := &azcosmos.QueryOptions{QueryParameters: params}
+
+	items, err := c.queryItems(ctx, "list_flights_filtered", query, pk, queryOptions)
+	if err != nil {
+		return nil, err
+	}
+	flights := decodeFlights(items)
+
+	sort.Slice(flights, func(i, j int) bool {
+		return flights[i].DepartureDate > flights[j].DepartureDate
+	})
+	return flights, nil
+}
+
+// filterClause returns the WHERE conditions selecting the user's non-deleted flights that match
+// filter, and the parameters they bind
+func (c *Client) filterClause(email string, filter FlightFilter) (string, []azcosmos.QueryParameter) {
+	conditions := []string{c.partitionFilter(), notDeletedFilter}
+	params := []azcosmos.QueryParameter{{Name: "@pk", Value: email}}
+	if filter.Airline != "" {
+		conditions = append(conditions, "CONTAINS(UPPER(c.airline), @airline)")
+		params = append(params, azcosmos.QueryParameter{Name: "@airline", Value: strings.ToUpper(filter.Airline)})
+	}
+	if filter.FromAirport != "" {
+		conditions = append(conditions, "UPPER(c.fromAirport) = @from")
+		params = append(params, azcosmos.QueryParameter{Name: "@from", Value: strings.ToUpper(filter.FromAirport)})
+	}
+	if filter.ToAirport != "" {
+		conditions = append(conditions, "UPPER(c.toAirport) = @to")
+		params = append(params, azcosmos.QueryParameter{Name: "@to", Value: strings.ToUpper(filter.ToAirport)})
+	}
+	if filter.After != "" {
+		conditions = append(conditions, "c.departureDate >= @after")
+		params = append(params, azcosmos.QueryParameter{Name: "@after", Value: filter.After})
+	}
+	if filter.Before != "" {
+		conditions = append(conditions, "c.departureDate <= @before")
+		params = append(params, azcosmos.QueryParameter{Name: "@before", Value: filter.Before})
+	}
+	if filter.Tag != "" {
+		// Stored tags are normalized, so an exact match is case-insensitive
+		conditions = append(conditions, "ARRAY_CONTAINS(c.tags, @tag)")
+		params = append(params, azcosmos.QueryParameter{Name: "@tag", Value: normalizeTag(filter.Tag)})
+	}
+	if filter.Passenger != "" {
+		conditions = append(conditions, "UPPER(TRIM(c.passenger)) = @passenger")
+		params = append(params, azcosmos.QueryParameter{Name: "@passenger", Value: strings.ToUpper(filter.Passenger)})
+	}
+	return strings.Join(conditions, " AND "), params
+}
+
+// ListUpcomingFlights returns the user's flights departing on or after today, soonest first.
+// "Today" is the date at the departure airport at instant now (fallback for unknown airports),
+// since departure dates are printed in local time. Returns an empty slice when none match.
+func (c *Client) ListUpcomingFlights(ctx context.Context, email string, now time.Time, fallback *time.Location) ([]BoardingPass, error) {
+	// Query with the earliest possible local date, then compare each flight against its own airport
+	earliest, _ := airports.DateRange(now)
+	candidates, err := c.listFlightsByDate(ctx, "list_upcoming_flights", email, "c.departureDate >= @today", earliest)
+	if err != nil {
+		return nil, err
+	}
+	flights := filterByLocalDate(candidates, now, fallback, func(date, today string) bool { return date >= today })
+	sortByDeparture(flights)
+	return flights, nil
+}
+
+// ListPastFlights returns the user's flights that departed before today, most recent first,
+// using the departure airport's local date as in ListUpcomingFlights. Returns an empty slice when none match.
+func (c *Client) ListPastFlights(ctx context.Context, email string, now time.Time, fallback *time.Location) ([]BoardingPass, error) {
+	// Query with the latest possible local date, then compare each flight against its own airport
+	_, latest := airports.DateRange(now)
+	candidates, err := c.listFlightsByDate(ctx, "list_past_flights", email, "c.departureDate < @today", latest)
+	if err != nil {
+		return nil, err
+	}
+	flights := filterByLocalDate(candidates, now, fallback, func(date, today string) bool { return date < today })
+
+	sort.Slice(flights, func(i, j int) bool {
+		return flights[i].DepartureDate+flights[i].DepartureTime > flights[j].DepartureDate+flights[j].DepartureTime
+	})
+	return flights, nil
+}
+
+// filterByLocalDate keeps the flights for which keep(departureDate, today at the departure airport) is true
+func filterByLocalDate(flights []BoardingPass, now time.Time, fallback *time.Location, keep func(date, today string) bool) []BoardingPass {
+	kept := make([]BoardingPass, 0, len(flights))
+	for _, f := range flights {
+		if keep(f.DepartureDate, airports.LocalDate(f.FromAirport, now, fallback)) {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+// listFlightsByDate runs a partition-scoped query with a departure date condition on @today
+func (c *Client) listFlightsByDate(ctx context.Context, operation, email, condition, today string) ([]BoardingPass, error) {
+	if email == "" {
+		return nil, errors.New("email is required")
+	}
+
+	pk := azcosmos.NewPartitionKeyString(email)
+
+	query := "SELECT * FROM c WHERE " + c.partitionFilter() + " AND " + notDeletedFilter + " AND " + condition
+	queryOptions := &azcosmos.QueryOptions{
+		QueryParameters: []azcosmos.QueryParameter{
+			{Name: "@pk", Value: email},
+			{Name: "@today", Value: today},
+		},
+	}
+
+	items, err := c.queryItems(ctx, operation, query, pk, queryOptions)
+	if err != nil {
+		return nil, err
+	}
+	return decodeFlights(items), nil
+}
+
+// SearchFlights returns the user's flights where the term appears in the airline, airports,
+// passenger, flight number, confirmation code, or frequent flyer number (case-insensitive). Returns an empty slice when nothing matches.
+func (c *Client) SearchFlights(ctx context.Context, email, term string) ([]BoardingPass, error) {
+	if email == "" {
+		return nil, errors.New("email is required")
+	}
+
+	pk := azcosmos.NewPartitionKeyString(email)
+
+	// Term is parameterized to avoid injection; UPPER on both sides makes matching case-insensitive
+	query := `SELECT * FROM c WHERE ` + c.partitionFilter() + ` AND ` + notDeletedFilter + ` AND (
+		CONTAINS(UPPER(c.airline), @term) OR
+		CONTAINS(UPPER(c.fromAirport), @term) OR
+		CONTAINS(UPPER(c.toAirport), @term) OR
+		CONTAINS(UPPER(c.passenger), @term) OR
+		CONTAINS(UPPER(c.flightNumber), @term) OR
+		CONTAINS(UPPER(c.confirmationCode), @term) OR
+		CONTAINS(UPPER(c.frequentFlyerNumber), @term))`
+	queryOptions := &azcosmos.QueryOptions{
+		QueryParameters: []azcosmos.QueryParameter{
+			{Name: "@pk", Value: email},
+			{Name: "@term", Value: strings.ToUpper(strings.TrimSpace(term))},
+		},
+	}
+
+	items, err := c.queryItems(ctx, "search_flights", query, pk, queryOptions)
+	if err != nil {
+		return nil, fmt.Errorf("search failed: %w", err)
+	}
+	flights := decodeFlights(items)
+
 	// Sort by departure date descending
 	sort.Slice(flights, func(i, j int) bool {
 		return flights[i].DepartureDate > flights[j].DepartureDate
@@ -160,27 +673,215 @@ func (c *Client) ListFlights(ctx context.Context, email string) ([]BoardingPass,
 	return flights, nil
 }
 
-// DeleteFlight removes a flight from Cosmos DB
-func (c *Client) DeleteFlight(ctx context.Context, id, email string) error {
+// ErrNotFound is returned when the requested flight does not exist
+var ErrNotFound = errors.New("flight not found")
+
+// isNotFound reports whether err is a Cosmos DB 404 response
+func isNotFound(err error) bool {
+	var respErr *azcore.ResponseError
+	return errors.As(err, &respErr) && respErr.StatusCode == http.StatusNotFound
+}
+
+// ErrPreconditionFailed is returned when an update's ETag no longer matches the stored flight
+var ErrPreconditionFailed = errors.New("flight was modified by another request")
+
+// isPreconditionFailed reports whether err is a Cosmos DB 412 response
+func isPreconditionFailed(err error) bool {
+	var respErr *azcore.ResponseError
+	return errors.As(err, &respErr) && respErr.StatusCode == http.StatusPreconditionFailed
+}
+
+// isConflict reports whether err is a Cosmos DB 409 response
+func isConflict(err error) bool {
+	var respErr *azcore.ResponseError
+	return errors.As(err, &respErr) && respErr.StatusCode == http.StatusConflict
+}
+
+// PurgeFlight permanently removes a flight from Cosmos DB, whether or not it is in the trash.
+// It returns the flight as it was before removal, so re-saving it undoes the purge.
+// Returns ErrNotFound if the flight does not exist.
+func (c *Client) PurgeFlight(ctx context.Context, id, email string) (*BoardingPass, error) {
+	flight, err := c.readFlight(ctx, id, email)
+	if err != nil {
+		return nil, err
+	}
+
+	pk := azcosmos.NewPartitionKeyString(email)
+
+	start := time.Now()
+	response, err := c.container.DeleteItem(ctx, pk, id, nil)
+	metrics.ObserveCosmos("delete_item", start, response.RequestCharge, err)
+	if isNotFound(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Return the flight as it was before it was trashed
+	flight.Deleted = false
+	flight.DeletedAt = ""
+	return flight, nil
+}
+
+// GetFlight retrieves a single flight by ID.
+// Returns ErrNotFound if the flight does not exist or is in the trash.
+func (c *Client) GetFlight(ctx context.Context, id, email string) (*BoardingPass, error) {
+	flight, err := c.readFlight(ctx, id, email)
+	if err != nil {
+		return nil, err
+	}
+	if flight.Deleted {
+		return nil, ErrNotFound
+	}
+	return flight, nil
+}
+
+// UpdateFlight overwrites an existing flight's details. The ID, partition key, creation time,
+// TTL, and trash state are preserved from the stored item. When ifMatch is non-empty the write only succeeds
+// if the stored ETag still matches, otherwise ErrPreconditionFailed is returned.
+// Returns ErrNotFound if the flight does not exist or is in the trash.
+func (c *Client) UpdateFlight(ctx context.Context, flight *BoardingPass, ifMatch string) (*BoardingPass, error) {
+	existing, err := c.GetFlight(ctx, flight.ID, c.partitionValue(flight))
+	if err != nil {
+		return nil, err
+	}
+
+	flight.CreatedAt = existing.CreatedAt
+	flight.TTL = existing.TTL
+	flight.Deleted = existing.Deleted
+	flight.DeletedAt = existing.DeletedAt
+	flight.DeriveSeatFields()
+	flight.NormalizeTags()
+	if err := c.replaceFlight(ctx, "update", flight, ifMatch); err != nil {
+		return nil, err
+	}
+	return flight, nil
+}
+
+// patchableFields are the flight properties PatchFlight may change. Identity, partition key,
+// and bookkeeping fields (id, email, createdAt, deleted, ttl) are deliberately excluded.
+var patchableFields = map[string]bool{
+	"flightNumber":        true,
+	"airline":             true,
+	"fromAirport":         true,
+	"toAirport":           true,
+	"departureDate":       true,
+	"departureTime":       true,
+	"seat":                true,
+	"gate":                true,
+	"passenger":           true,
+	"cabinClass":          true,
+	"boardingGroup":       true,
+	"confirmationCode":    true,
+	"frequentFlyerNumber": true,
+	"note":                true,
+}
+
+// maxPatchOperations is the Cosmos DB limit on operations in a single patch request
+const maxPatchOperations = 10
+
+// ErrInvalidPatch is returned by PatchFlight when the fields or values can't be patched
+var ErrInvalidPatch = errors.New("invalid patch")
+
+// ValidatePatch checks a PatchFlight field map and returns its field names in sorted order.
+// Values must be strings; unknown or read-only fields return ErrInvalidPatch.
+func ValidatePatch(ops map[string]any) ([]string, error) {
+	if len(ops) == 0 {
+		return nil, fmt.Errorf("%w: no fields to update", ErrInvalidPatch)
+	}
+	// Patching seat also sets the derived seatRow and seatColumn
+	opCount := len(ops)
+	if _, ok := ops["seat"]; ok {
+		opCount += 2
+	}
+	if opCount > maxPatchOperations {
+		return nil, fmt.Errorf("%w: at most %d operations can be applied at once (seat counts as 3)", ErrInvalidPatch, maxPatchOperations)
+	}
+
+	fields := make([]string, 0, len(ops))
+	for field, value := range ops {
+		if !patchableFields[field] {
+			return nil, fmt.Errorf("%w: field %q cannot be updated", ErrInvalidPatch, field)
+		}
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: field %q must be a string", ErrInvalidPatch, field)
+		}
+		if field == "note" && len(s) > maxNoteLength {
+			return nil, fmt.Errorf("%w: note must be at most %d characters", ErrInvalidPatch, maxNoteLength)
+		}
+		fields = append(fields, field)
+	}
+	sort.Strings(fields) // Deterministic operation order
+	return fields, nil
+}
+
+// PatchFlight sets only the given fields (keyed by JSON name) on an existing flight, leaving
+// the rest untouched. The fields must pass ValidatePatch, otherwise ErrInvalidPatch is returned.
+// When ifMatch is non-empty the write only succeeds if the stored ETag still matches, otherwise
+// ErrPreconditionFailed is returned. Returns ErrNotFound if the flight does not exist or is in the trash.
+func (c *Client) PatchFlight(ctx context.Context, id, email string, ops map[string]any, ifMatch string) error {
 	if id == "" || email == "" {
 		return errors.New("id and email are required")
 	}
+	fields, err := ValidatePatch(ops)
+	if err != nil {
+		return err
+	}
+
+	var patch azcosmos.PatchOperations
+	for _, field := range fields {
+		patch.AppendSet("/"+field, ops[field])
+	}
+	if seat, ok := ops["seat"]; ok {
+		row, column, _ := parseSeat(seat.(string))
+		patch.AppendSet("/seatRow", row)
+		patch.AppendSet("/seatColumn", column)
+	}
+	// Trashed flights fail the condition with a 412, which is reported as not found
+	patch.SetCondition("FROM c WHERE " + notDeletedFilter)
 
 	pk := azcosmos.NewPartitionKeyString(email)
 
-	_, err := c.container.DeleteItem(ctx, pk, id, nil)
+	var opts *azcosmos.ItemOptions
+	if ifMatch != "" {
+		etag := azcore.ETag(ifMatch)
+		opts = &azcosmos.ItemOptions{IfMatchEtag: &etag}
+	}
+
+	start := time.Now()
+	response, err := c.container.PatchItem(ctx, pk, id, patch, opts)
+	metrics.ObserveCosmos("patch_item", start, response.RequestCharge, err)
+	if isNotFound(err) {
+		return ErrNotFound
+	}
+	if isPreconditionFailed(err) {
+		// A 412 means either the ETag or the not-deleted condition failed; tell them apart
+		if ifMatch != "" {
+			if _, getErr := c.GetFlight(ctx, id, email); !errors.Is(getErr, ErrNotFound) {
+				return ErrPreconditionFailed
+			}
+		}
+		return ErrNotFound
+	}
 	return err
 }
 
-// GetFlight retrieves a single flight by ID
-func (c *Client) GetFlight(ctx context.Context, id, email string) (*BoardingPass, error) {
+// readFlight retrieves a single flight by ID, including trashed flights
+func (c *Client) readFlight(ctx context.Context, id, email string) (*BoardingPass, error) {
 	if id == "" || email == "" {
 		return nil, errors.New("id and email are required")
 	}
 
 	pk := azcosmos.NewPartitionKeyString(email)
 
+	start := time.Now()
 	response, err := c.container.ReadItem(ctx, pk, id, nil)
+	metrics.ObserveCosmos("read_item", start, response.RequestCharge, err)
+	if isNotFound(err) {
+		return nil, ErrNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -204,34 +905,19 @@ func (c *Client) ExecuteQuery(ctx context.Context, query, email string) ([]Board
 	// Use partition key for efficient single-partition query
 	pk := azcosmos.NewPartitionKeyString(email)
 
-	pager := c.container.NewQueryItemsPager(query, pk, nil)
-
-	var flights []BoardingPass
-	for pager.More() {
-		response, err := pager.NextPage(ctx)
-		if err != nil {
-			return nil, fmt.Errorf("query failed: %w", err)
-		}
-
-		for _, item := range response.Items {
-			var flight BoardingPass
-			if err := json.Unmarshal(item, &flight); err != nil {
-				continue
-			}
-			flights = append(flights, flight)
-		}
+	items, err := c.queryItems(ctx, "execute_query", query, pk, nil)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
 	}
 
-	return flights, nil
+	return decodeFlights(items), nil
 }
 
 // ExecuteRawQuery runs an AI-generated SQL query and returns raw JSON results.
 // This handles any query type including aggregates (COUNT, SUM), GROUP BY, DISTINCT, etc.
 // The email parameter is used as the partition key for efficient queries.
 func (c *Client) ExecuteRawQuery(ctx context.Context, query, email string) ([]json.RawMessage, error) {
-	// log.Printf("[COSMOS] ExecuteRawQuery called")
-	// log.Printf("[COSMOS] Query: %s", query)
-	// log.Printf("[COSMOS] Email (partition key): %s", email)
+	slog.Debug("[COSMOS] ExecuteRawQuery", "query", redact.Query(query), "partitionKey", redact.Email(email))
 
 	if email == "" {
 		return nil, errors.New("email is required for partition-scoped queries")
@@ -240,25 +926,73 @@ func (c *Client) ExecuteRawQuery(ctx context.Context, query, email string) ([]js
 	// Use partition key for efficient single-partition query
 	pk := azcosmos.NewPartitionKeyString(email)
 
-	pager := c.container.NewQueryItemsPager(query, pk, nil)
+	items, err := c.queryItems(ctx, "execute_raw_query", query, pk, nil)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
 
-	var results []json.RawMessage
-	pageCount := 0
-	for pager.More() {
-		pageCount++
-		response, err := pager.NextPage(ctx)
-		if err != nil {
-			log.Printf("[COSMOS] Query failed on page %d: %v", pageCount, err)
-			return nil, fmt.Errorf("query failed: %w", err)
-		}
-		// log.Printf("[COSMOS] Page %d returned %d items", pageCount, len(response.Items))
+	results := make([]json.RawMessage, 0, len(items))
+	for _, item := range items {
+		results = append(results, json.RawMessage(item))
+	}
+
+	slog.Debug("[COSMOS] ExecuteRawQuery results", "count", len(results))
+	return results, nil
+}
+
+// QueryPlan describes the cost and index usage of a query, as reported by Cosmos DB for its first page
+type QueryPlan struct {
+	Query          string  `json:"query"`
+	RequestCharge  float32 `json:"requestCharge"`          // RU consumed by the first page
+	IndexMetrics   string  `json:"indexMetrics,omitempty"` // Index utilization (decoded from base64 when possible)
+	QueryMetrics   string  `json:"queryMetrics,omitempty"` // Raw x-ms-documentdb-query-metrics header
+	FirstPageItems int     `json:"firstPageItems"`         // Number of items returned in the first page
+	HasMoreResults bool    `json:"hasMoreResults"`         // True if the query would need additional pages
+	ActivityID     string  `json:"activityId,omitempty"`   // Cosmos activity ID for troubleshooting
+}
+
+// ExplainQuery runs a query with index metrics enabled and returns the metrics from the first page only.
+// Results are not materialized beyond the first page, so this is a cheap way to preview a query's cost.
+func (c *Client) ExplainQuery(ctx context.Context, query, email string) (*QueryPlan, error) {
+	if email == "" {
+		return nil, errors.New("email is required for partition-scoped queries")
+	}
 
-		for _, item := range response.Items {
-			// log.Printf("[COSMOS] Item %d: %s", i, string(item))
-			results = append(results, json.RawMessage(item))
+	pk := azcosmos.NewPartitionKeyString(email)
+
+	pager := c.container.NewQueryItemsPager(query, pk, &azcosmos.QueryOptions{
+		PopulateIndexMetrics: true,
+	})
+
+	plan := &QueryPlan{Query: query}
+	if !pager.More() {
+		return plan, nil
+	}
+
+	start := time.Now()
+	ctx, span := startQuerySpan(ctx, "explain_query", query)
+	response, err := pager.NextPage(ctx)
+	metrics.ObserveCosmos("explain_query", start, response.RequestCharge, err)
+	endQuerySpan(span, response.RequestCharge, err)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+
+	plan.RequestCharge = response.RequestCharge
+	plan.ActivityID = response.ActivityID
+	plan.FirstPageItems = len(response.Items)
+	plan.HasMoreResults = pager.More()
+
+	if response.IndexMetrics != nil {
+		plan.IndexMetrics = *response.IndexMetrics
+		// Index metrics are returned base64-encoded; fall back to the raw header if decoding fails
+		if decoded, err := base64.StdEncoding.DecodeString(plan.IndexMetrics); err == nil {
+			plan.IndexMetrics = string(decoded)
 		}
 	}
+	if response.QueryMetrics != nil {
+		plan.QueryMetrics = *response.QueryMetrics
+	}
 
-	// log.Printf("[COSMOS] Total results: %d", len(results))
-	return results, nil
+	return plan, nil
 }
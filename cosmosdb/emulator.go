@@ -0,0 +1,58 @@
+package cosmosdb
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azcosmos"
+)
+
+// emulatorTLSTimeout bounds each HTTPS request to the emulator
+const emulatorTLSTimeout = 60 * time.Second
+
+// emulatorClientOptions returns client options for the emulator.
+// Plain HTTP (nil options) is used when certPath is empty. Otherwise requests go over HTTPS
+// and trust the emulator's self-signed certificate, read as PEM from certPath.
+// The Go SDK always talks to the gateway, so no connection mode setting is needed.
+func emulatorClientOptions(endpoint, certPath string) (*azcosmos.ClientOptions, error) {
+	if certPath == "" {
+		return nil, nil
+	}
+
+	if !strings.HasPrefix(endpoint, "https://") {
+		return nil, errors.New("the emulator's TLS mode requires an https:// endpoint")
+	}
+
+	pem, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read emulator certificate: %w", err)
+	}
+
+	// Trust the emulator certificate in addition to the system roots
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		roots = x509.NewCertPool()
+	}
+	if !roots.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no PEM certificates found in %s", certPath)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		RootCAs:    roots,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	return &azcosmos.ClientOptions{
+		ClientOptions: policy.ClientOptions{
+			Transport: &http.Client{Transport: transport, Timeout: emulatorTLSTimeout},
+		},
+	}, nil
+}
@@ -0,0 +1,59 @@
+package cosmosdb
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azcosmos"
+	"github.com/abhirockzz/flight-log-app/airports"
+)
+
+// nextFlightCandidates is how many of the earliest-dated flights NextFlight reads. Ordering by
+// date and time together in SQL needs a composite index the container isn't created with, so the
+// query orders by date and the candidates are compared by date and time here. It only needs to
+// cover the flights dated before the answer: ones whose date has already passed at their airport
+// plus those sharing the soonest date.
+const nextFlightCandidates = 20
+
+// NextFlight returns the user's soonest flight departing on or after today at its departure airport
+// (as in ListUpcomingFlights), with an earlier departure time winning on the same date. It runs a single
+// TOP query, so it stays cheap however many flights the user has. Returns ErrNotFound when there is none.
+func (c *Client) NextFlight(ctx context.Context, email string, now time.Time, fallback *time.Location) (*BoardingPass, error) {
+	if email == "" {
+		return nil, errors.New("email is required")
+	}
+
+	// Query from the earliest possible local date, then compare each flight against its own airport
+	earliest, _ := airports.DateRange(now)
+	pk := azcosmos.NewPartitionKeyString(email)
+	query := "SELECT TOP @n * FROM c WHERE " + c.partitionFilter() + " AND " + notDeletedFilter +
+		" AND c.departureDate >= @today ORDER BY c.departureDate ASC"
+	queryOptions := &azcosmos.QueryOptions{
+		QueryParameters: []azcosmos.QueryParameter{
+			{Name: "@pk", Value: email},
+			{Name: "@today", Value: earliest},
+			{Name: "@n", Value: nextFlightCandidates},
+		},
+	}
+
+	items, err := c.queryItems(ctx, "next_flight", query, pk, queryOptions)
+	if err != nil {
+		return nil, err
+	}
+	flights := filterByLocalDate(decodeFlights(items), now, fallback, func(date, today string) bool { return date >= today })
+	if len(flights) == 0 {
+		return nil, ErrNotFound
+	}
+
+	sortByDeparture(flights)
+	return &flights[0], nil
+}
+
+// sortByDeparture orders flights soonest first by departure date, then time
+func sortByDeparture(flights []BoardingPass) {
+	sort.Slice(flights, func(i, j int) bool {
+		return flights[i].DepartureDate+flights[i].DepartureTime < flights[j].DepartureDate+flights[j].DepartureTime
+	})
+}
@@ -0,0 +1,155 @@
+package cosmosdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azcosmos"
+)
+
+// Sort orders accepted by FlightPage
+const (
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+)
+
+// Page sizes for ListFlightsPage
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// sortFields are the fields flights can be sorted by. Every one is always present on stored flights,
+// since Cosmos DB's ORDER BY leaves out items missing the field.
+var sortFields = map[string]func(BoardingPass) string{
+	"departureDate": func(f BoardingPass) string { return f.DepartureDate },
+	"departureTime": func(f BoardingPass) string { return f.DepartureTime },
+	"flightNumber":  func(f BoardingPass) string { return f.FlightNumber },
+	"airline":       func(f BoardingPass) string { return f.Airline },
+	"fromAirport":   func(f BoardingPass) string { return f.FromAirport },
+	"toAirport":     func(f BoardingPass) string { return f.ToAirport },
+	"createdAt":     func(f BoardingPass) string { return f.CreatedAt },
+}
+
+// FlightPage selects one page of a sorted flight list. The zero value is the first
+// DefaultPageSize flights, most recent departure first.
+type FlightPage struct {
+	Sort  string // One of the sortFields; empty sorts by departureDate
+	Order string // OrderAsc or OrderDesc; empty is descending
+	Page  int    // 1-based; 0 is the first page
+	Size  int    // Flights per page, at most MaxPageSize; 0 is DefaultPageSize
+}
+
+// ErrInvalidPage is returned for a FlightPage with an unknown sort field or order or an out-of-range page or size
+var ErrInvalidPage = errors.New("invalid page")
+
+// normalize validates p and fills in its defaults
+func (p FlightPage) normalize() (FlightPage, error) {
+	if p.Sort == "" {
+		p.Sort = "departureDate"
+	}
+	if _, ok := sortFields[p.Sort]; !ok {
+		fields := make([]string, 0, len(sortFields))
+		for field := range sortFields {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		return p, fmt.Errorf("%w: cannot sort by %q (expected one of %s)", ErrInvalidPage, p.Sort, strings.Join(fields, ", "))
+	}
+	switch p.Order = strings.ToLower(p.Order); p.Order {
+	case "":
+		p.Order = OrderDesc
+	case OrderAsc, OrderDesc:
+	default:
+		return p, fmt.Errorf("%w: order must be %s or %s", ErrInvalidPage, OrderAsc, OrderDesc)
+	}
+	if p.Page == 0 {
+		p.Page = 1
+	}
+	if p.Size == 0 {
+		p.Size = DefaultPageSize
+	}
+	if p.Page < 1 {
+		return p, fmt.Errorf("%w: page must be at least 1", ErrInvalidPage)
+	}
+	if p.Size < 1 || p.Size > MaxPageSize {
+		return p, fmt.Errorf("%w: size must be between 1 and %d", ErrInvalidPage, MaxPageSize)
+	}
+	return p, nil
+}
+
+// Validate checks the sort field, order, page, and size
+func (p FlightPage) Validate() error {
+	_, err := p.normalize()
+	return err
+}
+
+// PageFlights sorts flights as p asks and returns its page along with the total number of flights.
+// Stores that can't sort and page in the database (e.g. the in-memory store) use it.
+func PageFlights(flights []BoardingPass, p FlightPage) ([]BoardingPass, int, error) {
+	p, err := p.normalize()
+	if err != nil {
+		return nil, 0, err
+	}
+	key := sortFields[p.Sort]
+	sort.SliceStable(flights, func(i, j int) bool {
+		if p.Order == OrderAsc {
+			return key(flights[i]) < key(flights[j])
+		}
+		return key(flights[i]) > key(flights[j])
+	})
+
+	total := len(flights)
+	start := min((p.Page-1)*p.Size, total)
+	end := min(start+p.Size, total)
+	return flights[start:end], total, nil
+}
+
+// ListFlightsPage returns one page of the user's flights matching filter, sorted as page asks, and
+// the number of matching flights across all pages. Sorting and paging run in Cosmos DB.
+func (c *Client) ListFlightsPage(ctx context.Context, email string, filter FlightFilter, page FlightPage) ([]BoardingPass, int, error) {
+	if email == "" {
+		return nil, 0, errors.New("email is required")
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, 0, err
+	}
+	page, err := page.normalize()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	pk := azcosmos.NewPartitionKeyString(email)
+	where, params := c.filterClause(email, filter)
+
+	countItems, err := c.queryItems(ctx, "count_flights", "SELECT VALUE COUNT(1) FROM c WHERE "+where, pk,
+		&azcosmos.QueryOptions{QueryParameters: params})
+	if err != nil {
+		return nil, 0, err
+	}
+	var total int
+	if len(countItems) > 0 {
+		if err := json.Unmarshal(countItems[0], &total); err != nil {
+			return nil, 0, fmt.Errorf("failed to decode flight count: %w", err)
+		}
+	}
+
+	// The sort field is whitelisted, so it is safe to put in the SQL; ORDER BY names the property
+	// path itself, as Cosmos DB doesn't accept SELECT aliases there
+	query := fmt.Sprintf("SELECT * FROM c WHERE %s ORDER BY c.%s %s OFFSET @offset LIMIT @limit",
+		where, page.Sort, strings.ToUpper(page.Order))
+	params = append(params,
+		azcosmos.QueryParameter{Name: "@offset", Value: (page.Page - 1) * page.Size},
+		azcosmos.QueryParameter{Name: "@limit", Value: page.Size},
+	)
+
+	items, err := c.queryItems(ctx, "list_flights_page", query, pk, &azcosmos.QueryOptions{QueryParameters: params})
+	if err != nil {
+		return nil, 0, err
+	}
+	return decodeFlights(items), total, nil
+}
@@ -0,0 +1,63 @@
+package cosmosdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azcosmos"
+)
+
+// ListPassengers returns the distinct passenger names on the user's flights, alphabetically.
+// Family accounts keep several passengers' flights under one email; the names feed the
+// FlightFilter.Passenger filter.
+func (c *Client) ListPassengers(ctx context.Context, email string) ([]string, error) {
+	if email == "" {
+		return nil, errors.New("email is required")
+	}
+
+	pk := azcosmos.NewPartitionKeyString(email)
+
+	query := "SELECT DISTINCT VALUE c.passenger FROM c WHERE " + c.partitionFilter() + " AND " + notDeletedFilter
+	queryOptions := &azcosmos.QueryOptions{
+		QueryParameters: []azcosmos.QueryParameter{
+			{Name: "@pk", Value: email},
+		},
+	}
+
+	items, err := c.queryItems(ctx, "list_passengers", query, pk, queryOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(items))
+	for _, item := range items {
+		var name string
+		if err := json.Unmarshal(item, &name); err == nil {
+			names = append(names, name)
+		}
+	}
+	return DistinctPassengers(names), nil
+}
+
+// DistinctPassengers trims names and drops empty ones and case-insensitive repeats (the passenger
+// filter ignores case), keeping the first spelling, and sorts the rest alphabetically
+func DistinctPassengers(names []string) []string {
+	passengers := make([]string, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		key := strings.ToUpper(name)
+		if name == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		passengers = append(passengers, name)
+	}
+	sort.Slice(passengers, func(i, j int) bool {
+		return strings.ToUpper(passengers[i]) < strings.ToUpper(passengers[j])
+	})
+	return passengers
+}
@@ -0,0 +1,46 @@
+package cosmosdb
+
+import "slices"
+
+// template copies a flight for a new trip: the copy has no ID, creation time, ETag, or trash
+// and expiry state, and drops the per-trip seat, gate, boarding group, confirmation code, note, and image
+// so nothing stale is saved
+func (b *BoardingPass) template() *BoardingPass {
+	t := *b
+	t.ID = ""
+	t.CreatedAt = ""
+	t.ETag = ""
+	t.Deleted = false
+	t.DeletedAt = ""
+	t.TTL = 0
+	t.Seat = ""
+	t.SeatRow = 0
+	t.SeatColumn = ""
+	t.Gate = ""
+	t.BoardingGroup = ""
+	t.ConfirmationCode = ""
+	t.Note = ""
+	t.ImageURL = ""
+	t.Tags = slices.Clone(b.Tags)
+	return &t
+}
+
+// MakeReturn returns an unsaved return flight: the airports are swapped, and the flight number,
+// date, and time are cleared since the return leg has its own. Airline, passenger, owner,
+// cabin, and tags are kept.
+func (b *BoardingPass) MakeReturn() *BoardingPass {
+	r := b.template()
+	r.FromAirport, r.ToAirport = b.ToAirport, b.FromAirport
+	r.FlightNumber = ""
+	r.DepartureDate = ""
+	r.DepartureTime = ""
+	return r
+}
+
+// MakeRepeat returns an unsaved copy of the flight departing on date (YYYY-MM-DD), for commuters
+// who fly the same route and flight number again
+func (b *BoardingPass) MakeRepeat(date string) *BoardingPass {
+	r := b.template()
+	r.DepartureDate = date
+	return r
+}
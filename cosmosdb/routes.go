@@ -0,0 +1,86 @@
+package cosmosdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azcosmos"
+)
+
+// FlightRoute is the number of flights a user took between two airports, in that direction.
+// Unlike the admin RouteCount, its short field names match what the route map client expects.
+type FlightRoute struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Count int    `json:"count"`
+}
+
+// ListRoutes returns the user's distinct routes with flight counts, most flown first.
+// Cosmos DB doesn't support ORDER BY together with GROUP BY, so the groups are sorted here.
+func (c *Client) ListRoutes(ctx context.Context, email string) ([]FlightRoute, error) {
+	if email == "" {
+		return nil, errors.New("email is required")
+	}
+
+	pk := azcosmos.NewPartitionKeyString(email)
+
+	query := "SELECT c.fromAirport, c.toAirport, COUNT(1) AS flights FROM c WHERE " + c.partitionFilter() + " AND " + notDeletedFilter +
+		" GROUP BY c.fromAirport, c.toAirport"
+	queryOptions := &azcosmos.QueryOptions{
+		QueryParameters: []azcosmos.QueryParameter{
+			{Name: "@pk", Value: email},
+		},
+	}
+
+	items, err := c.queryItems(ctx, "list_routes", query, pk, queryOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	routes := make([]FlightRoute, 0, len(items))
+	for _, item := range items {
+		var group struct {
+			FromAirport string `json:"fromAirport"`
+			ToAirport   string `json:"toAirport"`
+			Flights     int    `json:"flights"`
+		}
+		if err := json.Unmarshal(item, &group); err != nil || group.FromAirport == "" || group.ToAirport == "" {
+			continue
+		}
+		routes = append(routes, FlightRoute{From: group.FromAirport, To: group.ToAirport, Count: group.Flights})
+	}
+	sortRoutes(routes)
+	return routes, nil
+}
+
+// CountRoutes groups flights into routes the same way ListRoutes does, for stores without GROUP BY
+func CountRoutes(flights []BoardingPass) []FlightRoute {
+	counts := make(map[[2]string]int)
+	for _, flight := range flights {
+		if flight.FromAirport != "" && flight.ToAirport != "" {
+			counts[[2]string{flight.FromAirport, flight.ToAirport}]++
+		}
+	}
+
+	routes := make([]FlightRoute, 0, len(counts))
+	for route, count := range counts {
+		routes = append(routes, FlightRoute{From: route[0], To: route[1], Count: count})
+	}
+	sortRoutes(routes)
+	return routes
+}
+
+// sortRoutes orders routes by count, highest first, then alphabetically for a stable response
+func sortRoutes(routes []FlightRoute) {
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Count != routes[j].Count {
+			return routes[i].Count > routes[j].Count
+		}
+		if routes[i].From != routes[j].From {
+			return routes[i].From < routes[j].From
+		}
+		return routes[i].To < routes[j].To
+	})
+}
@@ -0,0 +1,30 @@
+package cosmosdb
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// seatPattern matches a row number followed by a seat letter, e.g. "12A" or "3 F"
+var seatPattern = regexp.MustCompile(`^(\d{1,3})\s*([A-Z])$`)
+
+// parseSeat splits a seat like "12A" into its row (12) and column letter ("A").
+// ok is false for seats that don't follow the row+letter pattern, e.g. "" or "GATE".
+func parseSeat(s string) (int, string, bool) {
+	m := seatPattern.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(s)))
+	if m == nil {
+		return 0, "", false
+	}
+	row, err := strconv.Atoi(m[1])
+	if err != nil || row == 0 {
+		return 0, "", false
+	}
+	return row, m[2], true
+}
+
+// DeriveSeatFields sets SeatRow and SeatColumn from Seat, or clears them when Seat doesn't parse.
+// Stores call it on every write so queries can filter on the parts (e.g. window seats).
+func (b *BoardingPass) DeriveSeatFields() {
+	b.SeatRow, b.SeatColumn, _ = parseSeat(b.Seat)
+}
@@ -0,0 +1,144 @@
+package cosmosdb
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Seat positions reported by SeatStats
+const (
+	SeatWindow = "window"
+	SeatMiddle = "middle"
+	SeatAisle  = "aisle"
+)
+
+// SeatLayout maps seat letters to window, middle, or aisle for an assumed cabin layout
+type SeatLayout struct {
+	spec      string
+	positions map[string]string
+}
+
+// DefaultSeatLayout is a single-aisle 3-3 cabin, the most common layout on short-haul flights
+var DefaultSeatLayout = mustParseSeatLayout("ABC-DEF")
+
+// ParseSeatLayout parses a layout such as "ABC-DEF" (3-3) or "ABC-DEFG-HJK" (3-4-3): seat letters
+// from one window to the other, with "-" for each aisle. The outermost letters are window seats,
+// letters next to an aisle are aisle seats, and the rest are middle seats.
+func ParseSeatLayout(spec string) (SeatLayout, error) {
+	spec = strings.ToUpper(strings.TrimSpace(spec))
+	groups := strings.Split(spec, "-")
+	if len(groups) < 2 {
+		return SeatLayout{}, fmt.Errorf("seat layout %q has no aisle (expected e.g. ABC-DEF)", spec)
+	}
+
+	positions := make(map[string]string)
+	for g, group := range groups {
+		if group == "" {
+			return SeatLayout{}, fmt.Errorf("seat layout %q has an empty seat group", spec)
+		}
+		for i, r := range group {
+			letter := string(r)
+			if r < 'A' || r > 'Z' {
+				return SeatLayout{}, fmt.Errorf("seat layout %q has invalid seat letter %q", spec, letter)
+			}
+			if _, dup := positions[letter]; dup {
+				return SeatLayout{}, fmt.Errorf("seat layout %q repeats seat letter %s", spec, letter)
+			}
+			switch {
+			case (g == 0 && i == 0) || (g == len(groups)-1 && i == len(group)-1):
+				positions[letter] = SeatWindow
+			case i == 0 || i == len(group)-1:
+				positions[letter] = SeatAisle
+			default:
+				positions[letter] = SeatMiddle
+			}
+		}
+	}
+	return SeatLayout{spec: spec, positions: positions}, nil
+}
+
+// mustParseSeatLayout parses a built-in layout, panicking if it is invalid
+func mustParseSeatLayout(spec string) SeatLayout {
+	l, err := ParseSeatLayout(spec)
+	if err != nil {
+		panic(err)
+	}
+	return l
+}
+
+// String returns the layout in the form ParseSeatLayout accepts
+func (l SeatLayout) String() string {
+	return l.spec
+}
+
+// Position returns SeatWindow, SeatMiddle, or SeatAisle for a seat letter, or "" when the
+// letter isn't part of the layout
+func (l SeatLayout) Position(column string) string {
+	return l.positions[strings.ToUpper(column)]
+}
+
+// SeatStats summarizes where a user tends to sit
+type SeatStats struct {
+	Layout          string `json:"layout"` // Cabin layout the positions assume, e.g. "ABC-DEF"
+	Window          int    `json:"window"`
+	Middle          int    `json:"middle"`
+	Aisle           int    `json:"aisle"`
+	MostCommon      string `json:"mostCommonSeat,omitempty"` // e.g. "12A"; the lowest row and letter wins a tie
+	MostCommonCount int    `json:"mostCommonSeatCount,omitempty"`
+	Skipped         int    `json:"skipped"` // Flights whose seat isn't row+letter (e.g. "" or "GATE") or whose letter isn't in the layout
+}
+
+// SeatPreferences counts window, middle, and aisle seats in flights under layout (DefaultSeatLayout
+// when zero) and finds the most frequent seat. Seats that can't be classified are counted in
+// Skipped rather than guessed.
+func SeatPreferences(flights []BoardingPass, layout SeatLayout) *SeatStats {
+	if layout.positions == nil {
+		layout = DefaultSeatLayout
+	}
+
+	type seat struct {
+		row    int
+		column string
+	}
+	stats := &SeatStats{Layout: layout.String()}
+	counts := make(map[seat]int)
+	for _, f := range flights {
+		row, column, ok := parseSeat(f.Seat)
+		if !ok {
+			stats.Skipped++
+			continue
+		}
+		switch layout.Position(column) {
+		case SeatWindow:
+			stats.Window++
+		case SeatMiddle:
+			stats.Middle++
+		case SeatAisle:
+			stats.Aisle++
+		default:
+			stats.Skipped++
+			continue
+		}
+		counts[seat{row, column}]++
+	}
+
+	seats := make([]seat, 0, len(counts))
+	for s := range counts {
+		seats = append(seats, s)
+	}
+	sort.Slice(seats, func(i, j int) bool {
+		if counts[seats[i]] != counts[seats[j]] {
+			return counts[seats[i]] > counts[seats[j]]
+		}
+		if seats[i].row != seats[j].row {
+			return seats[i].row < seats[j].row
+		}
+		return seats[i].column < seats[j].column
+	})
+	if len(seats) > 0 {
+		stats.MostCommon = fmt.Sprintf("%d%s", seats[0].row, seats[0].column)
+		stats.MostCommonCount = counts[seats[0]]
+	}
+	return stats
+}
@@ -0,0 +1,54 @@
+package cosmosdb
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// FlightStore is the flight storage used by the server and the chat handler. Client implements
+// it against Cosmos DB; the inmemory package implements it for local runs and tests without Cosmos.
+// Implementations return the sentinel errors documented on Client's methods (ErrNotFound,
+// ErrDuplicateFlight, ErrPreconditionFailed, ErrInvalidPatch) so callers can handle them uniformly.
+type FlightStore interface {
+	// Create
+	SaveFlight(ctx context.Context, flight *BoardingPass) (*BoardingPass, error)
+	InsertFlight(ctx context.Context, flight *BoardingPass) (*BoardingPass, error)
+	SaveFlightsBatch(ctx context.Context, flights []*BoardingPass) ([]BoardingPass, error)
+
+	// Read
+	GetFlight(ctx context.Context, id, email string) (*BoardingPass, error)
+	FindDuplicate(ctx context.Context, flight *BoardingPass) (*BoardingPass, error)
+	ListFlights(ctx context.Context, email string) ([]BoardingPass, error)
+	ListFlightsFiltered(ctx context.Context, email string, filter FlightFilter) ([]BoardingPass, error)
+	ListFlightsPage(ctx context.Context, email string, filter FlightFilter, page FlightPage) ([]BoardingPass, int, error)
+	StreamFlights(ctx context.Context, email string, fn func(BoardingPass) error) error
+	SearchFlights(ctx context.Context, email, term string) ([]BoardingPass, error)
+	ListUpcomingFlights(ctx context.Context, email string, now time.Time, fallback *time.Location) ([]BoardingPass, error)
+	NextFlight(ctx context.Context, email string, now time.Time, fallback *time.Location) (*BoardingPass, error)
+	ListPastFlights(ctx context.Context, email string, now time.Time, fallback *time.Location) ([]BoardingPass, error)
+	Summarize(ctx context.Context, email, today string) (*UserSummary, error)
+	ListRoutes(ctx context.Context, email string) ([]FlightRoute, error)
+	ListPassengers(ctx context.Context, email string) ([]string, error)
+
+	// Update
+	UpdateFlight(ctx context.Context, flight *BoardingPass, ifMatch string) (*BoardingPass, error)
+	PatchFlight(ctx context.Context, id, email string, ops map[string]any, ifMatch string) error
+
+	// Delete and trash
+	DeleteFlight(ctx context.Context, id, email, ifMatch string) (*BoardingPass, error)
+	PurgeFlight(ctx context.Context, id, email string) (*BoardingPass, error)
+	RestoreFlight(ctx context.Context, id, email string) (*BoardingPass, error)
+	ListDeletedFlights(ctx context.Context, email string) ([]BoardingPass, error)
+	PurgeDeletedFlights(ctx context.Context, cutoff time.Time) ([]BoardingPass, error)
+
+	// AI-generated queries (Cosmos DB SQL)
+	ExecuteRawQuery(ctx context.Context, query, email string) ([]json.RawMessage, error)
+	ExplainQuery(ctx context.Context, query, email string) (*QueryPlan, error)
+
+	// Cross-partition statistics for operators
+	AdminStats(ctx context.Context) (*GlobalStats, error)
+}
+
+// Client must keep satisfying FlightStore as methods are added
+var _ FlightStore = (*Client)(nil)
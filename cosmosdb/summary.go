@@ -0,0 +1,75 @@
+package cosmosdb
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azcosmos"
+)
+
+// UserSummary is a cheap overview of a user's flights, used to tailor chat suggestions
+type UserSummary struct {
+	TotalFlights    int    `json:"totalFlights"`
+	UpcomingFlights int    `json:"upcomingFlights"`
+	TopAirline      string `json:"topAirline,omitempty"`
+	TopDestination  string `json:"topDestination,omitempty"`
+}
+
+// Summarize counts the user's flights and finds their most frequent airline and destination.
+// It projects only the fields it needs so the query stays cheap on large partitions.
+func (c *Client) Summarize(ctx context.Context, email, today string) (*UserSummary, error) {
+	if email == "" {
+		return nil, errors.New("email is required")
+	}
+
+	pk := azcosmos.NewPartitionKeyString(email)
+
+	query := "SELECT c.airline, c.toAirport, c.departureDate FROM c WHERE " + c.partitionFilter() + " AND " + notDeletedFilter
+	queryOptions := &azcosmos.QueryOptions{
+		QueryParameters: []azcosmos.QueryParameter{
+			{Name: "@pk", Value: email},
+		},
+	}
+
+	items, err := c.queryItems(ctx, "summarize", query, pk, queryOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	return SummarizeFlights(decodeFlights(items), today), nil
+}
+
+// SummarizeFlights builds a UserSummary from a user's active flights, counting flights
+// departing on or after today (YYYY-MM-DD) as upcoming
+func SummarizeFlights(flights []BoardingPass, today string) *UserSummary {
+	summary := &UserSummary{}
+	airlines := make(map[string]int)
+	destinations := make(map[string]int)
+	for _, flight := range flights {
+		summary.TotalFlights++
+		if flight.DepartureDate >= today {
+			summary.UpcomingFlights++
+		}
+		if flight.Airline != "" {
+			airlines[flight.Airline]++
+		}
+		if flight.ToAirport != "" {
+			destinations[flight.ToAirport]++
+		}
+	}
+
+	summary.TopAirline = mostFrequent(airlines)
+	summary.TopDestination = mostFrequent(destinations)
+	return summary
+}
+
+// mostFrequent returns the key with the highest count, breaking ties alphabetically
+func mostFrequent(counts map[string]int) string {
+	var best string
+	for key, count := range counts {
+		if best == "" || count > counts[best] || (count == counts[best] && key < best) {
+			best = key
+		}
+	}
+	return best
+}
@@ -0,0 +1,57 @@
+package cosmosdb
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Limits on user annotations, keeping items small and tags usable as filters
+const (
+	maxTags       = 20
+	maxTagLength  = 32
+	maxNoteLength = 1000
+)
+
+// NormalizeTags trims and lowercases Tags, dropping empty and repeated tags while keeping their order,
+// so "Work" and "work " match the same ?tag=work filter. Stores call it on every write.
+func (b *BoardingPass) NormalizeTags() {
+	if len(b.Tags) == 0 {
+		b.Tags = nil
+		return
+	}
+	tags := make([]string, 0, len(b.Tags))
+	seen := make(map[string]bool, len(b.Tags))
+	for _, tag := range b.Tags {
+		tag = normalizeTag(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	if len(tags) == 0 {
+		tags = nil
+	}
+	b.Tags = tags
+}
+
+// normalizeTag returns tag in the form it is stored and filtered by
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// ValidateAnnotations checks Tags and Note against the size limits, returning ErrInvalidFlight if exceeded
+func (b *BoardingPass) ValidateAnnotations() error {
+	if len(b.Tags) > maxTags {
+		return fmt.Errorf("%w: at most %d tags are allowed", ErrInvalidFlight, maxTags)
+	}
+	for _, tag := range b.Tags {
+		if len(tag) > maxTagLength {
+			return fmt.Errorf("%w: tags must be at most %d characters", ErrInvalidFlight, maxTagLength)
+		}
+	}
+	if len(b.Note) > maxNoteLength {
+		return fmt.Errorf("%w: note must be at most %d characters", ErrInvalidFlight, maxNoteLength)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package cosmosdb
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Timeline buckets accepted by FlightsByPeriod
+const (
+	BucketMonth = "month"
+	BucketYear  = "year"
+)
+
+// Timeline counts a user's flights per calendar period of their departure date
+type Timeline struct {
+	Bucket      string        `json:"bucket"`
+	Periods     []PeriodCount `json:"periods"`     // Oldest first; periods without flights are omitted
+	Unparseable int           `json:"unparseable"` // Flights whose departureDate isn't YYYY-MM-DD
+}
+
+// PeriodCount is the number of flights departing in one period, e.g. "2026-01" or "2026"
+type PeriodCount struct {
+	Period string `json:"period"`
+	Count  int    `json:"count"`
+}
+
+// FlightsByPeriod buckets flights by the month ("2006-01") or year ("2006") of their departure date.
+// Dates that don't parse are counted in Unparseable rather than failing the whole timeline.
+func FlightsByPeriod(flights []BoardingPass, bucket string) (*Timeline, error) {
+	var layout string
+	switch bucket {
+	case BucketMonth:
+		layout = "2006-01"
+	case BucketYear:
+		layout = "2006"
+	default:
+		return nil, fmt.Errorf("unknown bucket %q (expected %s or %s)", bucket, BucketMonth, BucketYear)
+	}
+
+	timeline := &Timeline{Bucket: bucket, Periods: []PeriodCount{}}
+	counts := make(map[string]int)
+	for _, flight := range flights {
+		date, err := time.Parse("2006-01-02", flight.DepartureDate)
+		if err != nil {
+			timeline.Unparseable++
+			continue
+		}
+		counts[date.Format(layout)]++
+	}
+
+	for period, count := range counts {
+		timeline.Periods = append(timeline.Periods, PeriodCount{Period: period, Count: count})
+	}
+	sort.Slice(timeline.Periods, func(i, j int) bool {
+		return timeline.Periods[i].Period < timeline.Periods[j].Period
+	})
+	return timeline, nil
+}
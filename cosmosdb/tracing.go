@@ -0,0 +1,31 @@
+package cosmosdb
+
+import (
+	"context"
+
+	"github.com/abhirockzz/flight-log-app/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates spans for Cosmos DB queries; it is a no-op unless tracing.Setup installed a provider
+var tracer = otel.Tracer("github.com/abhirockzz/flight-log-app/cosmosdb")
+
+// startQuerySpan starts a client span for a query, named after the metrics operation (e.g. "list_flights").
+// Queries are parameterized, so the text holds no user values.
+func startQuerySpan(ctx context.Context, operation, query string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "cosmosdb."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "cosmosdb"),
+			attribute.String("db.operation.name", operation),
+			attribute.String("db.query.text", query),
+		))
+}
+
+// endQuerySpan records the query's RU charge and error, if any, and ends the span
+func endQuerySpan(span trace.Span, requestCharge float32, err error) {
+	span.SetAttributes(attribute.Float64("db.cosmosdb.request_charge", float64(requestCharge)))
+	tracing.End(span, err)
+}
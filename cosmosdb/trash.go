@@ -0,0 +1,154 @@
+package cosmosdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azcosmos"
+	"github.com/abhirockzz/flight-log-app/metrics"
+)
+
+// notDeletedFilter excludes trashed flights. The deleted property is omitted from active
+// flights (omitempty), so IS_DEFINED also matches items written before soft-delete existed.
+const notDeletedFilter = "NOT IS_DEFINED(c.deleted)"
+
+// DeleteFlight moves a flight to the trash by marking it deleted. Use PurgeFlight to remove it permanently.
+// It returns the flight as it was before deletion, so re-saving it undoes the delete.
+// When ifMatch is non-empty the flight is only trashed if its ETag still matches, otherwise
+// ErrPreconditionFailed is returned. Returns ErrNotFound if the flight does not exist or is
+// already in the trash.
+func (c *Client) DeleteFlight(ctx context.Context, id, email, ifMatch string) (*BoardingPass, error) {
+	flight, err := c.GetFlight(ctx, id, email)
+	if err != nil {
+		return nil, err
+	}
+	if ifMatch != "" && flight.ETag != ifMatch {
+		return nil, ErrPreconditionFailed
+	}
+
+	trashed := *flight
+	trashed.Deleted = true
+	trashed.DeletedAt = time.Now().UTC().Format(time.RFC3339)
+	if err := c.replaceFlight(ctx, "soft_delete", &trashed, ifMatch); err != nil {
+		return nil, err
+	}
+	return flight, nil
+}
+
+// RestoreFlight moves a flight out of the trash.
+// Returns ErrNotFound if the flight does not exist or is not in the trash.
+func (c *Client) RestoreFlight(ctx context.Context, id, email string) (*BoardingPass, error) {
+	flight, err := c.readFlight(ctx, id, email)
+	if err != nil {
+		return nil, err
+	}
+	if !flight.Deleted {
+		return nil, ErrNotFound
+	}
+
+	flight.Deleted = false
+	flight.DeletedAt = ""
+	if err := c.replaceFlight(ctx, "restore", flight, ""); err != nil {
+		return nil, err
+	}
+	return flight, nil
+}
+
+// ListDeletedFlights returns the user's trashed flights, most recently deleted first
+func (c *Client) ListDeletedFlights(ctx context.Context, email string) ([]BoardingPass, error) {
+	if email == "" {
+		return nil, errors.New("email is required")
+	}
+
+	pk := azcosmos.NewPartitionKeyString(email)
+
+	query := "SELECT * FROM c WHERE " + c.partitionFilter() + " AND c.deleted = true"
+	queryOptions := &azcosmos.QueryOptions{
+		QueryParameters: []azcosmos.QueryParameter{
+			{Name: "@pk", Value: email},
+		},
+	}
+
+	items, err := c.queryItems(ctx, "list_deleted_flights", query, pk, queryOptions)
+	if err != nil {
+		return nil, err
+	}
+	flights := decodeFlights(items)
+
+	sort.Slice(flights, func(i, j int) bool {
+		return flights[i].DeletedAt > flights[j].DeletedAt
+	})
+
+	return flights, nil
+}
+
+// PurgeDeletedFlights permanently removes every user's flights that were trashed before cutoff
+// and returns them as they were before they were trashed, so callers can clean up their images.
+// Like AdminStats this is a cross-partition query; run it from a periodic sweep, not per request.
+// On error the flights purged so far are returned with it.
+func (c *Client) PurgeDeletedFlights(ctx context.Context, cutoff time.Time) ([]BoardingPass, error) {
+	// SELECT * so the configured partition value extractor always has its field
+	query := "SELECT * FROM c WHERE c.deleted = true AND c.deletedAt < @cutoff"
+	queryOptions := &azcosmos.QueryOptions{
+		QueryParameters: []azcosmos.QueryParameter{
+			{Name: "@cutoff", Value: cutoff.UTC().Format(time.RFC3339)},
+		},
+	}
+
+	// An empty partition key fans the query out across all partitions
+	items, err := c.queryItems(ctx, "find_purgeable_flights", query, azcosmos.NewPartitionKey(), queryOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	var purged []BoardingPass
+	for _, flight := range decodeFlights(items) {
+		removed, err := c.PurgeFlight(ctx, flight.ID, c.partitionValue(&flight))
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return purged, fmt.Errorf("failed to purge flight %s: %w", flight.ID, err)
+		}
+		purged = append(purged, *removed)
+	}
+	return purged, nil
+}
+
+// replaceFlight overwrites a stored flight, recording metrics under the given operation name.
+// A non-empty ifMatch makes the write conditional on the stored ETag. On success flight.ETag is updated.
+func (c *Client) replaceFlight(ctx context.Context, operation string, flight *BoardingPass, ifMatch string) error {
+	data, err := json.Marshal(flight)
+	if err != nil {
+		return err
+	}
+
+	pk := azcosmos.NewPartitionKeyString(c.partitionValue(flight))
+
+	var opts *azcosmos.ItemOptions
+	if ifMatch != "" {
+		etag := azcore.ETag(ifMatch)
+		opts = &azcosmos.ItemOptions{IfMatchEtag: &etag}
+	}
+
+	start := time.Now()
+	response, err := c.container.ReplaceItem(ctx, pk, flight.ID, data, opts)
+	metrics.ObserveCosmos(operation, start, response.RequestCharge, err)
+	if isNotFound(err) {
+		return ErrNotFound
+	}
+	if isPreconditionFailed(err) {
+		return ErrPreconditionFailed
+	}
+	if err != nil {
+		return err
+	}
+
+	flight.ETag = string(response.ETag)
+	return nil
+}
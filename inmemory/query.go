@@ -0,0 +1,785 @@
+package inmemory
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// This file runs the subset of Cosmos DB SQL that the chat generates for one user's flights:
+//
+//	SELECT [DISTINCT] [TOP n] * | VALUE COUNT(1) | VALUE c.field | c.field [AS name], ...
+//	FROM c
+//	[WHERE condition [AND condition]...]
+//	[ORDER BY c.field [ASC|DESC], ...]
+//	[OFFSET n LIMIT n]
+//
+// A condition compares a field (optionally wrapped in UPPER or LOWER) with a literal, or calls
+// CONTAINS, STARTSWITH, ENDSWITH, ARRAY_CONTAINS, or [NOT] IS_DEFINED. Anything else, such as OR,
+// GROUP BY, or JOIN, fails with ErrQueryUnsupported.
+
+// sqlQuery is a parsed query
+type sqlQuery struct {
+	distinct bool
+	top      int // 0 means no TOP
+	count    bool
+	value    []string     // Path for SELECT VALUE c.field
+	fields   []projection // Nil for SELECT *
+	where    []condition  // All must hold
+	orderBy  []ordering
+	offset   int
+	limit    int // -1 means no LIMIT
+}
+
+// projection is one selected field
+type projection struct {
+	path []string
+	name string
+}
+
+// ordering is one ORDER BY field
+type ordering struct {
+	path []string
+	desc bool
+}
+
+// condition reports whether a document matches one WHERE predicate
+type condition func(doc map[string]any) bool
+
+// operand is a field path with optional case folding, as used on either side of a comparison
+type operand struct {
+	path []string
+	fold func(string) string // strings.ToUpper or strings.ToLower; nil leaves the value as is
+}
+
+// value returns the operand's value in doc, and false when the field is undefined
+func (o operand) value(doc map[string]any) (any, bool) {
+	v, ok := lookupPath(doc, o.path)
+	if !ok {
+		return nil, false
+	}
+	if o.fold != nil {
+		s, isString := v.(string)
+		if !isString {
+			return nil, false
+		}
+		return o.fold(s), true
+	}
+	return v, true
+}
+
+// lookupPath walks nested objects in doc, returning false if a segment is missing
+func lookupPath(doc map[string]any, path []string) (any, bool) {
+	var v any = doc
+	for _, key := range path {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if v, ok = obj[key]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// runQuery evaluates query against docs, returning the JSON results Cosmos DB would
+func runQuery(query string, docs []map[string]any) ([]json.RawMessage, error) {
+	q, err := parseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := make([]map[string]any, 0, len(docs))
+	for _, doc := range docs {
+		if q.matches(doc) {
+			matched = append(matched, doc)
+		}
+	}
+
+	if q.count {
+		return []json.RawMessage{json.RawMessage(strconv.Itoa(len(matched)))}, nil
+	}
+
+	if len(q.orderBy) > 0 {
+		sort.SliceStable(matched, func(i, j int) bool { return q.less(matched[i], matched[j]) })
+	}
+
+	results := make([]json.RawMessage, 0, len(matched))
+	seen := make(map[string]bool)
+	for _, doc := range matched {
+		out, ok := q.project(doc)
+		if !ok {
+			continue
+		}
+		raw, err := json.Marshal(out)
+		if err != nil {
+			return nil, err
+		}
+		if q.distinct {
+			if seen[string(raw)] {
+				continue
+			}
+			seen[string(raw)] = true
+		}
+		results = append(results, raw)
+	}
+
+	if q.offset > 0 {
+		results = results[min(q.offset, len(results)):]
+	}
+	if q.limit >= 0 && q.limit < len(results) {
+		results = results[:q.limit]
+	}
+	if q.top > 0 && q.top < len(results) {
+		results = results[:q.top]
+	}
+	return results, nil
+}
+
+// matches reports whether doc satisfies every WHERE condition
+func (q *sqlQuery) matches(doc map[string]any) bool {
+	for _, cond := range q.where {
+		if !cond(doc) {
+			return false
+		}
+	}
+	return true
+}
+
+// less orders documents by the ORDER BY fields. Documents missing a field sort after those that have it.
+func (q *sqlQuery) less(a, b map[string]any) bool {
+	for _, o := range q.orderBy {
+		va, okA := lookupPath(a, o.path)
+		vb, okB := lookupPath(b, o.path)
+		if !okA || !okB {
+			if okA != okB {
+				return okA
+			}
+			continue
+		}
+		c, ok := compareValues(va, vb)
+		if !ok || c == 0 {
+			continue
+		}
+		if o.desc {
+			return c > 0
+		}
+		return c < 0
+	}
+	return false
+}
+
+// project builds the selected result for doc. SELECT VALUE of an undefined field yields nothing.
+func (q *sqlQuery) project(doc map[string]any) (any, bool) {
+	switch {
+	case q.value != nil:
+		return lookupPath(doc, q.value)
+	case q.fields == nil:
+		return doc, true
+	}
+	out := make(map[string]any, len(q.fields))
+	for _, f := range q.fields {
+		if v, ok := lookupPath(doc, f.path); ok {
+			out[f.name] = v
+		}
+	}
+	return out, true
+}
+
+// compareValues compares two strings or two numbers; other combinations are not comparable
+func compareValues(a, b any) (int, bool) {
+	switch x := a.(type) {
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y), true
+		}
+	case float64:
+		if y, ok := b.(float64); ok {
+			switch {
+			case x < y:
+				return -1, true
+			case x > y:
+				return 1, true
+			}
+			return 0, true
+		}
+	case bool:
+		if y, ok := b.(bool); ok && x == y {
+			return 0, true
+		} else if ok {
+			return 1, true // Only = and != are meaningful for booleans
+		}
+	case nil:
+		if b == nil {
+			return 0, true
+		}
+	}
+	return 0, false
+}
+
+// token is one lexical element of a query
+type token struct {
+	kind byte // 'i' identifier or keyword, 's' string literal, 'n' number, or the punctuation itself ('o' for operators)
+	text string
+}
+
+// tokenize splits query into tokens. String literals are unescaped.
+func tokenize(query string) ([]token, error) {
+	var tokens []token
+	runes := []rune(query)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '\'' || r == '"':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(runes) && runes[j] != r; j++ {
+				if runes[j] == '\\' && j+1 < len(runes) {
+					j++
+				}
+				b.WriteRune(runes[j])
+			}
+			if j == len(runes) {
+				return nil, fmt.Errorf("%w: unterminated string literal", ErrQueryUnsupported)
+			}
+			tokens = append(tokens, token{'s', b.String()})
+			i = j + 1
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			j := i + 1
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, token{'n', string(runes[i:j])})
+			i = j
+		case unicode.IsLetter(r) || r == '_':
+			j := i + 1
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_') {
+				j++
+			}
+			tokens = append(tokens, token{'i', string(runes[i:j])})
+			i = j
+		case strings.ContainsRune("=<>!", r):
+			j := i + 1
+			if j < len(runes) && strings.ContainsRune("=>", runes[j]) {
+				j++
+			}
+			tokens = append(tokens, token{'o', string(runes[i:j])})
+			i = j
+		case strings.ContainsRune("(),.*[]", r):
+			tokens = append(tokens, token{byte(r), string(r)})
+			i++
+		default:
+			return nil, fmt.Errorf("%w: unexpected character %q", ErrQueryUnsupported, r)
+		}
+	}
+	return tokens, nil
+}
+
+// parser reads a query from its tokens
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// parseQuery parses the supported subset of Cosmos DB SQL
+func parseQuery(query string) (*sqlQuery, error) {
+	tokens, err := tokenize(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	q := &sqlQuery{limit: -1}
+
+	if err := p.expectKeyword("SELECT"); err != nil {
+		return nil, err
+	}
+	q.distinct = p.keyword("DISTINCT")
+	if p.keyword("TOP") {
+		if q.top, err = p.integer(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.parseSelection(q); err != nil {
+		return nil, err
+	}
+
+	if err := p.expectKeyword("FROM"); err != nil {
+		return nil, err
+	}
+	if err := p.expectKeyword("c"); err != nil {
+		return nil, err
+	}
+
+	if p.keyword("WHERE") {
+		for {
+			cond, err := p.parseCondition()
+			if err != nil {
+				return nil, err
+			}
+			q.where = append(q.where, cond)
+			if !p.keyword("AND") {
+				break
+			}
+		}
+	}
+
+	if p.keyword("ORDER") {
+		if err := p.expectKeyword("BY"); err != nil {
+			return nil, err
+		}
+		for {
+			path, err := p.parsePath()
+			if err != nil {
+				return nil, err
+			}
+			o := ordering{path: path}
+			if p.keyword("DESC") {
+				o.desc = true
+			} else {
+				p.keyword("ASC")
+			}
+			q.orderBy = append(q.orderBy, o)
+			if !p.punct(',') {
+				break
+			}
+		}
+	}
+
+	if p.keyword("OFFSET") {
+		if q.offset, err = p.integer(); err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("LIMIT"); err != nil {
+			return nil, err
+		}
+		if q.limit, err = p.integer(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.pos < len(p.tokens) {
+		return nil, p.unexpected()
+	}
+	return q, nil
+}
+
+// parseSelection parses what follows SELECT [DISTINCT] [TOP n]
+func (p *parser) parseSelection(q *sqlQuery) error {
+	if p.punct('*') {
+		return nil
+	}
+	if p.keyword("VALUE") {
+		if p.keyword("COUNT") {
+			q.count = true
+			return p.parseCountArg()
+		}
+		path, err := p.parsePath()
+		q.value = path
+		return err
+	}
+	q.fields = []projection{}
+	for {
+		path, err := p.parsePath()
+		if err != nil {
+			return err
+		}
+		f := projection{path: path, name: path[len(path)-1]}
+		if p.keyword("AS") {
+			if f.name, err = p.identifier(); err != nil {
+				return err
+			}
+		}
+		q.fields = append(q.fields, f)
+		if !p.punct(',') {
+			return nil
+		}
+	}
+}
+
+// parseCountArg parses the (1) of COUNT(1)
+func (p *parser) parseCountArg() error {
+	if !p.punct('(') {
+		return p.unexpected()
+	}
+	if _, err := p.integer(); err != nil {
+		return err
+	}
+	if !p.punct(')') {
+		return p.unexpected()
+	}
+	return nil
+}
+
+// parseCondition parses one WHERE predicate
+func (p *parser) parseCondition() (condition, error) {
+	if p.keyword("NOT") {
+		if !p.keyword("IS_DEFINED") {
+			return nil, p.unexpected()
+		}
+		defined, err := p.parseIsDefined()
+		if err != nil {
+			return nil, err
+		}
+		return func(doc map[string]any) bool { return !defined(doc) }, nil
+	}
+	if p.keyword("IS_DEFINED") {
+		return p.parseIsDefined()
+	}
+	for _, name := range []string{"CONTAINS", "STARTSWITH", "ENDSWITH"} {
+		if p.keyword(name) {
+			return p.parseStringFunc(name)
+		}
+	}
+	if p.keyword("ARRAY_CONTAINS") {
+		return p.parseArrayContains()
+	}
+
+	// A comparison, with the field on either side
+	if p.peek().kind == 's' || p.peek().kind == 'n' || p.peekKeyword("true", "false", "null") {
+		literal, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		op, err := p.operator()
+		if err != nil {
+			return nil, err
+		}
+		field, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return comparison(field, flipOperator(op), literal), nil
+	}
+	field, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	op, err := p.operator()
+	if err != nil {
+		return nil, err
+	}
+	literal, err := p.parseLiteral()
+	if err != nil {
+		return nil, err
+	}
+	return comparison(field, op, literal), nil
+}
+
+// comparison builds a condition comparing a field with a literal. An undefined field or a
+// literal of another type never matches, as in Cosmos DB.
+func comparison(field operand, op string, literal any) condition {
+	return func(doc map[string]any) bool {
+		v, ok := field.value(doc)
+		if !ok {
+			return false
+		}
+		c, ok := compareValues(v, literal)
+		if !ok {
+			return false
+		}
+		switch op {
+		case "=":
+			return c == 0
+		case "!=", "<>":
+			return c != 0
+		case "<":
+			return c < 0
+		case "<=":
+			return c <= 0
+		case ">":
+			return c > 0
+		default: // ">="
+			return c >= 0
+		}
+	}
+}
+
+// flipOperator returns the operator for swapped operands, so 'x' < c.f becomes c.f > 'x'
+func flipOperator(op string) string {
+	switch op {
+	case "<":
+		return ">"
+	case "<=":
+		return ">="
+	case ">":
+		return "<"
+	case ">=":
+		return "<="
+	}
+	return op
+}
+
+// parseIsDefined parses the (c.field) of IS_DEFINED
+func (p *parser) parseIsDefined() (condition, error) {
+	if !p.punct('(') {
+		return nil, p.unexpected()
+	}
+	path, err := p.parsePath()
+	if err != nil {
+		return nil, err
+	}
+	if !p.punct(')') {
+		return nil, p.unexpected()
+	}
+	return func(doc map[string]any) bool {
+		_, ok := lookupPath(doc, path)
+		return ok
+	}, nil
+}
+
+// parseStringFunc parses the arguments of CONTAINS, STARTSWITH, or ENDSWITH, including the
+// optional third argument that makes the match case-insensitive
+func (p *parser) parseStringFunc(name string) (condition, error) {
+	if !p.punct('(') {
+		return nil, p.unexpected()
+	}
+	field, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if !p.punct(',') {
+		return nil, p.unexpected()
+	}
+	tok := p.next()
+	if tok.kind != 's' {
+		return nil, p.unexpectedToken(tok)
+	}
+	ignoreCase := false
+	if p.punct(',') {
+		if ignoreCase = p.keyword("true"); !ignoreCase && !p.keyword("false") {
+			return nil, p.unexpected()
+		}
+	}
+	if !p.punct(')') {
+		return nil, p.unexpected()
+	}
+
+	match := map[string]func(s, substr string) bool{
+		"CONTAINS":   strings.Contains,
+		"STARTSWITH": strings.HasPrefix,
+		"ENDSWITH":   strings.HasSuffix,
+	}[name]
+	return func(doc map[string]any) bool {
+		v, ok := field.value(doc)
+		s, isString := v.(string)
+		if !ok || !isString {
+			return false
+		}
+		if ignoreCase {
+			return match(strings.ToLower(s), strings.ToLower(tok.text))
+		}
+		return match(s, tok.text)
+	}, nil
+}
+
+// parseArrayContains parses the arguments of ARRAY_CONTAINS(c.field, literal)
+func (p *parser) parseArrayContains() (condition, error) {
+	if !p.punct('(') {
+		return nil, p.unexpected()
+	}
+	path, err := p.parsePath()
+	if err != nil {
+		return nil, err
+	}
+	if !p.punct(',') {
+		return nil, p.unexpected()
+	}
+	literal, err := p.parseLiteral()
+	if err != nil {
+		return nil, err
+	}
+	if !p.punct(')') {
+		return nil, p.unexpected()
+	}
+	return func(doc map[string]any) bool {
+		v, _ := lookupPath(doc, path)
+		items, _ := v.([]any)
+		for _, item := range items {
+			if c, ok := compareValues(item, literal); ok && c == 0 {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// parseOperand parses c.field, optionally wrapped in UPPER() or LOWER()
+func (p *parser) parseOperand() (operand, error) {
+	var fold func(string) string
+	switch {
+	case p.keyword("UPPER"):
+		fold = strings.ToUpper
+	case p.keyword("LOWER"):
+		fold = strings.ToLower
+	default:
+		path, err := p.parsePath()
+		return operand{path: path}, err
+	}
+	if !p.punct('(') {
+		return operand{}, p.unexpected()
+	}
+	path, err := p.parsePath()
+	if err != nil {
+		return operand{}, err
+	}
+	if !p.punct(')') {
+		return operand{}, p.unexpected()
+	}
+	return operand{path: path, fold: fold}, nil
+}
+
+// parsePath parses c.field, c["field"], or a nested path such as c.a.b
+func (p *parser) parsePath() ([]string, error) {
+	if err := p.expectKeyword("c"); err != nil {
+		return nil, err
+	}
+	var path []string
+	for {
+		switch {
+		case p.punct('.'):
+			name, err := p.identifier()
+			if err != nil {
+				return nil, err
+			}
+			path = append(path, name)
+		case p.punct('['):
+			tok := p.next()
+			if tok.kind != 's' || !p.punct(']') {
+				return nil, p.unexpectedToken(tok)
+			}
+			path = append(path, tok.text)
+		default:
+			if len(path) == 0 {
+				return nil, p.unexpected()
+			}
+			return path, nil
+		}
+	}
+}
+
+// parseLiteral parses a string, number, true, false, or null
+func (p *parser) parseLiteral() (any, error) {
+	tok := p.next()
+	switch {
+	case tok.kind == 's':
+		return tok.text, nil
+	case tok.kind == 'n':
+		n, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, p.unexpectedToken(tok)
+		}
+		return n, nil
+	case tok.kind == 'i' && strings.EqualFold(tok.text, "true"):
+		return true, nil
+	case tok.kind == 'i' && strings.EqualFold(tok.text, "false"):
+		return false, nil
+	case tok.kind == 'i' && strings.EqualFold(tok.text, "null"):
+		return nil, nil
+	}
+	return nil, p.unexpectedToken(tok)
+}
+
+// operator reads a comparison operator
+func (p *parser) operator() (string, error) {
+	tok := p.next()
+	switch tok.text {
+	case "=", "!=", "<>", "<", "<=", ">", ">=":
+		return tok.text, nil
+	}
+	return "", p.unexpectedToken(tok)
+}
+
+// integer reads a non-negative whole number
+func (p *parser) integer() (int, error) {
+	tok := p.next()
+	n, err := strconv.Atoi(tok.text)
+	if tok.kind != 'n' || err != nil || n < 0 {
+		return 0, p.unexpectedToken(tok)
+	}
+	return n, nil
+}
+
+// identifier reads a field or alias name
+func (p *parser) identifier() (string, error) {
+	tok := p.next()
+	if tok.kind != 'i' {
+		return "", p.unexpectedToken(tok)
+	}
+	return tok.text, nil
+}
+
+// peek returns the next token without consuming it; the zero token at the end
+func (p *parser) peek() token {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return token{}
+}
+
+// next consumes and returns the next token; the zero token at the end
+func (p *parser) next() token {
+	tok := p.peek()
+	if p.pos < len(p.tokens) {
+		p.pos++
+	}
+	return tok
+}
+
+// peekKeyword reports whether the next token is one of the keywords, in any case
+func (p *parser) peekKeyword(keywords ...string) bool {
+	tok := p.peek()
+	if tok.kind != 'i' {
+		return false
+	}
+	for _, kw := range keywords {
+		if strings.EqualFold(tok.text, kw) {
+			return true
+		}
+	}
+	return false
+}
+
+// keyword consumes the next token if it is kw, in any case
+func (p *parser) keyword(kw string) bool {
+	if p.peekKeyword(kw) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// expectKeyword consumes kw or fails
+func (p *parser) expectKeyword(kw string) error {
+	if !p.keyword(kw) {
+		return p.unexpected()
+	}
+	return nil
+}
+
+// punct consumes the next token if it is the punctuation r
+func (p *parser) punct(r byte) bool {
+	if p.peek().kind == r {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// unexpected reports the next token as unsupported
+func (p *parser) unexpected() error {
+	return p.unexpectedToken(p.peek())
+}
+
+// unexpectedToken reports tok as unsupported
+func (p *parser) unexpectedToken(tok token) error {
+	if tok.kind == 0 {
+		return fmt.Errorf("%w: unexpected end of query", ErrQueryUnsupported)
+	}
+	return fmt.Errorf("%w: unexpected %q", ErrQueryUnsupported, tok.text)
+}
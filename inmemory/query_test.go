@@ -0,0 +1,92 @@
+package inmemory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/abhirockzz/flight-log-app/cosmosdb"
+)
+
+func TestExecuteRawQuery(t *testing.T) {
+	ctx := context.Background()
+	const email = "alice@example.com"
+	s := New()
+	for _, f := range []cosmosdb.BoardingPass{
+		{ID: "1", Email: email, FlightNumber: "UA 1", Airline: "United", FromAirport: "SFO", ToAirport: "JFK", DepartureDate: "2026-01-10", Tags: []string{"work"}},
+		{ID: "2", Email: email, FlightNumber: "DL 2", Airline: "Delta", FromAirport: "JFK", ToAirport: "LAX", DepartureDate: "2026-02-20"},
+		{ID: "3", Email: email, FlightNumber: "UA 3", Airline: "United", FromAirport: "LAX", ToAirport: "SFO", DepartureDate: "2026-03-05"},
+		{ID: "4", Email: "bob@example.com", FlightNumber: "AA 4", Airline: "American", FromAirport: "SFO", ToAirport: "JFK", DepartureDate: "2026-01-01"},
+	} {
+		if _, err := s.InsertFlight(ctx, &f); err != nil {
+			t.Fatalf("InsertFlight: %v", err)
+		}
+	}
+	if _, err := s.DeleteFlight(ctx, "3", email, ""); err != nil {
+		t.Fatalf("DeleteFlight: %v", err)
+	}
+
+	tests := []struct {
+		query string
+		want  string // Results joined with commas
+	}{
+		{"SELECT c.id FROM c WHERE c.email = 'alice@example.com' AND NOT IS_DEFINED(c.deleted)", `{"id":"2"},{"id":"1"}`},
+		{"SELECT c.id FROM c WHERE c.email = 'alice@example.com'", `{"id":"3"},{"id":"2"},{"id":"1"}`},
+		{"SELECT c.id FROM c WHERE c.email = 'alice@example.com' AND IS_DEFINED(c.deleted)", `{"id":"3"}`},
+		{"SELECT VALUE COUNT(1) FROM c WHERE c.email = 'alice@example.com' AND NOT IS_DEFINED(c.deleted)", `2`},
+		{"select c.id, c.airline as carrier from c where 'alice@example.com' = c.email and c.toAirport = 'JFK'", `{"carrier":"United","id":"1"}`},
+		{"SELECT c.id FROM c WHERE c.email = 'alice@example.com' ORDER BY c.departureDate ASC", `{"id":"1"},{"id":"2"},{"id":"3"}`},
+		{"SELECT TOP 1 c.id FROM c WHERE c.email = 'alice@example.com' ORDER BY c.departureDate DESC", `{"id":"3"}`},
+		{"SELECT c.id FROM c WHERE c.email = 'alice@example.com' ORDER BY c.departureDate OFFSET 1 LIMIT 1", `{"id":"2"}`},
+		{"SELECT c.id FROM c WHERE c.email = 'alice@example.com' AND c.departureDate >= '2026-02-01' AND c.departureDate < '2026-03-01'", `{"id":"2"}`},
+		{"SELECT c.id FROM c WHERE c.email = 'alice@example.com' AND CONTAINS(c.airline, 'delta', true)", `{"id":"2"}`},
+		{"SELECT c.id FROM c WHERE c.email = 'alice@example.com' AND CONTAINS(UPPER(c.airline), 'UNIT') AND c.id != '3'", `{"id":"1"}`},
+		{"SELECT c.id FROM c WHERE c.email = 'alice@example.com' AND STARTSWITH(c.flightNumber, 'DL')", `{"id":"2"}`},
+		{"SELECT c.id FROM c WHERE c.email = 'alice@example.com' AND ARRAY_CONTAINS(c.tags, 'work')", `{"id":"1"}`},
+		{`SELECT c.id FROM c WHERE c["email"] = 'alice@example.com' AND c["airline"] = 'Delta'`, `{"id":"2"}`},
+		{"SELECT DISTINCT VALUE c.airline FROM c WHERE c.email = 'alice@example.com'", `"United","Delta"`},
+		{"SELECT c.id FROM c WHERE c.email = 'bob@example.com'", ``},
+	}
+
+	for _, tt := range tests {
+		results, err := s.ExecuteRawQuery(ctx, tt.query, email)
+		if err != nil {
+			t.Errorf("ExecuteRawQuery(%q): %v", tt.query, err)
+			continue
+		}
+		got := make([]string, len(results))
+		for i, r := range results {
+			got[i] = string(r)
+		}
+		if strings.Join(got, ",") != tt.want {
+			t.Errorf("ExecuteRawQuery(%q) = %s, want %s", tt.query, strings.Join(got, ","), tt.want)
+		}
+	}
+
+	// The whole document comes back for SELECT *
+	results, err := s.ExecuteRawQuery(ctx, "SELECT * FROM c WHERE c.email = 'alice@example.com' AND c.id = '1'", email)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("SELECT * = %d results, %v; want 1", len(results), err)
+	}
+	var flight cosmosdb.BoardingPass
+	if err := json.Unmarshal(results[0], &flight); err != nil || flight.FlightNumber != "UA 1" {
+		t.Fatalf("SELECT * result = %s, %v; want UA 1", results[0], err)
+	}
+}
+
+func TestExecuteRawQueryUnsupported(t *testing.T) {
+	s := New()
+	for _, query := range []string{
+		"SELECT * FROM c WHERE c.email = 'alice@example.com' OR c.airline = 'Delta'",
+		"SELECT c.airline, COUNT(1) AS count FROM c WHERE c.email = 'alice@example.com' GROUP BY c.airline",
+		"SELECT t FROM c JOIN t IN c.tags WHERE c.email = 'alice@example.com'",
+		"DELETE FROM c",
+		"SELECT * FROM c WHERE c.email = 'alice@example.com",
+	} {
+		if _, err := s.ExecuteRawQuery(context.Background(), query, "alice@example.com"); !errors.Is(err, ErrQueryUnsupported) {
+			t.Errorf("ExecuteRawQuery(%q) = %v, want ErrQueryUnsupported", query, err)
+		}
+	}
+}
@@ -0,0 +1,619 @@
+// Package inmemory is a FlightStore kept in process memory, for running the app and its
+// handlers without a Cosmos DB instance. Data is lost when the process exits.
+package inmemory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abhirockzz/flight-log-app/airports"
+	"github.com/abhirockzz/flight-log-app/cosmosdb"
+	"github.com/google/uuid"
+)
+
+// topRoutesLimit is the number of routes returned by AdminStats, matching the Cosmos DB store
+const topRoutesLimit = 10
+
+// ErrQueryUnsupported is returned for Cosmos DB SQL outside the subset this store can run (see
+// query.go), such as OR, GROUP BY, or JOIN
+var ErrQueryUnsupported = errors.New("query is not supported by the in-memory store")
+
+// Store holds flights in memory, partitioned by email like the Cosmos DB container
+type Store struct {
+	mu      sync.RWMutex
+	flights map[string]map[string]cosmosdb.BoardingPass // email -> id -> flight
+}
+
+// Store must stay interchangeable with the Cosmos DB client
+var _ cosmosdb.FlightStore = (*Store)(nil)
+
+// New creates an empty store
+func New() *Store {
+	return &Store{flights: make(map[string]map[string]cosmosdb.BoardingPass)}
+}
+
+// newETag returns a fresh opaque ETag for a write
+func newETag() string {
+	return fmt.Sprintf("%q", uuid.New().String())
+}
+
+// active returns the user's flights that are not in the trash, in no particular order.
+// The caller must hold s.mu.
+func (s *Store) active(email string) []cosmosdb.BoardingPass {
+	flights := make([]cosmosdb.BoardingPass, 0, len(s.flights[email]))
+	for _, f := range s.flights[email] {
+		if !f.Deleted {
+			flights = append(flights, f)
+		}
+	}
+	return flights
+}
+
+// put stores a copy of flight with a new ETag, derived seat fields, and normalized tags. The caller must hold s.mu for writing.
+func (s *Store) put(flight *cosmosdb.BoardingPass) {
+	flight.ETag = newETag()
+	flight.DeriveSeatFields()
+	flight.NormalizeTags()
+	if s.flights[flight.Email] == nil {
+		s.flights[flight.Email] = make(map[string]cosmosdb.BoardingPass)
+	}
+	s.flights[flight.Email][flight.ID] = *flight
+}
+
+// sortByDateDesc orders flights newest departure first
+func sortByDateDesc(flights []cosmosdb.BoardingPass) {
+	sort.Slice(flights, func(i, j int) bool {
+		return flights[i].DepartureDate > flights[j].DepartureDate
+	})
+}
+
+// SaveFlight stores a flight unless the user already has one with the same flight number and
+// departure date, in which case the existing flight is returned with ErrDuplicateFlight
+func (s *Store) SaveFlight(ctx context.Context, flight *cosmosdb.BoardingPass) (*cosmosdb.BoardingPass, error) {
+	if flight.Email == "" {
+		return nil, errors.New("email is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing := s.findDuplicate(flight); existing != nil {
+		return existing, cosmosdb.ErrDuplicateFlight
+	}
+	return s.insert(flight)
+}
+
+// FindDuplicate returns the active flight with the same flight number and departure date, or nil
+func (s *Store) FindDuplicate(ctx context.Context, flight *cosmosdb.BoardingPass) (*cosmosdb.BoardingPass, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.findDuplicate(flight), nil
+}
+
+// findDuplicate is FindDuplicate for callers that hold s.mu
+func (s *Store) findDuplicate(flight *cosmosdb.BoardingPass) *cosmosdb.BoardingPass {
+	for _, existing := range s.active(flight.Email) {
+		if existing.FlightNumber == flight.FlightNumber && existing.DepartureDate == flight.DepartureDate {
+			return &existing
+		}
+	}
+	return nil
+}
+
+// InsertFlight stores a flight without checking for duplicates
+func (s *Store) InsertFlight(ctx context.Context, flight *cosmosdb.BoardingPass) (*cosmosdb.BoardingPass, error) {
+	if flight.Email == "" {
+		return nil, errors.New("email is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.insert(flight)
+}
+
+// insert stores a new flight. Re-inserting a trashed flight's ID restores it; a live one is a duplicate.
+// The caller must hold s.mu for writing.
+func (s *Store) insert(flight *cosmosdb.BoardingPass) (*cosmosdb.BoardingPass, error) {
+	if flight.ID == "" {
+		flight.ID = uuid.New().String()
+	}
+	if flight.CreatedAt == "" {
+		flight.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+	if existing, ok := s.flights[flight.Email][flight.ID]; ok && !existing.Deleted {
+		return &existing, cosmosdb.ErrDuplicateFlight
+	}
+
+	flight.Deleted = false
+	flight.DeletedAt = ""
+	s.put(flight)
+	return flight, nil
+}
+
+// SaveFlightsBatch stores flights that share an email, skipping duplicates with ErrDuplicateFlight.
+// The returned error joins the per-item failures; the saved flights are returned either way.
+func (s *Store) SaveFlightsBatch(ctx context.Context, flights []*cosmosdb.BoardingPass) ([]cosmosdb.BoardingPass, error) {
+	if len(flights) == 0 {
+		return []cosmosdb.BoardingPass{}, nil
+	}
+	email := flights[0].Email
+	if email == "" {
+		return nil, errors.New("email is required")
+	}
+	for _, flight := range flights {
+		if flight.Email != email {
+			return nil, errors.New("all flights in a batch must share the same email")
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing := make(map[string]bool)
+	for _, f := range s.active(email) {
+		existing[f.FlightNumber+"|"+f.DepartureDate] = true
+	}
+
+	var errs []error
+	saved := make([]cosmosdb.BoardingPass, 0, len(flights))
+	for _, flight := range flights {
+		key := flight.FlightNumber + "|" + flight.DepartureDate
+		if existing[key] {
+			errs = append(errs, &cosmosdb.ItemError{Flight: flight, Err: cosmosdb.ErrDuplicateFlight})
+			continue
+		}
+		existing[key] = true
+
+		if _, err := s.insert(flight); err != nil {
+			errs = append(errs, &cosmosdb.ItemError{Flight: flight, Err: err})
+			continue
+		}
+		saved = append(saved, *flight)
+	}
+	return saved, errors.Join(errs...)
+}
+
+// GetFlight returns a flight by ID, or ErrNotFound if it does not exist or is in the trash
+func (s *Store) GetFlight(ctx context.Context, id, email string) (*cosmosdb.BoardingPass, error) {
+	if id == "" || email == "" {
+		return nil, errors.New("id and email are required")
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	flight, ok := s.flights[email][id]
+	if !ok || flight.Deleted {
+		return nil, cosmosdb.ErrNotFound
+	}
+	return &flight, nil
+}
+
+// ListFlights returns the user's flights, newest departure first
+func (s *Store) ListFlights(ctx context.Context, email string) ([]cosmosdb.BoardingPass, error) {
+	if email == "" {
+		return nil, errors.New("email is required")
+	}
+
+	s.mu.RLock()
+	flights := s.active(email)
+	s.mu.RUnlock()
+
+	sortByDateDesc(flights)
+	return flights, nil
+}
+
+// ListFlightsFiltered returns the user's flights matching filter, newest departure first
+func (s *Store) ListFlightsFiltered(ctx context.Context, email string, filter cosmosdb.FlightFilter) ([]cosmosdb.BoardingPass, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	all, err := s.ListFlights(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+
+	flights := make([]cosmosdb.BoardingPass, 0, len(all))
+	for _, f := range all {
+		switch {
+		case filter.Airline != "" && !strings.Contains(strings.ToUpper(f.Airline), strings.ToUpper(filter.Airline)):
+		case filter.FromAirport != "" && !strings.EqualFold(f.FromAirport, filter.FromAirport):
+		case filter.ToAirport != "" && !strings.EqualFold(f.ToAirport, filter.ToAirport):
+		case filter.After != "" && f.DepartureDate < filter.After:
+		case filter.Before != "" && f.DepartureDate > filter.Before:
+		case filter.Tag != "" && !slices.Contains(f.Tags, strings.ToLower(strings.TrimSpace(filter.Tag))):
+		case filter.Passenger != "" && !strings.EqualFold(strings.TrimSpace(f.Passenger), filter.Passenger):
+		default:
+			flights = append(flights, f)
+		}
+	}
+	return flights, nil
+}
+
+// ListFlightsPage returns one page of the user's flights matching filter and the total number matching
+func (s *Store) ListFlightsPage(ctx context.Context, email string, filter cosmosdb.FlightFilter, page cosmosdb.FlightPage) ([]cosmosdb.BoardingPass, int, error) {
+	if err := page.Validate(); err != nil {
+		return nil, 0, err
+	}
+	flights, err := s.ListFlightsFiltered(ctx, email, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	return cosmosdb.PageFlights(flights, page)
+}
+
+// StreamFlights calls fn for each of the user's flights, newest departure first, stopping at the first error
+func (s *Store) StreamFlights(ctx context.Context, email string, fn func(cosmosdb.BoardingPass) error) error {
+	flights, err := s.ListFlights(ctx, email)
+	if err != nil {
+		return err
+	}
+	for _, f := range flights {
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SearchFlights returns the user's flights where the term appears in the airline, airports, passenger,
+// flight number, confirmation code, or frequent flyer number (case-insensitive), newest departure first
+func (s *Store) SearchFlights(ctx context.Context, email, term string) ([]cosmosdb.BoardingPass, error) {
+	all, err := s.ListFlights(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+
+	term = strings.ToUpper(strings.TrimSpace(term))
+	flights := make([]cosmosdb.BoardingPass, 0, len(all))
+	for _, f := range all {
+		for _, field := range []string{f.Airline, f.FromAirport, f.ToAirport, f.Passenger, f.FlightNumber, f.ConfirmationCode, f.FrequentFlyerNumber} {
+			if strings.Contains(strings.ToUpper(field), term) {
+				flights = append(flights, f)
+				break
+			}
+		}
+	}
+	return flights, nil
+}
+
+// ListUpcomingFlights returns flights departing on or after today at their departure airport, soonest first
+func (s *Store) ListUpcomingFlights(ctx context.Context, email string, now time.Time, fallback *time.Location) ([]cosmosdb.BoardingPass, error) {
+	flights, err := s.listByLocalDate(ctx, email, now, fallback, func(date, today string) bool { return date >= today })
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(flights, func(i, j int) bool {
+		return flights[i].DepartureDate+flights[i].DepartureTime < flights[j].DepartureDate+flights[j].DepartureTime
+	})
+	return flights, nil
+}
+
+// NextFlight returns the soonest flight departing on or after today at its departure airport, or cosmosdb.ErrNotFound
+func (s *Store) NextFlight(ctx context.Context, email string, now time.Time, fallback *time.Location) (*cosmosdb.BoardingPass, error) {
+	flights, err := s.ListUpcomingFlights(ctx, email, now, fallback)
+	if err != nil {
+		return nil, err
+	}
+	if len(flights) == 0 {
+		return nil, cosmosdb.ErrNotFound
+	}
+	return &flights[0], nil
+}
+
+// ListPastFlights returns flights that departed before today at their departure airport, most recent first
+func (s *Store) ListPastFlights(ctx context.Context, email string, now time.Time, fallback *time.Location) ([]cosmosdb.BoardingPass, error) {
+	flights, err := s.listByLocalDate(ctx, email, now, fallback, func(date, today string) bool { return date < today })
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(flights, func(i, j int) bool {
+		return flights[i].DepartureDate+flights[i].DepartureTime > flights[j].DepartureDate+flights[j].DepartureTime
+	})
+	return flights, nil
+}
+
+// listByLocalDate returns the user's flights for which keep(departureDate, today at the departure airport) is true
+func (s *Store) listByLocalDate(ctx context.Context, email string, now time.Time, fallback *time.Location, keep func(date, today string) bool) ([]cosmosdb.BoardingPass, error) {
+	all, err := s.ListFlights(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+
+	flights := make([]cosmosdb.BoardingPass, 0, len(all))
+	for _, f := range all {
+		if keep(f.DepartureDate, airports.LocalDate(f.FromAirport, now, fallback)) {
+			flights = append(flights, f)
+		}
+	}
+	return flights, nil
+}
+
+// Summarize counts the user's flights and finds their most frequent airline and destination
+func (s *Store) Summarize(ctx context.Context, email, today string) (*cosmosdb.UserSummary, error) {
+	flights, err := s.ListFlights(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	return cosmosdb.SummarizeFlights(flights, today), nil
+}
+
+// ListRoutes returns the user's distinct routes with flight counts, most flown first
+func (s *Store) ListRoutes(ctx context.Context, email string) ([]cosmosdb.FlightRoute, error) {
+	flights, err := s.ListFlights(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	return cosmosdb.CountRoutes(flights), nil
+}
+
+// ListPassengers returns the distinct passenger names on the user's flights, alphabetically
+func (s *Store) ListPassengers(ctx context.Context, email string) ([]string, error) {
+	flights, err := s.ListFlights(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(flights))
+	for i, f := range flights {
+		names[i] = f.Passenger
+	}
+	return cosmosdb.DistinctPassengers(names), nil
+}
+
+// UpdateFlight overwrites an existing flight, preserving its creation time, TTL, and trash state. A non-empty
+// ifMatch must equal the stored ETag, otherwise ErrPreconditionFailed is returned.
+func (s *Store) UpdateFlight(ctx context.Context, flight *cosmosdb.BoardingPass, ifMatch string) (*cosmosdb.BoardingPass, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.flights[flight.Email][flight.ID]
+	if !ok || existing.Deleted {
+		return nil, cosmosdb.ErrNotFound
+	}
+	if ifMatch != "" && ifMatch != existing.ETag {
+		return nil, cosmosdb.ErrPreconditionFailed
+	}
+
+	flight.CreatedAt = existing.CreatedAt
+	flight.TTL = existing.TTL
+	flight.Deleted = existing.Deleted
+	flight.DeletedAt = existing.DeletedAt
+	s.put(flight)
+	return flight, nil
+}
+
+// PatchFlight sets only the given fields on an existing flight. The fields must pass cosmosdb.ValidatePatch.
+// A non-empty ifMatch must equal the stored ETag, otherwise ErrPreconditionFailed is returned.
+func (s *Store) PatchFlight(ctx context.Context, id, email string, ops map[string]any, ifMatch string) error {
+	if id == "" || email == "" {
+		return errors.New("id and email are required")
+	}
+	if _, err := cosmosdb.ValidatePatch(ops); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.flights[email][id]
+	if !ok || existing.Deleted {
+		return cosmosdb.ErrNotFound
+	}
+	if ifMatch != "" && ifMatch != existing.ETag {
+		return cosmosdb.ErrPreconditionFailed
+	}
+
+	// Apply the fields by JSON name, the same way Cosmos DB patches the stored document
+	doc, err := json.Marshal(existing)
+	if err != nil {
+		return err
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(doc, &fields); err != nil {
+		return err
+	}
+	for field, value := range ops {
+		fields[field] = value
+	}
+	doc, err = json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	var patched cosmosdb.BoardingPass
+	if err := json.Unmarshal(doc, &patched); err != nil {
+		return err
+	}
+
+	s.put(&patched)
+	return nil
+}
+
+// DeleteFlight moves a flight to the trash and returns it as it was before deletion. A non-empty
+// ifMatch must equal the stored ETag, otherwise ErrPreconditionFailed is returned.
+func (s *Store) DeleteFlight(ctx context.Context, id, email, ifMatch string) (*cosmosdb.BoardingPass, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	flight, ok := s.flights[email][id]
+	if !ok || flight.Deleted {
+		return nil, cosmosdb.ErrNotFound
+	}
+	if ifMatch != "" && ifMatch != flight.ETag {
+		return nil, cosmosdb.ErrPreconditionFailed
+	}
+
+	trashed := flight
+	trashed.Deleted = true
+	trashed.DeletedAt = time.Now().UTC().Format(time.RFC3339)
+	s.put(&trashed)
+	return &flight, nil
+}
+
+// PurgeFlight permanently removes a flight, whether or not it is in the trash, and returns it
+// as it was before it was trashed
+func (s *Store) PurgeFlight(ctx context.Context, id, email string) (*cosmosdb.BoardingPass, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	flight, ok := s.flights[email][id]
+	if !ok {
+		return nil, cosmosdb.ErrNotFound
+	}
+	delete(s.flights[email], id)
+
+	flight.Deleted = false
+	flight.DeletedAt = ""
+	return &flight, nil
+}
+
+// RestoreFlight moves a flight out of the trash
+func (s *Store) RestoreFlight(ctx context.Context, id, email string) (*cosmosdb.BoardingPass, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	flight, ok := s.flights[email][id]
+	if !ok || !flight.Deleted {
+		return nil, cosmosdb.ErrNotFound
+	}
+
+	flight.Deleted = false
+	flight.DeletedAt = ""
+	s.put(&flight)
+	return &flight, nil
+}
+
+// ListDeletedFlights returns the user's trashed flights, most recently deleted first
+func (s *Store) ListDeletedFlights(ctx context.Context, email string) ([]cosmosdb.BoardingPass, error) {
+	if email == "" {
+		return nil, errors.New("email is required")
+	}
+
+	s.mu.RLock()
+	var flights []cosmosdb.BoardingPass
+	for _, f := range s.flights[email] {
+		if f.Deleted {
+			flights = append(flights, f)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(flights, func(i, j int) bool {
+		return flights[i].DeletedAt > flights[j].DeletedAt
+	})
+	return flights, nil
+}
+
+// PurgeDeletedFlights permanently removes every user's flights trashed before cutoff and returns
+// them as they were before they were trashed
+func (s *Store) PurgeDeletedFlights(ctx context.Context, cutoff time.Time) ([]cosmosdb.BoardingPass, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	limit := cutoff.UTC().Format(time.RFC3339)
+	var purged []cosmosdb.BoardingPass
+	for email, flights := range s.flights {
+		for id, f := range flights {
+			if f.Deleted && f.DeletedAt < limit {
+				delete(flights, id)
+				f.Deleted = false
+				f.DeletedAt = ""
+				purged = append(purged, f)
+			}
+		}
+		if len(flights) == 0 {
+			delete(s.flights, email)
+		}
+	}
+	return purged, nil
+}
+
+// ExecuteRawQuery runs a query against the user's flights, including trashed ones as in the
+// Cosmos DB container. Only the subset of SQL described in query.go is supported; other queries
+// fail with ErrQueryUnsupported.
+func (s *Store) ExecuteRawQuery(ctx context.Context, query, email string) ([]json.RawMessage, error) {
+	if email == "" {
+		return nil, errors.New("email is required for partition-scoped queries")
+	}
+	docs, err := s.documents(email)
+	if err != nil {
+		return nil, err
+	}
+	results, err := runQuery(query, docs)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	return results, nil
+}
+
+// ExplainQuery runs the query and reports how many items it returned. There is no request
+// charge or index metrics to report.
+func (s *Store) ExplainQuery(ctx context.Context, query, email string) (*cosmosdb.QueryPlan, error) {
+	results, err := s.ExecuteRawQuery(ctx, query, email)
+	if err != nil {
+		return nil, err
+	}
+	return &cosmosdb.QueryPlan{Query: query, FirstPageItems: len(results)}, nil
+}
+
+// documents returns the user's flights, trashed ones included, as JSON objects, newest departure first
+func (s *Store) documents(email string) ([]map[string]any, error) {
+	s.mu.RLock()
+	flights := make([]cosmosdb.BoardingPass, 0, len(s.flights[email]))
+	for _, f := range s.flights[email] {
+		flights = append(flights, f)
+	}
+	s.mu.RUnlock()
+	sortByDateDesc(flights)
+
+	docs := make([]map[string]any, len(flights))
+	for i, f := range flights {
+		data, err := json.Marshal(f)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &docs[i]); err != nil {
+			return nil, err
+		}
+	}
+	return docs, nil
+}
+
+// AdminStats computes statistics across all users
+func (s *Store) AdminStats(ctx context.Context) (*cosmosdb.GlobalStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := &cosmosdb.GlobalStats{}
+	routes := make(map[[2]string]int)
+	for email := range s.flights {
+		flights := s.active(email)
+		if len(flights) > 0 {
+			stats.UniqueUsers++
+		}
+		for _, f := range flights {
+			stats.TotalFlights++
+			routes[[2]string{f.FromAirport, f.ToAirport}]++
+		}
+	}
+
+	stats.TopRoutes = make([]cosmosdb.RouteCount, 0, len(routes))
+	for route, count := range routes {
+		stats.TopRoutes = append(stats.TopRoutes, cosmosdb.RouteCount{FromAirport: route[0], ToAirport: route[1], Count: count})
+	}
+	sort.Slice(stats.TopRoutes, func(i, j int) bool {
+		a, b := stats.TopRoutes[i], stats.TopRoutes[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.FromAirport+a.ToAirport < b.FromAirport+b.ToAirport
+	})
+	if len(stats.TopRoutes) > topRoutesLimit {
+		stats.TopRoutes = stats.TopRoutes[:topRoutesLimit]
+	}
+	return stats, nil
+}
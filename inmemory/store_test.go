@@ -0,0 +1,66 @@
+package inmemory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/abhirockzz/flight-log-app/cosmosdb"
+)
+
+func TestUpdateFlightKeepsTrashState(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	saved, err := s.InsertFlight(ctx, &cosmosdb.BoardingPass{Email: "alice@example.com", FlightNumber: "UA 1", DepartureDate: "2026-01-10"})
+	if err != nil {
+		t.Fatalf("InsertFlight: %v", err)
+	}
+
+	update := *saved
+	update.Gate = "B12"
+	update.Deleted = true
+	update.DeletedAt = "2000-01-01T00:00:00Z"
+	if _, err := s.UpdateFlight(ctx, &update, ""); err != nil {
+		t.Fatalf("UpdateFlight: %v", err)
+	}
+
+	current, err := s.GetFlight(ctx, saved.ID, saved.Email)
+	if err != nil {
+		t.Fatalf("GetFlight after update = %v, want the flight still active", err)
+	}
+	if current.Gate != "B12" || current.Deleted || current.DeletedAt != "" {
+		t.Fatalf("flight = gate %q, deleted %v, deletedAt %q; want gate B12 and not deleted", current.Gate, current.Deleted, current.DeletedAt)
+	}
+}
+
+func TestPurgeDeletedFlights(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	for _, f := range []cosmosdb.BoardingPass{
+		{ID: "old", Email: "alice@example.com", FlightNumber: "UA 1", DepartureDate: "2026-01-10", ImageURL: "https://images.example.com/a.png"},
+		{ID: "kept", Email: "bob@example.com", FlightNumber: "DL 2", DepartureDate: "2026-01-11"},
+	} {
+		if _, err := s.InsertFlight(ctx, &f); err != nil {
+			t.Fatalf("InsertFlight: %v", err)
+		}
+		if _, err := s.DeleteFlight(ctx, f.ID, f.Email, ""); err != nil {
+			t.Fatalf("DeleteFlight: %v", err)
+		}
+	}
+	s.mu.Lock()
+	old := s.flights["alice@example.com"]["old"]
+	old.DeletedAt = "2000-01-01T00:00:00Z"
+	s.flights["alice@example.com"]["old"] = old
+	s.mu.Unlock()
+
+	purged, err := s.PurgeDeletedFlights(ctx, time.Now().AddDate(0, 0, -30))
+	if err != nil {
+		t.Fatalf("PurgeDeletedFlights: %v", err)
+	}
+	if len(purged) != 1 || purged[0].ID != "old" || purged[0].ImageURL == "" || purged[0].Deleted {
+		t.Fatalf("purged = %+v, want the old flight with its image URL", purged)
+	}
+	if trash, _ := s.ListDeletedFlights(ctx, "bob@example.com"); len(trash) != 1 {
+		t.Fatalf("bob's trash = %d flights, want 1", len(trash))
+	}
+}
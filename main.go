@@ -1,57 +1,103 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/abhirockzz/flight-log-app/blobstore"
 	"github.com/abhirockzz/flight-log-app/cosmosdb"
+	"github.com/abhirockzz/flight-log-app/inmemory"
+	"github.com/abhirockzz/flight-log-app/redact"
+	"github.com/abhirockzz/flight-log-app/schedule"
 	"github.com/abhirockzz/flight-log-app/server"
+	"github.com/abhirockzz/flight-log-app/tracing"
 	sdk "github.com/github/copilot-sdk/go"
 )
 
 const (
 	defaultDatabase  = "flightlog"
 	defaultContainer = "boardingPasses"
+
+	// defaultBlobContainer holds boarding pass images when BLOB_CONNECTION_STRING is set
+	defaultBlobContainer = "boarding-passes"
+
+	// shutdownTimeout bounds how long in-flight requests (including SSE streams) get to finish
+	shutdownTimeout = 15 * time.Second
+
+	// Defaults for removing upload temp files orphaned by a crash
+	defaultOrphanSweepInterval = 15 * time.Minute
+	defaultOrphanMaxAge        = time.Hour
 )
 
 func main() {
-	// Get Cosmos DB endpoint from environment
-	endpoint := os.Getenv("COSMOS_ENDPOINT")
-	if endpoint == "" {
-		log.Fatal("COSMOS_ENDPOINT environment variable is required")
+	// Read and validate all settings up front, so a bad config fails before anything starts
+	cfg, err := loadConfig()
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
 	}
 
-	// Database name with default
-	database := os.Getenv("COSMOS_DATABASE")
-	if database == "" {
-		database = defaultDatabase
-	}
+	// LOG_LEVEL controls app debug logging and, when set, the Copilot CLI's log level
+	appLevel, sdkLevel := logLevels(cfg.LogLevel)
+	slog.SetLogLoggerLevel(appLevel)
 
-	// Container name with default
-	container := os.Getenv("COSMOS_CONTAINER")
-	if container == "" {
-		container = defaultContainer
+	// LOG_REDACT=false logs emails, chat messages, and queries unmasked; never use it with real user data
+	redact.SetEnabled(cfg.LogRedact)
+	if !cfg.LogRedact {
+		log.Println("Log redaction disabled: emails, chat messages, and queries are logged in full")
 	}
 
-	// Initialize Cosmos DB client
-	cosmosClient, err := cosmosdb.NewClient(endpoint, database, container)
+	// Export spans over OTLP when OTEL_EXPORTER_OTLP_ENDPOINT is set; otherwise tracing is a no-op
+	shutdownTracing, err := tracing.Setup(context.Background())
 	if err != nil {
-		log.Fatalf("Failed to initialize Cosmos DB client: %v", err)
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+	if tracing.Enabled() {
+		log.Println("Tracing enabled: exporting spans over OTLP")
+	}
+
+	// STORE=memory keeps flights in process memory, so the app runs without any Cosmos DB instance
+	var store cosmosdb.FlightStore
+	if cfg.Store == "memory" {
+		log.Println("Using the in-memory flight store: data is lost on exit and AI chat supports only simple queries")
+		store = inmemory.New()
+	} else {
+		store = newCosmosClient(cfg)
+	}
+
+	// AUTH_MODE picks how callers are identified; fail fast on a bad config rather than serve unauthenticated
+	serverOpts := append(serverOptions(cfg), server.WithConfig(cfg.Server))
+
+	// BLOB_CONNECTION_STRING keeps boarding pass images in Azure Blob Storage; unset, they are discarded
+	if cfg.BlobConnection != "" {
+		serverOpts = append(serverOpts, server.WithImageStore(newBlobClient(cfg.BlobConnection, cfg.BlobContainer)))
+	}
+
+	// AVIATIONSTACK_API_KEY checks extracted flights against airline schedules; unset, no check is made
+	if cfg.AviationStackKey != "" {
+		serverOpts = append(serverOpts, server.WithScheduleProvider(schedule.NewAviationStack(cfg.AviationStackKey)))
 	}
 
 	// Initialize Copilot SDK client
 	// When COPILOT_CLI_URL is set (e.g. Docker Compose), connect to external headless CLI over TCP.
 	// Otherwise, SDK spawns the CLI as a child process (local dev mode).
 	var copilotClient *sdk.Client
-	if cliURL := os.Getenv("COPILOT_CLI_URL"); cliURL != "" {
-		log.Printf("Connecting to external Copilot CLI at %s", cliURL)
+	if cfg.CopilotCLIURL != "" {
+		log.Printf("Connecting to external Copilot CLI at %s", cfg.CopilotCLIURL)
 		copilotClient = sdk.NewClient(&sdk.ClientOptions{
-			CLIUrl: cliURL,
+			CLIUrl: cfg.CopilotCLIURL,
 		})
 	} else {
 		copilotClient = sdk.NewClient(&sdk.ClientOptions{
-			LogLevel: "error",
+			LogLevel: sdkLevel,
 		})
 	}
 	if err := copilotClient.Start(); err != nil {
@@ -59,17 +105,113 @@ func main() {
 	}
 	defer copilotClient.Stop()
 
-	// Create server
-	srv := server.New(cosmosClient, copilotClient)
+	// Cancel ctx on SIGINT/SIGTERM so we can drain connections before exiting
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Create server; its background work stops when ctx is cancelled
+	srv := server.New(ctx, store, copilotClient, serverOpts...)
+	defer srv.Close()
+
+	// Remove upload temp files left behind if the process was killed mid-extraction
+	go server.SweepOrphanedUploads(ctx, cfg.Server.UploadDir, cfg.OrphanSweep, cfg.OrphanMaxAge)
+
+	port := cfg.Port
+
+	httpServer := &http.Server{
+		Addr:    ":" + port,
+		Handler: srv,
+		// Request contexts derive from ctx, so SSE handlers observe cancellation on shutdown
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Printf("Flight Log app starting on http://localhost:%s", port)
+		serveErr <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Server failed: %v", err)
+		}
+	case <-ctx.Done():
+		log.Println("Shutdown signal received, draining connections...")
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Graceful shutdown did not complete: %v", err)
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Printf("Failed to flush traces: %v", err)
+	}
+
+	log.Println("Server stopped")
+}
+
+// newCosmosClient connects to the Cosmos DB container named in cfg, exiting if that fails
+func newCosmosClient(cfg Config) *cosmosdb.Client {
+	var opts []cosmosdb.ClientOption
+	// Consistency level override (defaults to the account's level, usually Session)
+	if cfg.CosmosConsistency != "" {
+		opts = append(opts, cosmosdb.WithConsistency(cfg.CosmosConsistency))
+	}
+	if cfg.UseEmulator {
+		opts = append(opts, cosmosdb.WithEmulator(cfg.EmulatorCert))
+	}
+	if cfg.CreateIfNotExists {
+		opts = append(opts, cosmosdb.WithCreateIfNotExists())
+	}
+
+	cosmosClient, err := cosmosdb.NewClient(cfg.CosmosEndpoint, cfg.CosmosDatabase, cfg.CosmosContainer, opts...)
+	if err != nil {
+		log.Fatalf("Failed to initialize Cosmos DB client: %v", err)
+	}
+	return cosmosClient
+}
 
-	// Get port from environment or default to 8080
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+// newBlobClient connects to the given blob container, exiting if that fails
+func newBlobClient(connectionString, container string) *blobstore.Client {
+	client, err := blobstore.NewClient(connectionString, container)
+	if err != nil {
+		log.Fatalf("Failed to initialize Blob Storage client: %v", err)
 	}
+	log.Printf("Storing boarding pass images in blob container %s", container)
+	return client
+}
 
-	log.Printf("Flight Log app starting on http://localhost:%s", port)
-	if err := http.ListenAndServe(":"+port, srv); err != nil {
-		log.Fatalf("Server failed: %v", err)
+// logLevels maps LOG_LEVEL (debug/info/warn/error) to the app's slog level and the Copilot CLI's
+// log level. When unset, the app logs at info and the SDK only logs errors to keep output quiet.
+func logLevels(v string) (slog.Level, string) {
+	switch strings.ToLower(v) {
+	case "":
+		return slog.LevelInfo, "error"
+	case "debug":
+		return slog.LevelDebug, "debug"
+	case "info":
+		return slog.LevelInfo, "info"
+	case "warn", "warning":
+		return slog.LevelWarn, "warning"
+	case "error":
+		return slog.LevelError, "error"
+	}
+	log.Printf("Invalid LOG_LEVEL %q, using info", v)
+	return slog.LevelInfo, "error"
+}
+
+// serverOptions configures authentication from AUTH_MODE: "header" (the default) trusts the
+// X-User-Email header for local development, and "jwt" requires a bearer JWT signed with AUTH_JWT_SECRET
+func serverOptions(cfg Config) []server.Option {
+	if cfg.AuthMode != "jwt" {
+		log.Println("AUTH_MODE=header: trusting the X-User-Email header; use AUTH_MODE=jwt when exposed beyond localhost")
+		return nil
+	}
+	auth, err := server.NewJWTAuthenticator([]byte(cfg.AuthJWTSecret), cfg.AuthJWTEmailClaim)
+	if err != nil {
+		log.Fatalf("Invalid AUTH_JWT_SECRET: %v", err)
 	}
+	return []server.Option{server.WithAuthenticator(auth)}
 }
@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// registry holds the app's collectors, separate from the global default registry
+var registry = prometheus.NewRegistry()
+
+var (
+	extractions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "flightlog_extractions_total",
+		Help: "Boarding pass extractions by model and result (success or failure).",
+	}, []string{"model", "result"})
+
+	chats = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "flightlog_chat_requests_total",
+		Help: "Chat requests by model and result (success or failure).",
+	}, []string{"model", "result"})
+
+	cosmosLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "flightlog_cosmos_operation_duration_seconds",
+		Help:    "Latency of Cosmos DB operations.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"operation", "result"})
+
+	cosmosRU = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "flightlog_cosmos_request_charge",
+		Help:    "Request units (RU) consumed by Cosmos DB operations.",
+		Buckets: []float64{1, 2, 5, 10, 25, 50, 100, 250, 500, 1000},
+	}, []string{"operation"})
+)
+
+func init() {
+	registry.MustRegister(extractions, chats, cosmosLatency, cosmosRU)
+}
+
+// Handler returns the HTTP handler that serves the app's metrics
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// result maps an error to a "success"/"failure" label value
+func result(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
+
+// RecordExtraction counts a boarding pass extraction for the given model
+func RecordExtraction(model string, err error) {
+	extractions.WithLabelValues(model, result(err)).Inc()
+}
+
+// RecordChat counts a chat request for the given model
+func RecordChat(model string, err error) {
+	chats.WithLabelValues(model, result(err)).Inc()
+}
+
+// ObserveCosmos records the latency and RU charge of a Cosmos DB operation started at start.
+// A zero requestCharge (e.g. when the operation failed before a response) is not recorded.
+func ObserveCosmos(operation string, start time.Time, requestCharge float32, err error) {
+	cosmosLatency.WithLabelValues(operation, result(err)).Observe(time.Since(start).Seconds())
+	if requestCharge > 0 {
+		cosmosRU.WithLabelValues(operation).Observe(float64(requestCharge))
+	}
+}
@@ -0,0 +1,86 @@
+// Package redact masks personal data before it is logged: email addresses, the text of user
+// messages, and the literals in generated queries. Redaction is on by default; the app turns it
+// off with LOG_REDACT=false for local debugging.
+package redact
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+// maxText is how much of a user message is kept in logs
+const maxText = 24
+
+// disabled is inverted so the zero value redacts
+var disabled atomic.Bool
+
+// SetEnabled turns redaction on or off for the whole process
+func SetEnabled(on bool) {
+	disabled.Store(!on)
+}
+
+// Enabled reports whether log values are redacted
+func Enabled() bool {
+	return !disabled.Load()
+}
+
+// Email masks the local part of an address, keeping its first character, e.g. j***@example.com.
+// Values that aren't an address are masked entirely.
+func Email(email string) string {
+	if !Enabled() || email == "" {
+		return email
+	}
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok {
+		return "***"
+	}
+	first, _ := utf8.DecodeRuneInString(local)
+	if first == utf8.RuneError {
+		return "***@" + domain
+	}
+	return string(first) + "***@" + domain
+}
+
+// Text truncates free-form text such as a chat message, noting its full length, e.g.
+// "show me my flights to Ne… (42 chars)"
+func Text(s string) string {
+	n := utf8.RuneCountInString(s)
+	if !Enabled() || n <= maxText {
+		return s
+	}
+	runes := []rune(s)
+	return fmt.Sprintf("%s… (%d chars)", string(runes[:maxText]), n)
+}
+
+// URL reduces a URL to its scheme and host, e.g. https://example.com/…, dropping the path, query
+// string, and credentials, which may carry signatures or tokens. Values that don't parse are masked entirely.
+func URL(rawURL string) string {
+	if !Enabled() || rawURL == "" {
+		return rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return "***"
+	}
+	out := u.Scheme + "://" + u.Host
+	if u.Path != "" || u.RawQuery != "" || u.Fragment != "" {
+		out += "/…"
+	}
+	return out
+}
+
+// quotedLiteral matches a single-quoted SQL string literal, with ” as an escaped quote
+var quotedLiteral = regexp.MustCompile(`'(?:[^']|'')*'`)
+
+// Query masks the string literals of a SQL query, which hold emails, names, and other values the
+// user asked about, while keeping its structure readable, e.g. c.email = '***'
+func Query(query string) string {
+	if !Enabled() {
+		return query
+	}
+	return quotedLiteral.ReplaceAllString(query, "'***'")
+}
@@ -0,0 +1,149 @@
+// Package schedule checks extracted flights against published airline schedules, to catch
+// misread boarding passes such as a wrong flight number that points at a different route.
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/abhirockzz/flight-log-app/cosmosdb"
+)
+
+// ScheduledFlight is a flight as the airline schedule lists it. Times are local to the airport.
+type ScheduledFlight struct {
+	FlightNumber  string `json:"flightNumber"`  // e.g. "UA1234"
+	FromAirport   string `json:"fromAirport"`   // IATA code
+	ToAirport     string `json:"toAirport"`     // IATA code
+	DepartureDate string `json:"departureDate"` // YYYY-MM-DD
+	DepartureTime string `json:"departureTime"` // HH:MM; empty if the schedule doesn't give one
+}
+
+// Mismatch is a field where an extracted flight disagrees with its schedule
+type Mismatch struct {
+	Field     string `json:"field"` // fromAirport, toAirport, or departureTime
+	Extracted string `json:"extracted"`
+	Scheduled string `json:"scheduled"`
+	Message   string `json:"message"`
+}
+
+// Compare reports the route and departure time fields where flight differs from scheduled.
+// Fields empty on either side are not compared.
+func Compare(flight *cosmosdb.BoardingPass, scheduled *ScheduledFlight) []Mismatch {
+	var mismatches []Mismatch
+	check := func(field, label, extracted, want string) {
+		if extracted == "" || want == "" || strings.EqualFold(strings.TrimSpace(extracted), strings.TrimSpace(want)) {
+			return
+		}
+		mismatches = append(mismatches, Mismatch{
+			Field:     field,
+			Extracted: extracted,
+			Scheduled: want,
+			Message:   fmt.Sprintf("%s is %s on the boarding pass but %s in the %s schedule", label, extracted, want, scheduled.FlightNumber),
+		})
+	}
+	check("fromAirport", "Departure airport", flight.FromAirport, scheduled.FromAirport)
+	check("toAirport", "Arrival airport", flight.ToAirport, scheduled.ToAirport)
+	check("departureTime", "Departure time", flight.DepartureTime, scheduled.DepartureTime)
+	return mismatches
+}
+
+// flightCode strips spaces and dashes from a flight number, e.g. "UA 1234" to "UA1234"
+func flightCode(flightNumber string) string {
+	return strings.ToUpper(strings.NewReplacer(" ", "", "-", "").Replace(flightNumber))
+}
+
+// aviationStackURL is the AviationStack flights endpoint
+const aviationStackURL = "https://api.aviationstack.com/v1/flights"
+
+// AviationStack looks up flights with the AviationStack API (https://aviationstack.com).
+// Looking up a specific date needs a plan that includes historical and future flights.
+type AviationStack struct {
+	apiKey string
+	http   *http.Client
+}
+
+// NewAviationStack creates a client authenticating with apiKey
+func NewAviationStack(apiKey string) *AviationStack {
+	return &AviationStack{apiKey: apiKey, http: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// aviationStackResponse is the part of an AviationStack flights response used here
+type aviationStackResponse struct {
+	Data []struct {
+		FlightDate string `json:"flight_date"`
+		Departure  struct {
+			IATA      string `json:"iata"`
+			Scheduled string `json:"scheduled"` // Local time, e.g. "2026-01-25T08:05:00+00:00" (the offset is not meaningful)
+		} `json:"departure"`
+		Arrival struct {
+			IATA string `json:"iata"`
+		} `json:"arrival"`
+		Flight struct {
+			IATA string `json:"iata"`
+		} `json:"flight"`
+	} `json:"data"`
+	Error *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Lookup returns the scheduled flight flightNumber departing on date (YYYY-MM-DD), or false if the
+// schedule doesn't list it
+func (a *AviationStack) Lookup(ctx context.Context, flightNumber, date string) (*ScheduledFlight, bool, error) {
+	code := flightCode(flightNumber)
+	query := url.Values{
+		"access_key":  {a.apiKey},
+		"flight_iata": {code},
+		"flight_date": {date},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, aviationStackURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, false, err
+	}
+
+	resp, err := a.http.Do(req)
+	if err != nil {
+		// The URL carries the API key; report only what went wrong
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, false, fmt.Errorf("aviationstack request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body aviationStackResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, false, fmt.Errorf("aviationstack returned %s: %w", resp.Status, err)
+	}
+	if body.Error != nil {
+		return nil, false, fmt.Errorf("aviationstack error %s: %s", body.Error.Code, body.Error.Message)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("aviationstack returned %s", resp.Status)
+	}
+
+	for _, f := range body.Data {
+		if !strings.EqualFold(f.Flight.IATA, code) || f.FlightDate != date {
+			continue
+		}
+		scheduled := &ScheduledFlight{
+			FlightNumber:  code,
+			FromAirport:   strings.ToUpper(f.Departure.IATA),
+			ToAirport:     strings.ToUpper(f.Arrival.IATA),
+			DepartureDate: f.FlightDate,
+		}
+		if len(f.Departure.Scheduled) >= len("2006-01-02T15:04") {
+			scheduled.DepartureTime = f.Departure.Scheduled[11:16]
+		}
+		return scheduled, true, nil
+	}
+	return nil, false, nil
+}
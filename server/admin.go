@@ -0,0 +1,100 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/abhirockzz/flight-log-app/ai"
+	"github.com/abhirockzz/flight-log-app/redact"
+)
+
+// authorizeAdmin checks the request's bearer token (or X-Admin-Token header) against Config.AdminToken.
+// Admin endpoints are disabled entirely when no admin token is configured.
+func (s *Server) authorizeAdmin(w http.ResponseWriter, r *http.Request) bool {
+	expected := s.cfg.AdminToken
+	if expected == "" {
+		http.NotFound(w, r)
+		return false
+	}
+
+	token := r.Header.Get("X-Admin-Token")
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimPrefix(auth, "Bearer ")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// NormalizeResponse summarizes a POST /api/admin/normalize run
+type NormalizeResponse struct {
+	Checked int `json:"checked"` // Flights read
+	Updated int `json:"updated"` // Flights rewritten because normalization changed them
+	Failed  int `json:"failed"`  // Flights that changed but couldn't be written, e.g. edited concurrently
+}
+
+// handleAdminNormalize migrates a user's stored flights to the current normalization rules
+// (airport and airline names, dates, times, seats, tags), rewriting only the flights that change.
+// Each write checks the flight's ETag, so a flight edited meanwhile is left alone and counted as failed.
+func (s *Server) handleAdminNormalize(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	email := r.URL.Query().Get("email")
+	if email == "" {
+		http.Error(w, "email query parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	flights, err := s.store.ListFlights(r.Context(), email)
+	if err != nil {
+		log.Printf("Failed to list flights for normalization: %v", err)
+		http.Error(w, "Failed to list flights: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := NormalizeResponse{Checked: len(flights)}
+	for i := range flights {
+		flight := &flights[i]
+		if !ai.NormalizeFlight(flight) {
+			continue
+		}
+		if _, err := s.store.UpdateFlight(r.Context(), flight, flight.ETag); err != nil {
+			log.Printf("[ADMIN] Failed to normalize flight %s: %v", flight.ID, err)
+			resp.Failed++
+			continue
+		}
+		resp.Updated++
+	}
+	if resp.Updated > 0 {
+		s.chatHandler.InvalidateCache(email)
+	}
+	log.Printf("[ADMIN] Normalized flights for %s: %d checked, %d updated, %d failed", redact.Email(email), resp.Checked, resp.Updated, resp.Failed)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleAdminStats returns aggregate statistics across all users.
+// This runs a cross-partition query, so it is guarded by ADMIN_TOKEN.
+func (s *Server) handleAdminStats(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+
+	stats, err := s.store.AdminStats(r.Context())
+	if err != nil {
+		log.Printf("Failed to compute admin stats: %v", err)
+		http.Error(w, "Failed to compute stats: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
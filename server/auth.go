@@ -0,0 +1,222 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Authenticator verifies who is making a request. Without one (AUTH_MODE=header) the server
+// trusts the X-User-Email header and email query parameter as sent, which is only safe for local use.
+type Authenticator interface {
+	// Authenticate returns the caller's verified email, or an error wrapping ErrUnauthenticated
+	Authenticate(r *http.Request) (string, error)
+}
+
+// ErrUnauthenticated is returned by an Authenticator for missing, malformed, or expired credentials
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// Option configures a Server
+type Option func(*Server)
+
+// WithAuthenticator requires a verified identity on user API routes. The verified email replaces
+// X-User-Email and the email query parameter, and requests naming a different user get 403.
+func WithAuthenticator(a Authenticator) Option {
+	return func(s *Server) {
+		s.auth = a
+	}
+}
+
+// authEmailKey is the context key for the email verified by authMiddleware
+type authEmailKey struct{}
+
+// authenticatedEmail returns the email verified by authMiddleware, if authentication is enabled
+func authenticatedEmail(ctx context.Context) (string, bool) {
+	email, ok := ctx.Value(authEmailKey{}).(string)
+	return email, ok
+}
+
+// claimOwner checks a flight owner sent in a request body against the verified email and fills it in
+// when empty. It writes 403 and returns false on a mismatch; without authentication it does nothing.
+func claimOwner(w http.ResponseWriter, r *http.Request, email *string) bool {
+	verified, ok := authenticatedEmail(r.Context())
+	if !ok {
+		return true
+	}
+	if *email != "" && !strings.EqualFold(*email, verified) {
+		http.Error(w, "Forbidden: email does not match the authenticated user", http.StatusForbidden)
+		return false
+	}
+	*email = verified
+	return true
+}
+
+// requiresAuth reports whether a path acts on a user's data. Admin routes have their own token,
+// and the model and sample lists, OpenAPI document, metrics, and static files are public.
+func requiresAuth(path string) bool {
+	if !strings.HasPrefix(path, "/api/") {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(path, "/api/admin/"), path == "/api/models", path == "/api/samples":
+		return false
+	}
+	return true
+}
+
+// authMiddleware rejects user API requests that auth can't verify with 401, then rewrites the
+// request so handlers see the verified email in X-User-Email and the email query parameter
+func authMiddleware(auth Authenticator) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !requiresAuth(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			email, err := auth.Authenticate(r)
+			if err != nil {
+				slog.Debug("[AUTH] Rejected request", "path", r.URL.Path, "error", err)
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+
+			query := r.URL.Query()
+			for _, claimed := range []string{r.Header.Get("X-User-Email"), query.Get("email")} {
+				if claimed != "" && !strings.EqualFold(claimed, email) {
+					http.Error(w, "Forbidden: email does not match the authenticated user", http.StatusForbidden)
+					return
+				}
+			}
+
+			r = r.WithContext(context.WithValue(r.Context(), authEmailKey{}, email))
+			r.Header.Set("X-User-Email", email)
+			query.Set("email", email)
+			r.URL.RawQuery = query.Encode()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// jwtLeeway tolerates clock skew between the token issuer and this server
+const jwtLeeway = 30 * time.Second
+
+// defaultEmailClaim is the JWT claim holding the user's email
+const defaultEmailClaim = "email"
+
+// jwtAuthenticator verifies HMAC-signed JWTs (HS256, HS384, or HS512) issued by a trusted site
+type jwtAuthenticator struct {
+	secret     []byte
+	emailClaim string
+	now        func() time.Time
+}
+
+// NewJWTAuthenticator returns an Authenticator for bearer JWTs signed with the shared secret.
+// The token must carry an exp claim and the user's email in emailClaim ("email" if empty).
+// Browsers can't set headers on WebSocket connections, so the chat socket also accepts ?access_token=.
+func NewJWTAuthenticator(secret []byte, emailClaim string) (Authenticator, error) {
+	// RFC 7518 requires an HMAC key at least as long as the hash output
+	if len(secret) < sha256.Size {
+		return nil, fmt.Errorf("JWT secret must be at least %d bytes", sha256.Size)
+	}
+	if emailClaim == "" {
+		emailClaim = defaultEmailClaim
+	}
+	return &jwtAuthenticator{secret: secret, emailClaim: emailClaim, now: time.Now}, nil
+}
+
+// Authenticate implements Authenticator
+func (a *jwtAuthenticator) Authenticate(r *http.Request) (string, error) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok && r.URL.Path == "/api/chat/ws" {
+		token = r.URL.Query().Get("access_token")
+	}
+	if token == "" {
+		return "", fmt.Errorf("%w: missing bearer token", ErrUnauthenticated)
+	}
+
+	claims, err := a.verify(strings.TrimSpace(token))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrUnauthenticated, err)
+	}
+	email, _ := claims[a.emailClaim].(string)
+	if email = strings.TrimSpace(email); email == "" {
+		return "", fmt.Errorf("%w: token has no %s claim", ErrUnauthenticated, a.emailClaim)
+	}
+	return email, nil
+}
+
+// verify checks the token's signature and time claims and returns its claims
+func (a *jwtAuthenticator) verify(token string) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed token header: %w", err)
+	}
+	var newHash func() hash.Hash
+	switch header.Alg {
+	case "HS256":
+		newHash = sha256.New
+	case "HS384":
+		newHash = sha512.New384
+	case "HS512":
+		newHash = sha512.New
+	default:
+		// Notably rejects "none" and asymmetric algorithms signed with a public key
+		return nil, fmt.Errorf("unsupported signing algorithm %q", header.Alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed token signature")
+	}
+	mac := hmac.New(newHash, a.secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, errors.New("invalid token signature")
+	}
+
+	var claims map[string]any
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed token claims: %w", err)
+	}
+
+	now := a.now()
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, errors.New("token has no expiry")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(jwtLeeway)) {
+		return nil, errors.New("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(jwtLeeway).Before(time.Unix(int64(nbf), 0)) {
+		return nil, errors.New("token not yet valid")
+	}
+	return claims, nil
+}
+
+// decodeJWTPart decodes a base64url-encoded JSON token segment into v
+func decodeJWTPart(part string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/abhirockzz/flight-log-app/cosmosdb"
+	"github.com/abhirockzz/flight-log-app/redact"
+)
+
+const (
+	// maxBulkFlights caps the number of flights in one bulk import request
+	maxBulkFlights = 500
+
+	// maxBulkBodyBytes allows for maxBulkFlights flights of a few hundred bytes each
+	maxBulkBodyBytes = 4 << 20 // 4MB
+)
+
+// BulkItemResult reports the outcome of one flight in a bulk import, in request order
+type BulkItemResult struct {
+	Index   int    `json:"index"`
+	Success bool   `json:"success"`
+	ID      string `json:"id,omitempty"`    // Assigned ID of the created flight
+	Error   string `json:"error,omitempty"` // Why the flight was not created
+}
+
+// BulkImportResponse is the response from POST /api/flights/bulk
+type BulkImportResponse struct {
+	Created int              `json:"created"`
+	Failed  int              `json:"failed"`
+	Results []BulkItemResult `json:"results"`
+}
+
+// handleBulkCreateFlights imports an array of flights. Every flight is validated first and the whole
+// request is rejected if any is invalid, unless ?partial=true, which skips invalid flights instead.
+// Valid flights are inserted in transactional batches per owner (a batch must share a partition key);
+// flights that duplicate an existing one are reported as failed rather than failing the import.
+func (s *Server) handleBulkCreateFlights(w http.ResponseWriter, r *http.Request) {
+	var flights []cosmosdb.BoardingPass
+	if !decodeJSONBodyLimit(w, r, &flights, maxBulkBodyBytes) {
+		return
+	}
+	if len(flights) == 0 {
+		http.Error(w, "At least one flight is required", http.StatusBadRequest)
+		return
+	}
+	if len(flights) > maxBulkFlights {
+		http.Error(w, fmt.Sprintf("Too many flights (max %d per request)", maxBulkFlights), http.StatusBadRequest)
+		return
+	}
+	for i := range flights {
+		if !claimOwner(w, r, &flights[i].Email) {
+			return
+		}
+		clearServerFields(&flights[i])
+	}
+	partial := r.URL.Query().Get("partial") == "true"
+
+	resp := BulkImportResponse{Results: make([]BulkItemResult, len(flights))}
+	for i := range flights {
+		resp.Results[i].Index = i
+		err := flights[i].Validate()
+		if err == nil {
+			err = validateEmail(flights[i].Email)
+		}
+		if err != nil {
+			resp.Results[i].Error = err.Error()
+			resp.Failed++
+		}
+	}
+	if resp.Failed > 0 && !partial {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	// Group valid flights by owner, remembering each flight's position in the request
+	index := make(map[*cosmosdb.BoardingPass]int, len(flights))
+	groups := make(map[string][]*cosmosdb.BoardingPass)
+	var emails []string
+	for i := range flights {
+		if resp.Results[i].Error != "" {
+			continue
+		}
+		flight := &flights[i]
+		index[flight] = i
+		if groups[flight.Email] == nil {
+			emails = append(emails, flight.Email)
+		}
+		groups[flight.Email] = append(groups[flight.Email], flight)
+	}
+
+	for _, email := range emails {
+		group := groups[email]
+		saved, err := s.store.SaveFlightsBatch(r.Context(), group)
+		if len(saved) > 0 {
+			s.chatHandler.InvalidateCache(email)
+		}
+
+		created := make(map[string]bool, len(saved))
+		for _, flight := range saved {
+			created[flight.ID] = true
+		}
+		itemErrs := make(map[*cosmosdb.BoardingPass]error)
+		for _, itemErr := range cosmosdb.ItemErrors(err) {
+			itemErrs[itemErr.Flight] = itemErr.Err
+		}
+
+		for _, flight := range group {
+			result := &resp.Results[index[flight]]
+			switch {
+			case flight.ID != "" && created[flight.ID]:
+				result.Success = true
+				result.ID = flight.ID
+				resp.Created++
+				continue
+			case itemErrs[flight] != nil:
+				result.Error = itemErrs[flight].Error()
+			case err != nil:
+				result.Error = err.Error()
+			default:
+				result.Error = "not saved"
+			}
+			resp.Failed++
+		}
+
+		if len(saved) < len(group) {
+			log.Printf("Bulk import for %s saved %d of %d flights: %v", redact.Email(email), len(saved), len(group), err)
+		}
+		if r.Context().Err() != nil {
+			// The client is gone; skip the remaining owners
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
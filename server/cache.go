@@ -0,0 +1,96 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/fs"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abhirockzz/flight-log-app/static"
+)
+
+// Browser cache lifetimes for responses that rarely change within a session
+const (
+	modelsMaxAge      = 5 * time.Minute // The list can change on the background refresh
+	samplesMaxAge     = time.Hour
+	sampleImageMaxAge = 24 * time.Hour
+)
+
+// contentETag returns a strong ETag derived from the content hash
+func contentETag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// setCacheHeaders sets Cache-Control and ETag, and reports whether the client's
+// If-None-Match already matches so the caller can stop after writing 304
+func setCacheHeaders(w http.ResponseWriter, r *http.Request, etag string, maxAge time.Duration) bool {
+	w.Header().Set("Cache-Control", "max-age="+strconv.Itoa(int(maxAge.Seconds())))
+	w.Header().Set("ETag", etag)
+	return etagMatches(r.Header.Get("If-None-Match"), etag)
+}
+
+// etagMatches implements the weak comparison If-None-Match uses, including "*" and lists
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeCachedJSON writes v as JSON with caching headers, or 304 Not Modified if the client has it
+func writeCachedJSON(w http.ResponseWriter, r *http.Request, v any, maxAge time.Duration) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+
+	if setCacheHeaders(w, r, contentETag(data), maxAge) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// fileETags caches content-hash ETags of files in an embedded FS, keyed by name.
+// Embedded files can't change while the server runs, so each is hashed once.
+type fileETags struct {
+	mu    sync.Mutex
+	fsys  fs.FS
+	etags map[string]string
+}
+
+// staticETags holds ETags for the embedded web UI and sample boarding pass images
+var staticETags = &fileETags{fsys: static.FS, etags: make(map[string]string)}
+
+// get returns the ETag for the named file, hashing it on first use
+func (c *fileETags) get(name string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if etag, ok := c.etags[name]; ok {
+		return etag, nil
+	}
+	data, err := fs.ReadFile(c.fsys, name)
+	if err != nil {
+		return "", err
+	}
+	etag := contentETag(data)
+	c.etags[name] = etag
+	return etag, nil
+}
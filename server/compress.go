@@ -0,0 +1,207 @@
+package server
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// compressMinSize is the smallest JSON response worth compressing
+	compressMinSize = 1024
+	// maxDecompressedBytes caps a compressed request body once inflated, so a small upload
+	// can't expand into gigabytes (a "zip bomb")
+	maxDecompressedBytes = 50 << 20 // 50MB
+)
+
+// compressMiddleware gzips (or deflates) JSON responses of at least compressMinSize bytes when
+// the client accepts it. Other content types, including SSE streams (where compression would
+// hold back incremental flushes), and WebSocket upgrades pass through untouched.
+func compressMiddleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+			if encoding == "" || r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{ResponseWriter: w, encoding: encoding, status: http.StatusOK}
+			defer cw.Close()
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// negotiateEncoding picks gzip or deflate from an Accept-Encoding header, preferring gzip
+func negotiateEncoding(header string) string {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+				continue
+			}
+		}
+		accepted[name] = true
+	}
+
+	switch {
+	case accepted["gzip"]:
+		return "gzip"
+	case accepted["deflate"]:
+		return "deflate"
+	}
+	return ""
+}
+
+// compressWriter buffers the start of a JSON response and compresses it once it reaches
+// compressMinSize. Smaller responses and non-JSON responses are written unchanged.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding    string
+	status      int
+	wroteHeader bool           // Handler called WriteHeader (or Write)
+	passthrough bool           // Response is written uncompressed as-is
+	buf         []byte         // Buffered body while deciding whether to compress
+	enc         io.WriteCloser // Set once compression has started
+}
+
+// WriteHeader records the status and decides whether the response is eligible for compression
+func (c *compressWriter) WriteHeader(status int) {
+	if c.wroteHeader {
+		return
+	}
+	c.wroteHeader = true
+	c.status = status
+
+	h := c.Header()
+	eligible := strings.HasPrefix(h.Get("Content-Type"), "application/json") &&
+		h.Get("Content-Encoding") == "" &&
+		status != http.StatusNoContent && status != http.StatusNotModified
+	if !eligible {
+		c.passthrough = true
+		c.ResponseWriter.WriteHeader(status)
+		return
+	}
+	h.Add("Vary", "Accept-Encoding")
+}
+
+// Write buffers until the response is large enough to compress
+func (c *compressWriter) Write(p []byte) (int, error) {
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
+	}
+	if c.passthrough {
+		return c.ResponseWriter.Write(p)
+	}
+	if c.enc != nil {
+		return c.enc.Write(p)
+	}
+
+	c.buf = append(c.buf, p...)
+	if len(c.buf) >= compressMinSize {
+		if err := c.startCompression(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// startCompression sends the headers and the buffered body through the encoder
+func (c *compressWriter) startCompression() error {
+	h := c.Header()
+	h.Set("Content-Encoding", c.encoding)
+	h.Del("Content-Length")
+	c.ResponseWriter.WriteHeader(c.status)
+
+	if c.encoding == "gzip" {
+		c.enc = gzip.NewWriter(c.ResponseWriter)
+	} else {
+		// HTTP deflate is zlib-wrapped (RFC 9110), not a raw DEFLATE stream
+		c.enc, _ = zlib.NewWriterLevel(c.ResponseWriter, zlib.DefaultCompression)
+	}
+
+	buf := c.buf
+	c.buf = nil
+	_, err := c.enc.Write(buf)
+	return err
+}
+
+// Flush sends buffered data to the client; an eligible response starts compressing immediately
+func (c *compressWriter) Flush() {
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
+	}
+	if !c.passthrough {
+		if c.enc == nil {
+			c.startCompression()
+		}
+		if f, ok := c.enc.(interface{ Flush() error }); ok {
+			f.Flush()
+		}
+	}
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close finishes the response, writing small responses uncompressed
+func (c *compressWriter) Close() {
+	switch {
+	case !c.wroteHeader || c.passthrough:
+		return
+	case c.enc != nil:
+		c.enc.Close()
+	default:
+		c.ResponseWriter.WriteHeader(c.status)
+		c.ResponseWriter.Write(c.buf)
+	}
+}
+
+// Unwrap exposes the underlying ResponseWriter to http.ResponseController
+func (c *compressWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// decompressMiddleware inflates request bodies sent with Content-Encoding gzip or deflate, e.g. an
+// image upload gzipped by a mobile client on a slow network, so handlers parse them as usual.
+// The inflated body is capped at maxDecompressedBytes; reading past it fails with an
+// *http.MaxBytesError, which the JSON handlers report as 413. Other encodings, such as br (which
+// the standard library can't decode), are rejected with 415 and the supported list.
+func decompressMiddleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var body io.ReadCloser
+			var err error
+			switch encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))); encoding {
+			case "", "identity":
+				next.ServeHTTP(w, r)
+				return
+			case "gzip", "x-gzip":
+				body, err = gzip.NewReader(r.Body)
+			case "deflate":
+				body, err = zlib.NewReader(r.Body)
+			default:
+				w.Header().Set("Accept-Encoding", "gzip, deflate")
+				http.Error(w, "Unsupported Content-Encoding "+encoding+" (expected gzip or deflate)", http.StatusUnsupportedMediaType)
+				return
+			}
+			if err != nil {
+				http.Error(w, "Invalid compressed request body: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			defer body.Close()
+
+			r.Body = http.MaxBytesReader(w, body, maxDecompressedBytes)
+			r.Header.Del("Content-Encoding")
+			r.Header.Del("Content-Length")
+			r.ContentLength = -1
+			next.ServeHTTP(w, r)
+		})
+	}
+}
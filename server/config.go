@@ -0,0 +1,66 @@
+package server
+
+import (
+	"os"
+	"time"
+
+	"github.com/abhirockzz/flight-log-app/ai"
+	"github.com/abhirockzz/flight-log-app/cosmosdb"
+)
+
+// Config holds the server's settings. Start from DefaultConfig and pass it to New with WithConfig;
+// the app fills it from environment variables in main (see loadConfig there).
+type Config struct {
+	UploadDir            string   // Where uploads are written; shared with the Copilot CLI container in Docker Compose
+	AllowedOrigins       []string // Origins allowed by CORS; "*" allows any
+	AdminToken           string   // Bearer token for /api/admin routes; empty disables them
+	ImageURLAllowedHosts []string // Hosts POST /api/extract/url may download from (and their subdomains); empty allows any public host
+	ExtractionPrompt     string   // Custom extraction system prompt; empty uses the built-in one
+	FallbackModels       []string // Models extraction falls back to; nil uses the free vision-capable models
+	ModelsRefresh        time.Duration
+	DisableCompression   bool // Leave JSON responses uncompressed, e.g. when a proxy compresses them
+	MetricsEnabled       bool // Serve Prometheus metrics at /metrics
+
+	ChatCacheSize  int           // Chat query results cached; 0 disables the cache
+	ChatCacheTTL   time.Duration // How long a cached query result is reused; 0 disables the cache
+	ChatMaxQueries int           // Generated queries allowed per chat question
+	ChatAllowedSQL []string      // Changes to the SQL keywords and functions chat queries may use; see ai.WithAllowedSQL
+	Location       *time.Location
+
+	ModelFailureThreshold int           // Consecutive failures that demote a model; 0 disables demotion
+	ModelFailureWindow    time.Duration // Failures further apart don't count as consecutive
+	ModelCooldown         time.Duration // How long a demoted model is passed over
+
+	TrashRetention     time.Duration // Trashed flights older than this are purged; 0 keeps them
+	SampleDefaultCount int           // Sample flights loaded when ?count is not given
+	SampleMaxCount     int           // Largest ?count accepted; 0 allows every template
+	SampleDataTTL      time.Duration // Lifetime of sample flights; 0 keeps them forever
+
+	SeatLayout cosmosdb.SeatLayout // Cabin layout GET /api/stats assumes when classifying window, middle, and aisle seats
+}
+
+// DefaultConfig returns the settings used when New is given no WithConfig option
+func DefaultConfig() Config {
+	return Config{
+		UploadDir:             os.TempDir(),
+		AllowedOrigins:        []string{"*"},
+		ModelsRefresh:         defaultModelsRefreshInterval,
+		ChatCacheSize:         ai.DefaultQueryCacheSize,
+		ChatCacheTTL:          ai.DefaultQueryCacheTTL,
+		ChatMaxQueries:        ai.DefaultMaxQueries,
+		Location:              time.Local,
+		ModelFailureThreshold: defaultModelFailureThreshold,
+		ModelFailureWindow:    defaultModelFailureWindow,
+		ModelCooldown:         defaultModelCooldown,
+		SampleDefaultCount:    defaultSampleCount,
+		SampleDataTTL:         defaultSampleDataTTL,
+		SeatLayout:            cosmosdb.DefaultSeatLayout,
+	}
+}
+
+// WithConfig replaces the default settings with cfg
+func WithConfig(cfg Config) Option {
+	return func(s *Server) {
+		s.cfg = cfg
+	}
+}
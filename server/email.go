@@ -0,0 +1,61 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+)
+
+// maxEmailLength is the longest address SMTP allows (RFC 5321)
+const maxEmailLength = 254
+
+// errInvalidEmail is returned by validateEmail
+var errInvalidEmail = errors.New("invalid email")
+
+// validateEmail checks that an email used as a partition key is a plain name@domain address.
+// Quotes, backslashes, whitespace, and control characters are rejected even where RFC 5322 would
+// allow them, since the email ends up inside SQL string literals written by the chat model.
+func validateEmail(email string) error {
+	if len(email) > maxEmailLength {
+		return fmt.Errorf("%w: longer than %d characters", errInvalidEmail, maxEmailLength)
+	}
+	for _, r := range email {
+		switch {
+		case r == '\'' || r == '"' || r == '`' || r == '\\':
+			return fmt.Errorf("%w: quotes and backslashes are not allowed", errInvalidEmail)
+		case unicode.IsSpace(r) || unicode.IsControl(r):
+			return fmt.Errorf("%w: whitespace and control characters are not allowed", errInvalidEmail)
+		}
+	}
+
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok || local == "" || domain == "" || strings.Contains(domain, "@") {
+		return fmt.Errorf("%w: expected name@domain", errInvalidEmail)
+	}
+	if strings.HasPrefix(domain, ".") || strings.HasSuffix(domain, ".") || strings.Contains(domain, "..") {
+		return fmt.Errorf("%w: malformed domain %q", errInvalidEmail, domain)
+	}
+	return nil
+}
+
+// emailMiddleware rejects requests whose X-User-Email header or email query parameter is not a
+// valid email with 400, so handlers can pass either straight to the store as a partition key.
+// Emails in request bodies are checked by the handlers that accept them.
+func emailMiddleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, email := range []string{r.Header.Get("X-User-Email"), r.URL.Query().Get("email")} {
+				if email == "" {
+					continue
+				}
+				if err := validateEmail(email); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/abhirockzz/flight-log-app/cosmosdb"
+	"github.com/abhirockzz/flight-log-app/redact"
+)
+
+const (
+	// exportFormatVersion is bumped when ExportDocument changes incompatibly
+	exportFormatVersion = 1
+
+	// maxImportFlights caps the flights in one import; larger histories can be split across documents
+	maxImportFlights = 5000
+
+	// maxImportBodyBytes allows for maxImportFlights flights of a few hundred bytes each
+	maxImportBodyBytes = 16 << 20 // 16MB
+)
+
+// ExportDocument is a user's flights as written by GET /api/export and read by POST /api/import.
+// Flights keep every stored field, so a round trip is lossless apart from the store's ETags.
+type ExportDocument struct {
+	Version    int                     `json:"version"`
+	Email      string                  `json:"email"`      // Owner at export time; import assigns flights to ?email= instead
+	ExportedAt string                  `json:"exportedAt"` // RFC3339
+	Flights    []cosmosdb.BoardingPass `json:"flights"`
+}
+
+// ImportSkip explains why a flight in an import document was not created
+type ImportSkip struct {
+	Index  int    `json:"index"` // Position in the document's flights array
+	Reason string `json:"reason"`
+}
+
+// ImportResponse is the response from POST /api/import
+type ImportResponse struct {
+	Imported int          `json:"imported"`
+	Skipped  int          `json:"skipped"`
+	Skips    []ImportSkip `json:"skips,omitempty"`
+}
+
+// handleExport returns the user's flights (excluding the trash) as a downloadable ExportDocument
+func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
+	email := r.URL.Query().Get("email")
+	if email == "" {
+		http.Error(w, "email query parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	flights, err := s.store.ListFlights(r.Context(), email)
+	if err != nil {
+		log.Printf("Failed to list flights for export: %v", err)
+		http.Error(w, "Failed to export flights: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// ETags belong to the source store and would be meaningless after import
+	for i := range flights {
+		flights[i].ETag = ""
+	}
+
+	now := time.Now().UTC()
+	doc := ExportDocument{
+		Version:    exportFormatVersion,
+		Email:      email,
+		ExportedAt: now.Format(time.RFC3339),
+		Flights:    flights,
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="flightlog-export-%s.json"`, now.Format("2006-01-02")))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(doc)
+}
+
+// handleImport recreates flights from an ExportDocument for the user in ?email=. Flights get new IDs
+// unless ?preserveIds=true, which keeps them (e.g. restoring a backup into an empty environment).
+// Invalid flights, duplicates of existing flights, and ID collisions are skipped and reported.
+func (s *Server) handleImport(w http.ResponseWriter, r *http.Request) {
+	email := r.URL.Query().Get("email")
+	if email == "" {
+		http.Error(w, "email query parameter is required", http.StatusBadRequest)
+		return
+	}
+	preserveIDs := r.URL.Query().Get("preserveIds") == "true"
+
+	var doc ExportDocument
+	if !decodeJSONBodyLimit(w, r, &doc, maxImportBodyBytes) {
+		return
+	}
+	if doc.Version < 1 || doc.Version > exportFormatVersion {
+		http.Error(w, fmt.Sprintf("Unsupported export version %d (expected %d)", doc.Version, exportFormatVersion), http.StatusBadRequest)
+		return
+	}
+	if len(doc.Flights) > maxImportFlights {
+		http.Error(w, fmt.Sprintf("Too many flights (max %d per import)", maxImportFlights), http.StatusBadRequest)
+		return
+	}
+
+	resp := ImportResponse{}
+	skip := func(index int, reason string) {
+		resp.Skips = append(resp.Skips, ImportSkip{Index: index, Reason: reason})
+		resp.Skipped++
+	}
+
+	index := make(map[*cosmosdb.BoardingPass]int, len(doc.Flights))
+	pending := make([]*cosmosdb.BoardingPass, 0, len(doc.Flights))
+	for i := range doc.Flights {
+		flight := &doc.Flights[i]
+		flight.Email = email
+		flight.ETag = ""
+		clearServerFields(flight)
+		if !preserveIDs {
+			flight.ID = ""
+		}
+		if err := flight.Validate(); err != nil {
+			skip(i, err.Error())
+			continue
+		}
+		index[flight] = i
+		pending = append(pending, flight)
+	}
+
+	saved, err := s.store.SaveFlightsBatch(r.Context(), pending)
+	resp.Imported = len(saved)
+	if len(saved) > 0 {
+		s.chatHandler.InvalidateCache(email)
+	}
+
+	itemErrs := cosmosdb.ItemErrors(err)
+	for _, itemErr := range itemErrs {
+		skip(index[itemErr.Flight], itemErr.Err.Error())
+	}
+	// A failure of the whole batch leaves the unsaved flights without an item error
+	if len(saved)+len(itemErrs) < len(pending) {
+		log.Printf("Import for %s saved %d of %d flights: %v", redact.Email(email), len(saved), len(pending), err)
+		http.Error(w, fmt.Sprintf("Import failed after %d flights: %v", len(saved), err), http.StatusInternalServerError)
+		return
+	}
+	slices.SortFunc(resp.Skips, func(a, b ImportSkip) int { return a.Index - b.Index })
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
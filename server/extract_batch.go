@@ -0,0 +1,161 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+
+	"github.com/abhirockzz/flight-log-app/ai"
+	"github.com/abhirockzz/flight-log-app/cosmosdb"
+	"github.com/abhirockzz/flight-log-app/redact"
+)
+
+const (
+	// maxBatchImages caps the number of images in one batch extraction request
+	maxBatchImages = 50
+
+	// maxBatchBodyBytes caps the whole multipart body; each image is still limited to maxBatchImageBytes
+	maxBatchBodyBytes = 200 << 20 // 200MB
+
+	// maxBatchImageBytes matches the single-image limit of POST /api/extract
+	maxBatchImageBytes = 10 << 20 // 10MB
+
+	// batchFormMemory is how much of the form is kept in memory; larger parts spill to temp files
+	batchFormMemory = 32 << 20 // 32MB
+)
+
+// BatchItemEvent is the payload of a result event from POST /api/extract/batch.
+// Exactly one of Flight and Error is set.
+type BatchItemEvent struct {
+	Index    int                    `json:"index"` // Position of the image in the request, from 0
+	Filename string                 `json:"filename,omitempty"`
+	Flight   *cosmosdb.BoardingPass `json:"flight,omitempty"`
+	Error    *ErrorEvent            `json:"error,omitempty"`
+}
+
+// BatchSummaryEvent is the payload of the final summary event from POST /api/extract/batch
+type BatchSummaryEvent struct {
+	Total     int  `json:"total"`
+	Succeeded int  `json:"succeeded"`
+	Failed    int  `json:"failed"`
+	Cancelled bool `json:"cancelled,omitempty"` // The client disconnected before every image was processed
+}
+
+// handleExtractBatch extracts flights from several images uploaded as repeated "image" fields.
+// Images are processed one at a time to stay within model rate limits. Each gets a progress event
+// ({"index":i,"total":n,"filename":...}) and then a result event with either the flight or an error,
+// so one unreadable image doesn't stop the rest; a summary event ends the stream.
+// Extracted flights are not saved; the client confirms them through POST /api/flights or /api/flights/bulk.
+func (s *Server) handleExtractBatch(w http.ResponseWriter, r *http.Request) {
+	email := r.Header.Get("X-User-Email")
+	if email == "" {
+		http.Error(w, "X-User-Email header is required", http.StatusBadRequest)
+		return
+	}
+
+	if !s.requireCopilot(w) {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBatchBodyBytes)
+	if err := r.ParseMultipartForm(batchFormMemory); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, fmt.Sprintf("Request body too large (max %d bytes)", maxBytesErr.Limit), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Failed to parse form: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	files := r.MultipartForm.File["image"]
+	if len(files) == 0 {
+		http.Error(w, "At least one image is required", http.StatusBadRequest)
+		return
+	}
+	if len(files) > maxBatchImages {
+		http.Error(w, fmt.Sprintf("Too many images (max %d per request)", maxBatchImages), http.StatusBadRequest)
+		return
+	}
+
+	model := r.FormValue("model")
+	if model == "" {
+		model = s.getDefaultModel()
+	}
+	if !s.checkVisionModel(w, model) {
+		return
+	}
+	log.Printf("[EXTRACT] Batch of %d images | User: %s | Model: %s", len(files), redact.Email(email), model)
+
+	sse, ok := newSSEWriter(w)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	defer sse.Close()
+	sse.StartHeartbeat(r.Context())
+
+	summary := BatchSummaryEvent{Total: len(files)}
+	for i, header := range files {
+		if r.Context().Err() != nil {
+			summary.Cancelled = true
+			break
+		}
+		sse.Send("progress", fmt.Sprintf(`{"index":%d,"total":%d,"filename":%q}`, i, len(files), header.Filename))
+
+		result := BatchItemEvent{Index: i, Filename: header.Filename}
+		flight, err := s.extractUploadedFile(r, header, email, model)
+		if err != nil {
+			log.Printf("[EXTRACT] Batch image %d (%s) failed | User: %s | %v", i, header.Filename, redact.Email(email), err)
+			event := newErrorEvent(err)
+			result.Error = &event
+			summary.Failed++
+		} else {
+			result.Flight = flight
+			summary.Succeeded++
+		}
+		data, _ := json.Marshal(result)
+		sse.Send("result", string(data))
+	}
+
+	data, _ := json.Marshal(summary)
+	sse.Send("summary", string(data))
+	sse.Send("done", "")
+}
+
+// extractUploadedFile saves one multipart image to the upload dir, normalizes its orientation,
+// and runs extraction on it, removing the temp files afterwards
+func (s *Server) extractUploadedFile(r *http.Request, header *multipart.FileHeader, email, model string) (*cosmosdb.BoardingPass, error) {
+	if header.Size > maxBatchImageBytes {
+		return nil, fmt.Errorf("image too large (max %d bytes)", maxBatchImageBytes)
+	}
+	file, err := header.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	defer file.Close()
+
+	imagePath, err := s.saveUpload(file, header.Filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save image: %w", err)
+	}
+	defer os.Remove(imagePath)
+
+	normalized, err := ai.NormalizeImage(imagePath)
+	if err != nil {
+		log.Printf("[EXTRACT] Image normalization failed, using original: %v", err)
+	}
+	if normalized != imagePath {
+		defer os.Remove(normalized)
+		imagePath = normalized
+	}
+
+	// The single-image step events are dropped; batch progress is reported per file instead
+	s.recordUsage(email, model)
+	return s.extractor.Extract(r.Context(), imagePath, email, s.extractionModels(model), func(string, string) {})
+}
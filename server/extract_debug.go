@@ -0,0 +1,76 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/abhirockzz/flight-log-app/ai"
+	"github.com/abhirockzz/flight-log-app/cosmosdb"
+	"github.com/abhirockzz/flight-log-app/redact"
+)
+
+// ExtractDebugResponse is the result of POST /api/extract/debug: the extraction alongside
+// the exact image the model saw, for diagnosing orientation and cropping problems
+type ExtractDebugResponse struct {
+	Extracted   *cosmosdb.BoardingPass `json:"extracted,omitempty"`
+	Error       *ErrorEvent            `json:"error,omitempty"`       // Set when extraction failed; the image is still returned
+	Image       string                 `json:"image"`                 // Base64 of the image sent to the model
+	ContentType string                 `json:"contentType,omitempty"` // MIME type of Image, sniffed from its bytes
+	Normalized  bool                   `json:"normalized"`            // True if the image was rotated per its EXIF orientation
+}
+
+// handleExtractDebug runs extraction on an uploaded image and returns the result as JSON
+// together with the (possibly EXIF-normalized) image that was sent to the model.
+// The model only reports field values, not where they are on the image, so no overlay is drawn.
+func (s *Server) handleExtractDebug(w http.ResponseWriter, r *http.Request) {
+	email := r.Header.Get("X-User-Email")
+	if email == "" {
+		http.Error(w, "X-User-Email header is required", http.StatusBadRequest)
+		return
+	}
+
+	if !s.requireCopilot(w) {
+		return
+	}
+
+	imagePath, model, ok := s.receiveImage(w, r)
+	if !ok {
+		return
+	}
+	defer os.Remove(imagePath)
+
+	// Same preprocessing as streamExtraction, so the debug image matches what extraction sees
+	resp := ExtractDebugResponse{}
+	normalized, err := ai.NormalizeImage(imagePath)
+	if err != nil {
+		log.Printf("[EXTRACT] Image normalization failed, using original: %v", err)
+	}
+	if normalized != imagePath {
+		defer os.Remove(normalized)
+		imagePath = normalized
+		resp.Normalized = true
+	}
+
+	data, err := os.ReadFile(imagePath)
+	if err != nil {
+		http.Error(w, "Failed to read image: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp.Image = base64.StdEncoding.EncodeToString(data)
+	resp.ContentType = http.DetectContentType(data)
+
+	s.recordUsage(email, model)
+	flight, err := s.extractor.Extract(r.Context(), imagePath, email, s.extractionModels(model), func(string, string) {})
+	if err != nil {
+		log.Printf("[EXTRACT] Debug extraction failed | User: %s | Model: %s | %v", redact.Email(email), model, err)
+		event := newErrorEvent(err)
+		resp.Error = &event
+	}
+	resp.Extracted = flight
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,224 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/abhirockzz/flight-log-app/redact"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxImageDownloadBytes matches the multipart upload limit
+	maxImageDownloadBytes = 10 << 20 // 10MB
+
+	// imageDownloadTimeout bounds the whole download, including redirects
+	imageDownloadTimeout = 15 * time.Second
+
+	// maxImageRedirects is how many redirects a download may follow
+	maxImageRedirects = 3
+)
+
+// imageExtensions maps accepted image content types to the temp file extension
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// ExtractURLRequest is the body of POST /api/extract/url
+type ExtractURLRequest struct {
+	URL   string `json:"url"`
+	Model string `json:"model"`
+}
+
+// imageFetcher downloads boarding pass images from user-supplied URLs. Only https is allowed,
+// hosts can be restricted with Config.ImageURLAllowedHosts, and connections to loopback, private,
+// and link-local addresses are refused at dial time so DNS tricks can't reach internal services.
+type imageFetcher struct {
+	client       *http.Client
+	dir          string   // Where downloads are written
+	allowedHosts []string // Empty allows any public host
+}
+
+// newImageFetcher creates a fetcher that downloads into dir from allowedHosts (subdomains of a
+// listed host are allowed too)
+func newImageFetcher(dir string, allowedHosts []string) *imageFetcher {
+	f := &imageFetcher{dir: dir}
+	for _, host := range allowedHosts {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			f.allowedHosts = append(f.allowedHosts, host)
+		}
+	}
+
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: refusePrivateAddresses,
+	}
+	f.client = &http.Client{
+		Timeout: imageDownloadTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxImageRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxImageRedirects)
+			}
+			return f.checkURL(req.URL)
+		},
+	}
+	return f
+}
+
+// checkURL verifies the scheme and host of a download URL (before and after redirects)
+func (f *imageFetcher) checkURL(u *url.URL) error {
+	if u.Scheme != "https" {
+		return errors.New("only https URLs are allowed")
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return errors.New("URL has no host")
+	}
+	if len(f.allowedHosts) == 0 {
+		return nil
+	}
+	for _, allowed := range f.allowedHosts {
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return nil
+		}
+	}
+	return fmt.Errorf("host %s is not allowed", host)
+}
+
+// refusePrivateAddresses is a net.Dialer Control func that refuses non-public destinations.
+// It runs after DNS resolution, on the address actually being connected to.
+func refusePrivateAddresses(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("invalid address %s", host)
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsUnspecified() || ip.IsMulticast() || ip.IsInterfaceLocalMulticast() {
+		return fmt.Errorf("address %s is not publicly routable", ip)
+	}
+	return nil
+}
+
+// unwrapURLError drops the URL that *url.Error repeats in its message, since it may hold a
+// signed query string and the error is logged
+func unwrapURLError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
+
+// download fetches an image into a new temp file in the fetcher's dir and returns its path.
+// The caller must remove the file.
+func (f *imageFetcher) download(ctx context.Context, rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %w", unwrapURLError(err))
+	}
+	if err := f.checkURL(u); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("download failed: %w", unwrapURLError(err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("download failed: %s", resp.Status)
+	}
+	contentType, _, _ := strings.Cut(resp.Header.Get("Content-Type"), ";")
+	ext, ok := imageExtensions[strings.ToLower(strings.TrimSpace(contentType))]
+	if !ok {
+		return "", fmt.Errorf("URL is not a supported image (content type %q)", contentType)
+	}
+	if resp.ContentLength > maxImageDownloadBytes {
+		return "", fmt.Errorf("image is too large (max %d bytes)", maxImageDownloadBytes)
+	}
+
+	tempFile := filepath.Join(f.dir, uploadFilePrefix+uuid.New().String()+ext)
+	out, err := os.Create(tempFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to save image: %w", err)
+	}
+
+	// Read one byte past the limit to detect oversized bodies without a Content-Length
+	n, err := io.Copy(out, io.LimitReader(resp.Body, maxImageDownloadBytes+1))
+	out.Close()
+	if err == nil && n > maxImageDownloadBytes {
+		err = fmt.Errorf("image is too large (max %d bytes)", maxImageDownloadBytes)
+	}
+	if err != nil {
+		os.Remove(tempFile)
+		return "", err
+	}
+	return tempFile, nil
+}
+
+// handleExtractURL downloads a boarding pass image from a URL and streams extraction like handleExtract
+func (s *Server) handleExtractURL(w http.ResponseWriter, r *http.Request) {
+	email := r.Header.Get("X-User-Email")
+	if email == "" {
+		http.Error(w, "X-User-Email header is required", http.StatusBadRequest)
+		return
+	}
+
+	if !s.requireCopilot(w) {
+		return
+	}
+
+	var req ExtractURLRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if strings.TrimSpace(req.URL) == "" {
+		http.Error(w, "url is required", http.StatusBadRequest)
+		return
+	}
+
+	model := req.Model
+	if model == "" {
+		model = s.getDefaultModel()
+	}
+	if !s.checkVisionModel(w, model) {
+		return
+	}
+
+	tempFile, err := s.fetcher.download(r.Context(), req.URL)
+	if err != nil {
+		log.Printf("[EXTRACT] Image download rejected | User: %s | URL: %s | %v", redact.Email(email), redact.URL(req.URL), err)
+		http.Error(w, "Failed to fetch image: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer os.Remove(tempFile)
+
+	s.streamExtraction(w, r, tempFile, email, model, "")
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/abhirockzz/flight-log-app/ai"
+)
+
+const (
+	// defaultModelFailureThreshold is how many consecutive failures demote a model
+	defaultModelFailureThreshold = 3
+	// defaultModelFailureWindow is how close together failures must be to count as consecutive
+	defaultModelFailureWindow = 5 * time.Minute
+	// defaultModelCooldown is how long a demoted model is passed over
+	defaultModelCooldown = 10 * time.Minute
+)
+
+// ModelHealth is a model's recent reliability, reported by /api/models for debugging
+type ModelHealth struct {
+	Demoted             bool   `json:"demoted"`
+	ConsecutiveFailures int    `json:"consecutiveFailures"`
+	DemotedUntil        string `json:"demotedUntil,omitempty"` // RFC3339
+	LastError           string `json:"lastError,omitempty"`
+}
+
+// modelState is the failure history of one model
+type modelState struct {
+	failures     int
+	lastFailure  time.Time
+	demotedUntil time.Time
+	lastError    string
+}
+
+// healthTracker demotes models that keep failing, so they aren't picked as the default or as an
+// extraction fallback until a cooldown passes. Only unavailability and timeouts count as failures;
+// a success resets the count. It is kept in memory only.
+type healthTracker struct {
+	mu        sync.Mutex
+	models    map[string]*modelState
+	threshold int
+	window    time.Duration
+	cooldown  time.Duration
+	now       func() time.Time
+}
+
+func newHealthTracker(threshold int, window, cooldown time.Duration) *healthTracker {
+	return &healthTracker{
+		models:    make(map[string]*modelState),
+		threshold: threshold,
+		window:    window,
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+// isModelFailure reports whether err says more about the model than the request
+func isModelFailure(err error) bool {
+	return errors.Is(err, ai.ErrModelUnavailable) ||
+		errors.Is(err, ai.ErrExtractionTimeout) ||
+		errors.Is(err, ai.ErrChatTimeout)
+}
+
+// record notes the outcome of an extraction attempt or chat on model
+func (t *healthTracker) record(model string, err error) {
+	if model == "" || t.threshold == 0 {
+		return
+	}
+	if err != nil && !isModelFailure(err) {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	state := t.models[model]
+	if err == nil {
+		if state != nil && state.failures > 0 {
+			state.failures = 0
+			state.demotedUntil = time.Time{}
+		}
+		return
+	}
+
+	if state == nil {
+		state = &modelState{}
+		t.models[model] = state
+	}
+	now := t.now()
+	if now.Sub(state.lastFailure) > t.window {
+		state.failures = 0
+	}
+	state.failures++
+	state.lastFailure = now
+	state.lastError = err.Error()
+	if state.failures >= t.threshold && !now.Before(state.demotedUntil) {
+		state.demotedUntil = now.Add(t.cooldown)
+		log.Printf("[MODELS] Demoting %s for %v after %d consecutive failures: %v", model, t.cooldown, state.failures, err)
+	}
+}
+
+// healthy reports whether model is not currently demoted
+func (t *healthTracker) healthy(model string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	state := t.models[model]
+	return state == nil || !t.now().Before(state.demotedUntil)
+}
+
+// health returns model's current health, or nil if it has no recent failures
+func (t *healthTracker) health(model string) *ModelHealth {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	state := t.models[model]
+	if state == nil || state.failures == 0 {
+		return nil
+	}
+	h := &ModelHealth{ConsecutiveFailures: state.failures, LastError: state.lastError}
+	if t.now().Before(state.demotedUntil) {
+		h.Demoted = true
+		h.DemotedUntil = state.demotedUntil.UTC().Format(time.RFC3339)
+	}
+	return h
+}
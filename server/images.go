@@ -0,0 +1,132 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/abhirockzz/flight-log-app/cosmosdb"
+	"github.com/google/uuid"
+)
+
+// ImageStore keeps boarding pass images after their flight is saved. blobstore.Client implements it
+// against Azure Blob Storage; without one, images are discarded once extraction finishes.
+type ImageStore interface {
+	// Upload stores the file at path under name and returns the URL recorded on the flight
+	Upload(ctx context.Context, name, path string) (string, error)
+	// Name returns the name of an image URL returned by Upload, or false if it isn't in this store
+	Name(url string) (string, bool)
+	// Open returns the named image and its content type; the error wraps fs.ErrNotExist if it is missing
+	Open(ctx context.Context, name string) (io.ReadCloser, string, error)
+	// Delete removes the named image
+	Delete(ctx context.Context, name string) error
+}
+
+// WithImageStore keeps the uploaded image of each flight saved with ?uploadId= in store
+func WithImageStore(store ImageStore) Option {
+	return func(s *Server) {
+		s.images = store
+	}
+}
+
+// imageOwnerPrefix is the blob name prefix for a user's images. The email is hashed so it doesn't
+// appear in URLs, and the prefix lets the image handler refuse URLs pointing at another user's images.
+func imageOwnerPrefix(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(email)))
+	return hex.EncodeToString(sum[:16]) + "/"
+}
+
+// attachImage uploads the kept upload uploadID to the image store, records its URL on the flight,
+// and reports whether it did. Image storage is best effort: failures are logged and the flight is
+// saved without an image.
+func (s *Server) attachImage(ctx context.Context, flight *cosmosdb.BoardingPass, uploadID string) bool {
+	if s.images == nil || uploadID == "" {
+		return false
+	}
+	path, ok := s.uploads.Get(uploadID, flight.Email)
+	if !ok {
+		log.Printf("[IMAGES] Upload %s not found or expired; saving flight without an image", uploadID)
+		return false
+	}
+
+	name := imageOwnerPrefix(flight.Email) + uuid.New().String() + strings.ToLower(filepath.Ext(path))
+	url, err := s.images.Upload(ctx, name, path)
+	if err != nil {
+		log.Printf("[IMAGES] Failed to store image; saving flight without it: %v", err)
+		return false
+	}
+	flight.ImageURL = url
+	return true
+}
+
+// deleteImage removes a flight's image from the image store, if it has one there
+func (s *Server) deleteImage(ctx context.Context, flight *cosmosdb.BoardingPass) {
+	if s.images == nil || flight.ImageURL == "" {
+		return
+	}
+	name, ok := s.images.Name(flight.ImageURL)
+	if !ok || !strings.HasPrefix(name, imageOwnerPrefix(flight.Email)) {
+		return
+	}
+	if err := s.images.Delete(ctx, name); err != nil {
+		log.Printf("[IMAGES] Failed to delete image %s: %v", name, err)
+	}
+}
+
+// handleGetFlightImage proxies a flight's stored boarding pass image, so the blob container stays private
+func (s *Server) handleGetFlightImage(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	email := r.URL.Query().Get("email")
+	if email == "" {
+		http.Error(w, "email query parameter is required", http.StatusBadRequest)
+		return
+	}
+	if s.images == nil {
+		http.Error(w, "Image storage is not configured", http.StatusNotFound)
+		return
+	}
+
+	flight, err := s.store.GetFlight(r.Context(), id, email)
+	if errors.Is(err, cosmosdb.ErrNotFound) {
+		http.Error(w, "Flight not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to get flight: %v", err)
+		http.Error(w, "Failed to get flight: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// The URL is client-editable through PUT, so only serve images stored under the owner's prefix
+	name, ok := s.images.Name(flight.ImageURL)
+	if !ok || !strings.HasPrefix(name, imageOwnerPrefix(email)) {
+		http.Error(w, "Flight has no stored image", http.StatusNotFound)
+		return
+	}
+
+	body, contentType, err := s.images.Open(r.Context(), name)
+	if errors.Is(err, fs.ErrNotExist) {
+		http.Error(w, "Image not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[IMAGES] Failed to open image for flight %s: %v", id, err)
+		http.Error(w, "Failed to get image", http.StatusBadGateway)
+		return
+	}
+	defer body.Close()
+
+	if contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	// Image names are never reused, so the content can be cached
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	io.Copy(w, body)
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// Middleware wraps an http.Handler with additional behavior
+type Middleware func(http.Handler) http.Handler
+
+// chain applies middlewares to h so that the first middleware is the outermost
+func chain(h http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// corsMiddleware sets CORS headers for allowed origins and answers OPTIONS preflight requests
+func corsMiddleware(origins []string) Middleware {
+	allowAll := false
+	allowed := make(map[string]bool, len(origins))
+	for _, o := range origins {
+		if o == "*" {
+			allowAll = true
+		}
+		allowed[o] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			switch {
+			case allowAll:
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			case origin != "" && allowed[origin]:
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Add("Vary", "Origin")
+			}
+
+			// Let cross-origin clients read the ETag needed for conditional updates
+			w.Header().Set("Access-Control-Expose-Headers", "ETag, X-Total-Count")
+
+			// Preflight: advertise methods and the auth, custom email, and If-Match headers
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+				w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-User-Email, If-Match, Content-Encoding")
+				w.Header().Set("Access-Control-Max-Age", "600")
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// traceMiddleware starts a server span per request, continuing the caller's trace if the request
+// carries a traceparent header. Spans are named after the matching route, e.g. "GET /api/flights".
+func traceMiddleware(mux *http.ServeMux) Middleware {
+	return func(next http.Handler) http.Handler {
+		return otelhttp.NewHandler(next, "http.request",
+			otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+				if _, pattern := mux.Handler(r); pattern != "" {
+					return pattern
+				}
+				return r.Method
+			}),
+			// Scrapes would otherwise outnumber real requests
+			otelhttp.WithFilter(func(r *http.Request) bool { return r.URL.Path != "/metrics" }),
+		)
+	}
+}
@@ -0,0 +1,328 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/abhirockzz/flight-log-app/ai"
+	"github.com/abhirockzz/flight-log-app/cosmosdb"
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// openAPIComponent is a request or response struct published under components/schemas.
+// The schema is generated from the struct's json tags, so the spec follows the code.
+type openAPIComponent struct {
+	name string
+	typ  reflect.Type
+}
+
+// openAPIComponents lists the schema structs referenced by openAPIOperations
+var openAPIComponents = []openAPIComponent{
+	{"BoardingPass", reflect.TypeFor[cosmosdb.BoardingPass]()},
+	{"ChatRequest", reflect.TypeFor[ChatRequest]()},
+	{"ChatResponse", reflect.TypeFor[ai.ChatResponse]()},
+	{"ErrorEvent", reflect.TypeFor[ErrorEvent]()},
+	{"ModelResponse", reflect.TypeFor[ModelResponse]()},
+	{"ModelsListResponse", reflect.TypeFor[ModelsListResponse]()},
+	{"SampleDataResponse", reflect.TypeFor[SampleDataResponse]()},
+	{"ValidateResponse", reflect.TypeFor[ValidateResponse]()},
+	{"MilesResponse", reflect.TypeFor[MilesResponse]()},
+	{"ExtractURLRequest", reflect.TypeFor[ExtractURLRequest]()},
+	{"ExtractDebugResponse", reflect.TypeFor[ExtractDebugResponse]()},
+	{"BatchItemEvent", reflect.TypeFor[BatchItemEvent]()},
+	{"BatchSummaryEvent", reflect.TypeFor[BatchSummaryEvent]()},
+	{"Timeline", reflect.TypeFor[cosmosdb.Timeline]()},
+	{"BulkImportResponse", reflect.TypeFor[BulkImportResponse]()},
+	{"FlightRoute", reflect.TypeFor[cosmosdb.FlightRoute]()},
+	{"UsageResponse", reflect.TypeFor[UsageResponse]()},
+	{"ExportDocument", reflect.TypeFor[ExportDocument]()},
+	{"ImportResponse", reflect.TypeFor[ImportResponse]()},
+	{"ReadyResponse", reflect.TypeFor[ReadyResponse]()},
+	{"FlightStats", reflect.TypeFor[FlightStats]()},
+	{"ExplainRequest", reflect.TypeFor[ExplainRequest]()},
+	{"QueryPlan", reflect.TypeFor[cosmosdb.QueryPlan]()},
+	{"WSEvent", reflect.TypeFor[WSEvent]()},
+	{"GlobalStats", reflect.TypeFor[cosmosdb.GlobalStats]()},
+	{"NormalizeResponse", reflect.TypeFor[NormalizeResponse]()},
+}
+
+// openAPIParam is a query, path, or header parameter of an operation
+type openAPIParam struct {
+	name     string
+	in       string // "query", "path", or "header"
+	required bool
+	desc     string
+}
+
+// Parameters shared by most operations
+var (
+	emailQueryParam  = openAPIParam{"email", "query", true, "Owner's email (partition key)"}
+	emailHeaderParam = openAPIParam{"X-User-Email", "header", true, "Owner's email (partition key)"}
+	idPathParam      = openAPIParam{"id", "path", true, "Flight ID"}
+	adminTokenParam  = openAPIParam{"Authorization", "header", true, "Bearer ADMIN_TOKEN (or send it in X-Admin-Token); admin routes are 404 when no token is configured"}
+)
+
+// openAPIOperation describes one route. Schema names refer to openAPIComponents; a "[]" prefix means an array.
+type openAPIOperation struct {
+	method, path, summary string
+	params                []openAPIParam
+	request               string // Request body schema, "" for none
+	multipart             bool   // Request body is multipart/form-data (image upload)
+	batch                 bool   // The multipart image field may repeat
+	status                int    // Success status code
+	response              string // Response schema, "" for no body
+	stream                bool   // Response is a text/event-stream of progress events
+}
+
+// openAPIOperations documents every route under /api, plus /readyz. Static assets, sample images,
+// /metrics, and /openapi.json itself are left out.
+var openAPIOperations = []openAPIOperation{
+	{method: "get", path: "/api/flights", summary: "List a user's flights, optionally filtered", params: []openAPIParam{
+		emailQueryParam,
+		{"airline", "query", false, "Case-insensitive substring of the airline name"},
+		{"from", "query", false, "Departure airport code"},
+		{"to", "query", false, "Arrival airport code"},
+		{"after", "query", false, "Departing on or after this date (YYYY-MM-DD)"},
+		{"before", "query", false, "Departing on or before this date (YYYY-MM-DD)"},
+		{"tag", "query", false, "Flights carrying this tag, e.g. work"},
+		{"passenger", "query", false, "Passenger name, case-insensitive (see /api/flights/passengers)"},
+		{"sort", "query", false, "Sort field: departureDate (default), departureTime, flightNumber, airline, fromAirport, toAirport, or createdAt; returns one page"},
+		{"order", "query", false, "asc or desc (default desc without sort, asc with it); returns one page"},
+		{"page", "query", false, "1-based page number (default 1); returns one page"},
+		{"size", "query", false, "Flights per page, 1-100 (default 20); returns one page"},
+	}, status: http.StatusOK, response: "[]BoardingPass"},
+	{method: "post", path: "/api/flights", summary: "Save a flight", params: []openAPIParam{
+		{"force", "query", false, "Set to true to skip duplicate detection"},
+		{"uploadId", "query", false, "Upload kept by POST /api/extract with keepUpload=true; its image is stored with the flight when image storage is configured"},
+	}, request: "BoardingPass", status: http.StatusCreated, response: "BoardingPass"},
+	{method: "post", path: "/api/flights/bulk", summary: "Import many flights at once", params: []openAPIParam{
+		{"partial", "query", false, "Set to true to import the valid flights when some are invalid instead of rejecting the request"},
+	}, request: "[]BoardingPass", status: http.StatusOK, response: "BulkImportResponse"},
+	{method: "get", path: "/api/flights/all", summary: "List all of a user's flights", params: []openAPIParam{emailQueryParam},
+		status: http.StatusOK, response: "[]BoardingPass"},
+	{method: "get", path: "/api/flights/search", summary: "Search flights by keyword", params: []openAPIParam{
+		emailQueryParam, {"q", "query", true, "Term matched against airline, airports, passenger, flight number, confirmation code, and frequent flyer number"},
+	}, status: http.StatusOK, response: "[]BoardingPass"},
+	{method: "get", path: "/api/flights/upcoming", summary: "List flights departing today or later", params: []openAPIParam{emailQueryParam},
+		status: http.StatusOK, response: "[]BoardingPass"},
+	{method: "get", path: "/api/flights/next", summary: "The soonest upcoming flight (204 when there is none)", params: []openAPIParam{emailQueryParam},
+		status: http.StatusOK, response: "BoardingPass"},
+	{method: "get", path: "/api/flights/past", summary: "List flights that already departed", params: []openAPIParam{emailQueryParam},
+		status: http.StatusOK, response: "[]BoardingPass"},
+	{method: "get", path: "/api/flights/miles", summary: "Total distance flown", params: []openAPIParam{emailQueryParam},
+		status: http.StatusOK, response: "MilesResponse"},
+	{method: "get", path: "/api/flights/routes", summary: "Distinct routes flown with flight counts, most flown first", params: []openAPIParam{emailQueryParam},
+		status: http.StatusOK, response: "[]FlightRoute"},
+	{method: "get", path: "/api/flights/passengers", summary: "Distinct passenger names on a user's flights, alphabetically", params: []openAPIParam{emailQueryParam},
+		status: http.StatusOK, response: "[]string"},
+	{method: "get", path: "/api/stats", summary: "Flight count and seating habits (window/middle/aisle and most common seat)", params: []openAPIParam{emailQueryParam},
+		status: http.StatusOK, response: "FlightStats"},
+	{method: "get", path: "/api/stats/timeline", summary: "Count flights per departure month or year", params: []openAPIParam{
+		emailQueryParam, {"bucket", "query", false, "month (default) or year"},
+	}, status: http.StatusOK, response: "Timeline"},
+	{method: "post", path: "/api/flights/validate", summary: "Check a flight's airport codes", request: "BoardingPass",
+		status: http.StatusOK, response: "ValidateResponse"},
+	{method: "put", path: "/api/flights/{id}", summary: "Replace a flight", params: []openAPIParam{
+		idPathParam, {"If-Match", "header", false, "ETag from a previous read; the update fails with 412 if the flight changed since"},
+	}, request: "BoardingPass", status: http.StatusOK, response: "BoardingPass"},
+	{method: "patch", path: "/api/flights/{id}", summary: "Update some of a flight's fields", params: []openAPIParam{idPathParam, emailQueryParam},
+		request: "BoardingPass", status: http.StatusNoContent},
+	{method: "delete", path: "/api/flights/{id}", summary: "Move a flight to the trash", params: []openAPIParam{
+		idPathParam, emailQueryParam, {"purge", "query", false, "Set to true to delete permanently"},
+	}, status: http.StatusOK, response: "BoardingPass"},
+	{method: "post", path: "/api/flights/{id}/restore", summary: "Restore a flight from the trash", params: []openAPIParam{idPathParam, emailQueryParam},
+		status: http.StatusOK, response: "BoardingPass"},
+	{method: "post", path: "/api/flights/{id}/repeat", summary: "Pre-fill an unsaved return or repeat of a flight", params: []openAPIParam{
+		idPathParam, emailQueryParam,
+		{"type", "query", false, "repeat (default) copies the flight to date; return swaps the airports"},
+		{"date", "query", false, "Departure date (YYYY-MM-DD) for the new flight; required for repeat"},
+	}, status: http.StatusOK, response: "BoardingPass"},
+	{method: "get", path: "/api/flights/{id}/image", summary: "The flight's stored boarding pass image (404 without image storage)",
+		params: []openAPIParam{idPathParam, emailQueryParam}, status: http.StatusOK},
+	{method: "get", path: "/api/flights/trash", summary: "List trashed flights", params: []openAPIParam{emailQueryParam},
+		status: http.StatusOK, response: "[]BoardingPass"},
+	{method: "post", path: "/api/sample", summary: "Load sample flights", params: []openAPIParam{
+		emailQueryParam, {"count", "query", false, "Number of sample flights to create"},
+		{"replace", "query", false, "Set to true to delete and re-create sample flights that already exist instead of skipping them"},
+	}, status: http.StatusCreated, response: "SampleDataResponse"},
+	{method: "get", path: "/api/export", summary: "Export a user's flights as JSON for backup or moving environments", params: []openAPIParam{emailQueryParam},
+		status: http.StatusOK, response: "ExportDocument"},
+	{method: "post", path: "/api/import", summary: "Recreate flights from an export document", params: []openAPIParam{
+		emailQueryParam, {"preserveIds", "query", false, "Set to true to keep the exported flight IDs instead of generating new ones"},
+	}, request: "ExportDocument", status: http.StatusOK, response: "ImportResponse"},
+	{method: "post", path: "/api/extract", summary: "Extract flight details from a boarding pass image", params: []openAPIParam{emailHeaderParam},
+		multipart: true, status: http.StatusOK, stream: true},
+	{method: "post", path: "/api/extract/url", summary: "Extract flight details from a boarding pass image at an https URL", params: []openAPIParam{emailHeaderParam},
+		request: "ExtractURLRequest", status: http.StatusOK, stream: true},
+	{method: "post", path: "/api/extract/{uploadId}", summary: "Re-run extraction on a kept upload, e.g. with a different model", params: []openAPIParam{
+		{"uploadId", "path", true, "Upload ID from the upload event of POST /api/extract with keepUpload=true"}, emailHeaderParam,
+		{"model", "query", false, "Model ID (also accepted as a form field); defaults to the server default"},
+	}, status: http.StatusOK, stream: true},
+	{method: "post", path: "/api/extract/debug", summary: "Extract flight details and return the image sent to the model", params: []openAPIParam{emailHeaderParam},
+		multipart: true, status: http.StatusOK, response: "ExtractDebugResponse"},
+	{method: "post", path: "/api/extract/batch", summary: "Extract flight details from several boarding pass images, one result event per image", params: []openAPIParam{emailHeaderParam},
+		multipart: true, batch: true, status: http.StatusOK, stream: true},
+	{method: "get", path: "/api/extract/{uploadId}/watch", summary: "Follow the extraction running on a kept upload, e.g. from another screen (404 when none is running)", params: []openAPIParam{
+		{"uploadId", "path", true, "Upload ID from the upload event of POST /api/extract with keepUpload=true"}, emailQueryParam,
+	}, status: http.StatusOK, stream: true},
+	{method: "post", path: "/api/extract/{uploadId}/cancel", summary: "Cancel the extraction running on a kept upload; its stream gets a cancelled event (404 when none is running)", params: []openAPIParam{
+		{"uploadId", "path", true, "Upload ID from the upload event of POST /api/extract with keepUpload=true"}, emailHeaderParam,
+	}, status: http.StatusNoContent},
+	{method: "post", path: "/api/chat", summary: "Ask a question about your flights", params: []openAPIParam{emailHeaderParam},
+		request: "ChatRequest", status: http.StatusOK, stream: true},
+	{method: "get", path: "/api/chat/ws", summary: "WebSocket alternative to POST /api/chat: send ChatRequest frames, receive WSEvent frames named like the SSE events", params: []openAPIParam{
+		{"X-User-Email", "header", false, "Owner's email; browsers, which can't set WebSocket headers, use the email query parameter instead"},
+		{"email", "query", false, "Owner's email when the X-User-Email header isn't set"},
+	}, status: http.StatusSwitchingProtocols},
+	{method: "post", path: "/api/chat/suggestions", summary: "Example chat questions tailored to the user's flights", params: []openAPIParam{emailHeaderParam},
+		status: http.StatusOK, response: "[]string"},
+	{method: "post", path: "/api/query/explain", summary: "Preview the RU cost and index usage of a query without returning its results", params: []openAPIParam{emailHeaderParam},
+		request: "ExplainRequest", status: http.StatusOK, response: "QueryPlan"},
+	{method: "get", path: "/api/samples", summary: "URLs of the sample boarding pass images",
+		status: http.StatusOK, response: "[]string"},
+	{method: "get", path: "/api/usage", summary: "Estimated relative model spend (multiplier × calls) since the server started", params: []openAPIParam{emailQueryParam},
+		status: http.StatusOK, response: "UsageResponse"},
+	{method: "get", path: "/api/models", summary: "List available Copilot models",
+		status: http.StatusOK, response: "ModelsListResponse"},
+	{method: "get", path: "/api/admin/stats", summary: "Flight counts and top routes across all users (cross-partition query)", params: []openAPIParam{adminTokenParam},
+		status: http.StatusOK, response: "GlobalStats"},
+	{method: "post", path: "/api/admin/normalize", summary: "Rewrite a user's stored flights to the current normalization rules", params: []openAPIParam{adminTokenParam, emailQueryParam},
+		status: http.StatusOK, response: "NormalizeResponse"},
+	{method: "get", path: "/readyz", summary: "Whether the Copilot CLI answered its latest health check (503 with Retry-After when not)",
+		status: http.StatusOK, response: "ReadyResponse"},
+}
+
+// openAPISpec builds the OpenAPI document once, on first request
+var openAPISpec = sync.OnceValues(buildOpenAPISpec)
+
+// buildOpenAPISpec generates the OpenAPI 3.1 document (whose schemas are JSON Schema 2020-12) as JSON
+func buildOpenAPISpec() ([]byte, error) {
+	// Nested components are emitted as $refs rather than inlined
+	refs := make(map[reflect.Type]*jsonschema.Schema, len(openAPIComponents))
+	for _, c := range openAPIComponents {
+		refs[c.typ] = &jsonschema.Schema{Ref: "#/components/schemas/" + c.name}
+	}
+
+	schemas := make(map[string]*jsonschema.Schema, len(openAPIComponents))
+	for _, c := range openAPIComponents {
+		typeSchemas := make(map[reflect.Type]*jsonschema.Schema, len(refs))
+		for t, ref := range refs {
+			if t != c.typ {
+				typeSchemas[t] = ref
+			}
+		}
+		schema, err := jsonschema.ForType(c.typ, &jsonschema.ForOptions{TypeSchemas: typeSchemas})
+		if err != nil {
+			return nil, fmt.Errorf("schema for %s: %w", c.name, err)
+		}
+		// The same structs are used for requests, where the server fills in missing fields (id,
+		// createdAt, model) and ignores unknown ones, so don't mark fields required or closed
+		schema.Required = nil
+		schema.AdditionalProperties = nil
+		schemas[c.name] = schema
+	}
+
+	paths := map[string]map[string]any{}
+	for _, op := range openAPIOperations {
+		if paths[op.path] == nil {
+			paths[op.path] = map[string]any{}
+		}
+		paths[op.path][op.method] = op.spec()
+	}
+
+	return json.MarshalIndent(map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":   "Flight Log API",
+			"version": "1.0.0",
+		},
+		"paths":      paths,
+		"components": map[string]any{"schemas": schemas},
+	}, "", "  ")
+}
+
+// spec returns the OpenAPI operation object
+func (op openAPIOperation) spec() map[string]any {
+	out := map[string]any{"summary": op.summary}
+
+	if len(op.params) > 0 {
+		params := make([]map[string]any, 0, len(op.params))
+		for _, p := range op.params {
+			params = append(params, map[string]any{
+				"name":        p.name,
+				"in":          p.in,
+				"required":    p.required,
+				"description": p.desc,
+				"schema":      map[string]string{"type": "string"},
+			})
+		}
+		out["parameters"] = params
+	}
+
+	switch {
+	case op.multipart:
+		properties := map[string]any{
+			"image":      map[string]string{"type": "string", "format": "binary"},
+			"model":      map[string]string{"type": "string", "description": "Model ID; defaults to the server default"},
+			"keepUpload": map[string]string{"type": "string", "description": "Set to true to keep the image for re-extraction"},
+		}
+		if op.batch {
+			properties["image"] = map[string]any{"type": "array", "items": map[string]string{"type": "string", "format": "binary"}}
+			delete(properties, "keepUpload")
+		}
+		out["requestBody"] = map[string]any{
+			"required": true,
+			"content": map[string]any{"multipart/form-data": map[string]any{"schema": map[string]any{
+				"type":       "object",
+				"required":   []string{"image"},
+				"properties": properties,
+			}}},
+		}
+	case op.request != "":
+		out["requestBody"] = map[string]any{
+			"required": true,
+			"content":  map[string]any{"application/json": map[string]any{"schema": schemaRef(op.request)}},
+		}
+	}
+
+	response := map[string]any{"description": http.StatusText(op.status)}
+	switch {
+	case op.stream:
+		response["description"] = "Server-sent events; failures are reported as an error event (see ErrorEvent)"
+		response["content"] = map[string]any{"text/event-stream": map[string]any{"schema": map[string]string{"type": "string"}}}
+	case op.response != "":
+		response["content"] = map[string]any{"application/json": map[string]any{"schema": schemaRef(op.response)}}
+	}
+	out["responses"] = map[string]any{fmt.Sprint(op.status): response}
+	return out
+}
+
+// schemaRef returns a $ref to a component, wrapped in an array schema for "[]Name".
+// "string" is a plain string rather than a component.
+func schemaRef(name string) map[string]any {
+	if elem, ok := strings.CutPrefix(name, "[]"); ok {
+		return map[string]any{"type": "array", "items": schemaRef(elem)}
+	}
+	if name == "string" {
+		return map[string]any{"type": "string"}
+	}
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+// handleOpenAPI serves the OpenAPI document describing the REST API
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	spec, err := openAPISpec()
+	if err != nil {
+		log.Printf("Failed to build OpenAPI spec: %v", err)
+		http.Error(w, "Failed to build OpenAPI spec", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(spec)
+}
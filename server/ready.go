@@ -0,0 +1,138 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	sdk "github.com/github/copilot-sdk/go"
+)
+
+const (
+	// copilotCheckInterval is how often the Copilot CLI connection is pinged
+	copilotCheckInterval = 15 * time.Second
+	// copilotPingTimeout bounds one ping, so a hung CLI counts as down
+	copilotPingTimeout = 5 * time.Second
+)
+
+// copilotStatus is the outcome of the latest Copilot health check. It starts out ready, so
+// requests are only refused once a check has actually failed.
+type copilotStatus struct {
+	mu        sync.RWMutex
+	ready     bool
+	lastError string
+	checkedAt time.Time
+}
+
+// ReadyResponse is the body of GET /readyz
+type ReadyResponse struct {
+	Status  string        `json:"status"` // "ready" or "unavailable"
+	Copilot CopilotReadyz `json:"copilot"`
+}
+
+// CopilotReadyz reports the Copilot CLI connection as of the latest health check
+type CopilotReadyz struct {
+	Ready     bool   `json:"ready"`
+	Error     string `json:"error,omitempty"`
+	CheckedAt string `json:"checkedAt,omitempty"` // RFC3339; empty before the first check
+}
+
+// set records the result of a health check, logging when readiness changes
+func (c *copilotStatus) set(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ready := err == nil
+	if ready != c.ready {
+		if ready {
+			log.Println("[COPILOT] Copilot CLI is reachable again")
+		} else {
+			log.Printf("[COPILOT] Copilot CLI is unavailable; extraction and chat return 503: %v", err)
+		}
+	}
+	c.ready = ready
+	c.lastError = ""
+	if err != nil {
+		c.lastError = err.Error()
+	}
+	c.checkedAt = time.Now()
+}
+
+// snapshot returns the latest readiness for /readyz
+func (c *copilotStatus) snapshot() CopilotReadyz {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	r := CopilotReadyz{Ready: c.ready, Error: c.lastError}
+	if !c.checkedAt.IsZero() {
+		r.CheckedAt = c.checkedAt.UTC().Format(time.RFC3339)
+	}
+	return r
+}
+
+// pingCopilot checks that the Copilot client is connected and the CLI answers a ping in time
+func (s *Server) pingCopilot() error {
+	if state := s.copilotClient.GetState(); state != sdk.StateConnected {
+		return fmt.Errorf("client is %s", state)
+	}
+	ch := make(chan error, 1)
+	go func() {
+		_, err := s.copilotClient.Ping("")
+		ch <- err
+	}()
+
+	timer := time.NewTimer(copilotPingTimeout)
+	defer timer.Stop()
+	select {
+	case err := <-ch:
+		return err
+	case <-timer.C:
+		return fmt.Errorf("no ping response within %v", copilotPingTimeout)
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+}
+
+// watchCopilot pings the Copilot CLI every copilotCheckInterval until the server's context is done
+func (s *Server) watchCopilot() {
+	ticker := time.NewTicker(copilotCheckInterval)
+	defer ticker.Stop()
+	for {
+		s.copilot.set(s.pingCopilot())
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// requireCopilot writes 503 with a Retry-After of one check interval and returns false when the
+// latest health check found the Copilot CLI down, so extraction and chat fail fast instead of
+// erroring deep inside the SDK
+func (s *Server) requireCopilot(w http.ResponseWriter) bool {
+	if s.copilot.snapshot().Ready {
+		return true
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(copilotCheckInterval.Seconds())))
+	http.Error(w, "The AI service is temporarily unavailable; try again shortly", http.StatusServiceUnavailable)
+	return false
+}
+
+// handleReadyz reports whether the server can serve AI requests: 200 when the Copilot CLI
+// answered the latest health check, 503 otherwise. Flight CRUD keeps working either way.
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	resp := ReadyResponse{Status: "ready", Copilot: s.copilot.snapshot()}
+	status := http.StatusOK
+	if !resp.Copilot.Ready {
+		resp.Status = "unavailable"
+		status = http.StatusServiceUnavailable
+		w.Header().Set("Retry-After", strconv.Itoa(int(copilotCheckInterval.Seconds())))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"time"
+
+	"github.com/abhirockzz/flight-log-app/ai"
+	"github.com/abhirockzz/flight-log-app/cosmosdb"
+	"github.com/abhirockzz/flight-log-app/schedule"
+)
+
+// scheduleLookupTimeout bounds the schedule check so a slow provider can't hold up the extraction result
+const scheduleLookupTimeout = 5 * time.Second
+
+// ScheduleProvider looks up flights in published airline schedules. schedule.AviationStack
+// implements it; the default provider knows no flights, so extractions aren't checked.
+type ScheduleProvider interface {
+	// Lookup returns the flight flightNumber departing on date (YYYY-MM-DD), or false if the
+	// schedule doesn't list it
+	Lookup(ctx context.Context, flightNumber, date string) (*schedule.ScheduledFlight, bool, error)
+}
+
+// noSchedule is the default ScheduleProvider, which never finds a flight
+type noSchedule struct{}
+
+func (noSchedule) Lookup(context.Context, string, string) (*schedule.ScheduledFlight, bool, error) {
+	return nil, false, nil
+}
+
+// WithScheduleProvider checks each extracted flight against provider's schedule
+func WithScheduleProvider(provider ScheduleProvider) Option {
+	return func(s *Server) {
+		s.schedules = provider
+	}
+}
+
+// checkSchedule sends a warning event for each route or departure time field of an extracted
+// flight that disagrees with the airline schedule, which often means the boarding pass was misread.
+// The check is best effort: lookup failures and unlisted flights are only logged.
+func (s *Server) checkSchedule(ctx context.Context, flight *cosmosdb.BoardingPass, send ai.ProgressCallback) {
+	if flight.FlightNumber == "" || flight.DepartureDate == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, scheduleLookupTimeout)
+	defer cancel()
+
+	scheduled, found, err := s.schedules.Lookup(ctx, flight.FlightNumber, flight.DepartureDate)
+	if err != nil {
+		log.Printf("[SCHEDULE] Lookup of %s on %s failed: %v", flight.FlightNumber, flight.DepartureDate, err)
+		return
+	}
+	if !found {
+		slog.Debug("[SCHEDULE] Flight not in schedule", "flight", flight.FlightNumber, "date", flight.DepartureDate)
+		return
+	}
+
+	for _, m := range schedule.Compare(flight, scheduled) {
+		log.Printf("[SCHEDULE] %s: %s", flight.FlightNumber, m.Message)
+		data, _ := json.Marshal(m)
+		send("warning", string(data))
+	}
+}
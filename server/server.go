@@ -1,22 +1,35 @@
 package server
 
 import (
+	"context"
 	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
+	"log/slog"
+	"math"
 	"math/rand"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 	"unicode"
 
 	"github.com/abhirockzz/flight-log-app/ai"
+	"github.com/abhirockzz/flight-log-app/airports"
 	"github.com/abhirockzz/flight-log-app/cosmosdb"
+	"github.com/abhirockzz/flight-log-app/metrics"
+	"github.com/abhirockzz/flight-log-app/redact"
+	"github.com/abhirockzz/flight-log-app/static"
+	"github.com/abhirockzz/flight-log-app/tracing"
 	sdk "github.com/github/copilot-sdk/go"
 	"github.com/google/uuid"
 )
@@ -38,46 +51,187 @@ type SampleFlightTemplate struct {
 
 // Server handles HTTP requests for the Flight Log app
 type Server struct {
-	cosmos        *cosmosdb.Client
-	extractor     *ai.BoardingPassExtractor
-	chatHandler   *ai.ChatHandler
-	copilotClient *sdk.Client
-	mux           *http.ServeMux
-	models        []ModelResponse // Cached models from Copilot SDK
-	defaultModel  string          // Default model ID (first free+vision model)
+	store          cosmosdb.FlightStore
+	extractor      *ai.BoardingPassExtractor
+	chatHandler    *ai.ChatHandler
+	copilotClient  *sdk.Client
+	mux            *http.ServeMux
+	handler        http.Handler     // mux wrapped with middleware
+	uploads        *uploadStore     // Uploaded images kept for re-extraction
+	fetcher        *imageFetcher    // Downloads images for POST /api/extract/url
+	usage          *usageTracker    // Estimated model spend per user, in memory only
+	health         *healthTracker   // Recent model failures, used to pass over unreliable models
+	auth           Authenticator    // Verifies callers; nil trusts X-User-Email (AUTH_MODE=header)
+	images         ImageStore       // Keeps boarding pass images of saved flights; nil discards them
+	hub            *extractionHub   // Relays extractions on kept uploads to watchers
+	copilot        *copilotStatus   // Latest Copilot CLI health check; extraction and chat return 503 while it is down
+	schedules      ScheduleProvider // Airline schedules extracted flights are checked against
+	assets         http.Handler     // Serves the embedded web UI and sample images
+	cfg            Config           // Settings; see DefaultConfig and WithConfig
+	modelsMu       sync.RWMutex     // Guards models, defaultModel, and modelsLoadedAt
+	models         []ModelResponse  // Cached models from Copilot SDK
+	defaultModel   string           // Default model ID (first free+vision model)
+	modelsLoadedAt time.Time        // When models were last fetched successfully
+	ctx            context.Context  // Root context for background work; cancelled by Close or the caller
+	cancel         context.CancelFunc
 }
 
-// New creates a new Server instance
-func New(cosmosClient *cosmosdb.Client, copilotClient *sdk.Client) *Server {
+// New creates a new Server instance.
+// Background work (model refresh, upload cleanup) stops when ctx is cancelled or Close is called.
+func New(ctx context.Context, store cosmosdb.FlightStore, copilotClient *sdk.Client, opts ...Option) *Server {
+	ctx, cancel := context.WithCancel(ctx)
 	s := &Server{
-		cosmos:        cosmosClient,
-		extractor:     ai.NewBoardingPassExtractor(copilotClient),
-		chatHandler:   ai.NewChatHandler(copilotClient, cosmosClient),
+		store:         store,
 		copilotClient: copilotClient,
 		mux:           http.NewServeMux(),
+		uploads:       newUploadStore(ctx, uploadTTL),
+		usage:         newUsageTracker(),
+		hub:           newExtractionHub(),
+		copilot:       &copilotStatus{ready: true},
+		schedules:     noSchedule{},
+		assets:        http.FileServerFS(static.FS),
+		cfg:           DefaultConfig(),
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.chatHandler = ai.NewChatHandler(copilotClient, store, s.chatOptions()...)
+	s.fetcher = newImageFetcher(s.cfg.UploadDir, s.cfg.ImageURLAllowedHosts)
+	s.health = newHealthTracker(s.cfg.ModelFailureThreshold, s.cfg.ModelFailureWindow, s.cfg.ModelCooldown)
+	// The extractor checks requested models against the cached list, so it skips non-vision models,
+	// and reports every attempt so failing models can be demoted
+	s.extractor = ai.NewBoardingPassExtractor(copilotClient, append(s.extractorOptions(),
+		ai.WithModelCatalog(s.modelVision),
+		ai.WithAttemptObserver(s.recordExtractionAttempt))...)
+	// Don't block startup on a slow or failing Copilot backend; refreshModels retries in the background
+	loadErr := s.loadModels()
+	go s.refreshModels(s.cfg.ModelsRefresh, loadErr != nil)
+	go s.watchCopilot()
+	if s.cfg.TrashRetention > 0 {
+		go s.sweepTrash()
 	}
-	s.loadModels()
 	s.routes()
+	middlewares := []Middleware{corsMiddleware(s.cfg.AllowedOrigins)}
+	if tracing.Enabled() {
+		// Outermost, so requests rejected by CORS or auth are traced too
+		middlewares = append([]Middleware{traceMiddleware(s.mux)}, middlewares...)
+	}
+	if s.auth != nil {
+		middlewares = append(middlewares, authMiddleware(s.auth))
+	}
+	// After auth, so the email taken from a token is checked too
+	middlewares = append(middlewares, emailMiddleware(), decompressMiddleware())
+	if !s.cfg.DisableCompression {
+		middlewares = append(middlewares, compressMiddleware())
+	}
+	s.handler = chain(s.mux, middlewares...)
 	return s
 }
 
+// extractorOptions returns extractor options from the config.
+// A custom extraction prompt suits other documents, e.g. train or event tickets.
+func (s *Server) extractorOptions() []ai.ExtractorOption {
+	var opts []ai.ExtractorOption
+	if s.cfg.ExtractionPrompt != "" {
+		opts = append(opts, ai.WithSystemPrompt(s.cfg.ExtractionPrompt))
+	}
+	return opts
+}
+
+// recordExtractionAttempt records the outcome of one extraction attempt in metrics and model health
+func (s *Server) recordExtractionAttempt(model string, err error) {
+	metrics.RecordExtraction(model, err)
+	s.health.record(model, err)
+}
+
+// recordChat records the outcome of a chat in metrics and model health
+func (s *Server) recordChat(model string, err error) {
+	metrics.RecordChat(model, err)
+	s.health.record(model, err)
+}
+
+// chatOptions builds chat handler options from the config: the query result cache, the timezone
+// for dates in answers, the SQL allow-list, and the number of queries per question
+func (s *Server) chatOptions() []ai.ChatOption {
+	opts := []ai.ChatOption{
+		ai.WithQueryCache(s.cfg.ChatCacheSize, s.cfg.ChatCacheTTL),
+		ai.WithLocation(s.cfg.Location),
+		ai.WithAllowedSQL(s.cfg.ChatAllowedSQL),
+	}
+	if s.cfg.ChatMaxQueries > 0 {
+		opts = append(opts, ai.WithMaxQueries(s.cfg.ChatMaxQueries))
+	}
+	return opts
+}
+
+// Close stops the server's background goroutines. It does not close active connections.
+func (s *Server) Close() {
+	s.cancel()
+}
+
 // ServeHTTP implements the http.Handler interface
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	s.mux.ServeHTTP(w, r)
+	s.handler.ServeHTTP(w, r)
 }
 
 // routes sets up all HTTP routes
 func (s *Server) routes() {
 	// API routes
 	s.mux.HandleFunc("POST /api/extract", s.handleExtract)
+	s.mux.HandleFunc("POST /api/extract/url", s.handleExtractURL)
+	s.mux.HandleFunc("POST /api/extract/debug", s.handleExtractDebug)
+	s.mux.HandleFunc("POST /api/extract/batch", s.handleExtractBatch)
+	s.mux.HandleFunc("POST /api/extract/{uploadId}", s.handleReExtract)
+	s.mux.HandleFunc("GET /api/extract/{uploadId}/watch", s.handleWatchExtraction)
+	s.mux.HandleFunc("POST /api/extract/{uploadId}/cancel", s.handleCancelExtraction)
 	s.mux.HandleFunc("POST /api/flights", s.handleCreateFlight)
+	s.mux.HandleFunc("POST /api/flights/validate", s.handleValidateFlight)
+	s.mux.HandleFunc("POST /api/flights/bulk", s.handleBulkCreateFlights)
 	s.mux.HandleFunc("GET /api/flights", s.handleListFlights)
 	s.mux.HandleFunc("GET /api/flights/all", s.handleListAllFlights)
+	s.mux.HandleFunc("GET /api/flights/search", s.handleSearchFlights)
+	s.mux.HandleFunc("GET /api/flights/upcoming", s.handleListUpcomingFlights)
+	s.mux.HandleFunc("GET /api/flights/next", s.handleNextFlight)
+	s.mux.HandleFunc("GET /api/flights/miles", s.handleFlightMiles)
+	s.mux.HandleFunc("GET /api/flights/routes", s.handleListRoutes)
+	s.mux.HandleFunc("GET /api/flights/passengers", s.handleListPassengers)
+	s.mux.HandleFunc("GET /api/flights/past", s.handleListPastFlights)
+	s.mux.HandleFunc("GET /api/stats", s.handleStats)
+	s.mux.HandleFunc("GET /api/stats/timeline", s.handleStatsTimeline)
+	s.mux.HandleFunc("PUT /api/flights/{id}", s.handleUpdateFlight)
+	s.mux.HandleFunc("PATCH /api/flights/{id}", s.handlePatchFlight)
 	s.mux.HandleFunc("DELETE /api/flights/{id}", s.handleDeleteFlight)
+	s.mux.HandleFunc("POST /api/flights/{id}/restore", s.handleRestoreFlight)
+	s.mux.HandleFunc("POST /api/flights/{id}/repeat", s.handleRepeatFlight)
+	s.mux.HandleFunc("GET /api/flights/{id}/image", s.handleGetFlightImage)
+	s.mux.HandleFunc("GET /api/flights/trash", s.handleListDeletedFlights)
 	s.mux.HandleFunc("POST /api/sample", s.handleLoadSampleData)
+	s.mux.HandleFunc("GET /api/export", s.handleExport)
+	s.mux.HandleFunc("POST /api/import", s.handleImport)
 	s.mux.HandleFunc("POST /api/chat", s.handleChat)
+	s.mux.HandleFunc("POST /api/chat/suggestions", s.handleChatSuggestions)
+	s.mux.HandleFunc("GET /api/chat/ws", s.handleChatWS)
+	s.mux.HandleFunc("POST /api/query/explain", s.handleExplainQuery)
 	s.mux.HandleFunc("GET /api/samples", s.handleListSamples)
 	s.mux.HandleFunc("GET /api/models", s.handleModels)
+	s.mux.HandleFunc("GET /api/usage", s.handleUsage)
+
+	// Readiness of the Copilot dependency, for load balancers and orchestrators
+	s.mux.HandleFunc("GET /readyz", s.handleReadyz)
+
+	// OpenAPI document generated from the request/response structs
+	s.mux.HandleFunc("GET /openapi.json", s.handleOpenAPI)
+
+	// Admin routes (cross-partition, require ADMIN_TOKEN)
+	s.mux.HandleFunc("GET /api/admin/stats", s.handleAdminStats)
+	s.mux.HandleFunc("POST /api/admin/normalize", s.handleAdminNormalize)
+
+	// Prometheus metrics (opt-in via METRICS_ENABLED=true)
+	if s.cfg.MetricsEnabled {
+		s.mux.Handle("GET /metrics", metrics.Handler())
+	}
 
 	// Sample images
 	s.mux.HandleFunc("GET /samples/", s.handleSampleImage)
@@ -87,192 +241,976 @@ func (s *Server) routes() {
 	s.mux.HandleFunc("GET /static/", s.handleStatic)
 }
 
-// handleStatic serves static files
+// handleStatic serves the embedded web UI. Files are reachable at the root ("/app.js") and under
+// "/static/"; "/" serves index.html. FileServerFS handles Range requests, Content-Length, and
+// content types, and rejects paths that escape the embedded tree.
 func (s *Server) handleStatic(w http.ResponseWriter, r *http.Request) {
-	path := r.URL.Path
-	if path == "/" {
-		path = "/index.html"
+	name := strings.TrimPrefix(r.URL.Path, "/static")
+	if !strings.HasPrefix(name, "/") {
+		// e.g. "/staticfile", which only matched the "/" pattern
+		name = r.URL.Path
+	}
+	// Don't list directories such as /static/samples/
+	if name != "/" && strings.HasSuffix(name, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	file := strings.TrimPrefix(name, "/")
+	if file == "" {
+		file = "index.html"
+	}
+	// Without modification times on embedded files, the ETag lets browsers revalidate cheaply
+	if etag, err := staticETags.get(file); err == nil {
+		w.Header().Set("ETag", etag)
+	}
+
+	r = r.Clone(r.Context())
+	r.URL.Path = name
+	r.URL.RawPath = ""
+	s.assets.ServeHTTP(w, r)
+}
+
+// handleExtract handles boarding pass image upload and extraction via SSE
+func (s *Server) handleExtract(w http.ResponseWriter, r *http.Request) {
+	// Get email from header
+	email := r.Header.Get("X-User-Email")
+	if email == "" {
+		http.Error(w, "X-User-Email header is required", http.StatusBadRequest)
+		return
+	}
+
+	if !s.requireCopilot(w) {
+		return
+	}
+
+	tempFile, model, ok := s.receiveImage(w, r)
+	if !ok {
+		return
+	}
+	slog.Debug("[EXTRACT] Request", "user", redact.Email(email), "model", model)
+
+	// Keep the image for re-extraction if requested (keepUpload=true form value),
+	// otherwise remove it once this extraction finishes
+	var uploadID string
+	if r.FormValue("keepUpload") == "true" {
+		uploadID = s.uploads.Put(tempFile, email)
+	} else {
+		defer os.Remove(tempFile)
+	}
+
+	s.streamExtraction(w, r, tempFile, email, model, uploadID)
+}
+
+// receiveImage saves the multipart "image" field to a temp file in the upload dir and returns its path
+// with the requested model (the "model" field, defaulting to the server default). On failure it writes
+// the error response and returns false; otherwise the caller must remove the file.
+func (s *Server) receiveImage(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	// Parse multipart form (max 10MB)
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		http.Error(w, "Failed to parse form: "+err.Error(), http.StatusBadRequest)
+		return "", "", false
+	}
+
+	// Get model from form (optional, defaults to server default)
+	model := r.FormValue("model")
+	if model == "" {
+		model = s.getDefaultModel()
+	}
+	if !s.checkVisionModel(w, model) {
+		return "", "", false
+	}
+
+	// Get uploaded file
+	file, header, err := r.FormFile("image")
+	if err != nil {
+		http.Error(w, "Failed to get image: "+err.Error(), http.StatusBadRequest)
+		return "", "", false
+	}
+	defer file.Close()
+
+	tempFile, err := s.saveUpload(file, header.Filename)
+	if err != nil {
+		http.Error(w, "Failed to save image: "+err.Error(), http.StatusInternalServerError)
+		return "", "", false
+	}
+	return tempFile, model, true
+}
+
+// saveUpload copies an uploaded image to a new temp file in the upload dir, keeping the
+// original file extension, and returns its path. The caller must remove the file.
+func (s *Server) saveUpload(src io.Reader, filename string) (string, error) {
+	tempFile := filepath.Join(s.cfg.UploadDir, uploadFilePrefix+uuid.New().String()+filepath.Ext(filename))
+	out, err := os.Create(tempFile)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := io.Copy(out, src); err != nil {
+		out.Close()
+		os.Remove(tempFile)
+		return "", err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tempFile)
+		return "", err
+	}
+	return tempFile, nil
+}
+
+// handleReExtract re-runs extraction on a previously kept upload, typically with a different model
+func (s *Server) handleReExtract(w http.ResponseWriter, r *http.Request) {
+	// Get email from header
+	email := r.Header.Get("X-User-Email")
+	if email == "" {
+		http.Error(w, "X-User-Email header is required", http.StatusBadRequest)
+		return
+	}
+
+	if !s.requireCopilot(w) {
+		return
+	}
+
+	uploadID := r.PathValue("uploadId")
+	imagePath, ok := s.uploads.Get(uploadID, email)
+	if !ok {
+		http.Error(w, "Upload not found or expired", http.StatusNotFound)
+		return
+	}
+
+	// Get model from form (optional, defaults to server default)
+	model := r.FormValue("model")
+	if model == "" {
+		model = s.getDefaultModel()
+	}
+	if !s.checkVisionModel(w, model) {
+		return
+	}
+
+	s.streamExtraction(w, r, imagePath, email, model, uploadID)
+}
+
+// streamExtraction runs extraction on an image and streams progress to the client via SSE.
+// If uploadID is set, it is sent to the client so extraction can be re-run later, the events are
+// also relayed to watchers of the upload (see handleWatchExtraction), and the extraction can be
+// cancelled (see handleCancelExtraction).
+func (s *Server) streamExtraction(w http.ResponseWriter, r *http.Request, imagePath, email, model, uploadID string) {
+	// Set up the SSE stream; writes are serialized since SDK callbacks run on other goroutines
+	sse, ok := newSSEWriter(w)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	defer sse.Close()
+
+	ctx, cancel := context.WithCancelCause(r.Context())
+	defer cancel(nil)
+	send := sse.Send
+	if uploadID != "" {
+		publish, finish := s.hub.start(uploadID, email, func() { cancel(errExtractionCancelled) })
+		defer finish()
+		send = func(event, data string) {
+			sse.Send(event, data)
+			publish(event, data)
+		}
+	}
+
+	// Keep the connection alive while the model is working
+	sse.StartHeartbeat(r.Context())
+
+	// Send initial step (Step 1: Image uploaded)
+	send("step", `{"step":1,"status":"completed","elapsedMs":0}`)
+	if uploadID != "" {
+		send("upload", fmt.Sprintf(`{"uploadId":%q}`, uploadID))
+	}
+
+	// Rotate phone photos upright per their EXIF orientation; fall back to the original on failure
+	normalized, err := ai.NormalizeImage(imagePath)
+	if err != nil {
+		log.Printf("[EXTRACT] Image normalization failed, using original: %v", err)
+	}
+	if normalized != imagePath {
+		defer os.Remove(normalized)
+		imagePath = normalized
+	}
+
+	// Extract flight data using Copilot, streaming progress via the callback
+	s.recordUsage(email, model)
+	flight, err := s.extractor.Extract(ctx, imagePath, email, s.extractionModels(model), send)
+	if err != nil {
+		if errors.Is(context.Cause(ctx), errExtractionCancelled) {
+			send("cancelled", fmt.Sprintf(`{"uploadId":%q}`, uploadID))
+			return
+		}
+		send("error", newErrorEvent(err).String())
+		return
+	}
+
+	// Flag fields that disagree with the airline's schedule before the user confirms the flight
+	s.checkSchedule(ctx, flight, send)
+
+	// Send extracted data
+	flightJSON, _ := json.Marshal(flight)
+	send("extracted", string(flightJSON))
+	send("done", "")
+}
+
+// errExtractionCancelled is the cause of an extraction's context being cancelled through
+// POST /api/extract/{uploadId}/cancel, telling it apart from the client disconnecting
+var errExtractionCancelled = errors.New("extraction cancelled")
+
+// maxJSONBodyBytes caps JSON request bodies
+const maxJSONBodyBytes = 1 << 20 // 1MB
+
+// decodeJSONBody strictly decodes a size-limited JSON request body into v.
+// Unknown fields are rejected so typos aren't silently dropped. On failure it writes
+// a 400 (or 413 for oversized bodies) with the decode error and returns false.
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	return decodeJSONBodyLimit(w, r, v, maxJSONBodyBytes)
+}
+
+// decodeJSONBodyLimit is decodeJSONBody with a caller-chosen size limit, for endpoints that take larger bodies
+func decodeJSONBodyLimit(w http.ResponseWriter, r *http.Request, v any, limit int64) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, fmt.Sprintf("Request body too large (max %d bytes)", maxBytesErr.Limit), http.StatusRequestEntityTooLarge)
+			return false
+		}
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return false
+	}
+
+	// Reject trailing data after the JSON value; dec.More misses a stray closing } or ]
+	if err := dec.Decode(&struct{}{}); err != io.EOF {
+		http.Error(w, "Invalid request body: unexpected data after JSON object", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// ErrorEvent is the payload of an error event for a failed chat or extraction.
+// Kind lets the UI tailor its advice, e.g. retry with another model vs rephrase the question.
+type ErrorEvent struct {
+	Error string `json:"error"`
+	Kind  string `json:"kind"`
+	Hint  string `json:"hint,omitempty"`
+}
+
+// newErrorEvent classifies an error returned by the ai package
+func newErrorEvent(err error) ErrorEvent {
+	event := ErrorEvent{Error: err.Error(), Kind: "internal"}
+	switch {
+	case errors.Is(err, ai.ErrQueryExecution):
+		event.Kind = "query_failed"
+		event.Hint = "Try rephrasing your question."
+	case errors.Is(err, ai.ErrModelUnavailable):
+		event.Kind = "model_unavailable"
+		event.Hint = "Try again or pick a different model."
+	case errors.Is(err, ai.ErrExtractionTimeout), errors.Is(err, ai.ErrChatTimeout):
+		event.Kind = "timeout"
+		event.Hint = "Try again or pick a faster model."
+	case errors.Is(err, ai.ErrNoVisionModel):
+		event.Kind = "model_unsupported"
+		event.Hint = "Pick a model with vision support."
+	case errors.Is(err, context.Canceled):
+		event.Kind = "cancelled"
+	case errors.Is(err, cosmosdb.ErrNotFound):
+		event.Kind = "not_found"
+	case errors.Is(err, cosmosdb.ErrPreconditionFailed):
+		event.Kind = "conflict"
+		event.Hint = "Ask for the change again."
+	}
+	return event
+}
+
+// String encodes the event as JSON for an SSE data line
+func (e ErrorEvent) String() string {
+	data, _ := json.Marshal(e)
+	return string(data)
+}
+
+// clearServerFields drops fields of a flight from a request body that only the server sets:
+// the trash state, which only DeleteFlight changes, and the TTL used for sample data
+func clearServerFields(flight *cosmosdb.BoardingPass) {
+	flight.Deleted = false
+	flight.DeletedAt = ""
+	flight.TTL = 0
+}
+
+// handleCreateFlight saves a confirmed flight to Cosmos DB. With ?uploadId= (from an extraction with
+// keepUpload=true) and an image store configured, the boarding pass image is stored with the flight.
+func (s *Server) handleCreateFlight(w http.ResponseWriter, r *http.Request) {
+	var flight cosmosdb.BoardingPass
+	if !decodeJSONBody(w, r, &flight) {
+		return
+	}
+	if !claimOwner(w, r, &flight.Email) {
+		return
+	}
+	clearServerFields(&flight)
+
+	// Validate required fields
+	if flight.Email == "" {
+		http.Error(w, "Email is required", http.StatusBadRequest)
+		return
+	}
+	if err := validateEmail(flight.Email); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := flight.ValidateAnnotations(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	attached := s.attachImage(r.Context(), &flight, r.URL.Query().Get("uploadId"))
+
+	// Save to Cosmos DB (?force=true skips duplicate detection)
+	var saved *cosmosdb.BoardingPass
+	var err error
+	if r.URL.Query().Get("force") == "true" {
+		saved, err = s.store.InsertFlight(r.Context(), &flight)
+	} else {
+		saved, err = s.store.SaveFlight(r.Context(), &flight)
+	}
+	if err != nil && attached {
+		// The client may retry (e.g. with ?force=true), which stores the image again
+		s.deleteImage(r.Context(), &flight)
+	}
+	if errors.Is(err, cosmosdb.ErrDuplicateFlight) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":      err.Error(),
+			"existingId": saved.ID,
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to save flight: %v", err)
+		http.Error(w, "Failed to save flight: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.chatHandler.InvalidateCache(saved.Email)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(saved)
+}
+
+// ValidateResponse lists a flight's airport codes that are not in the known airport dataset
+type ValidateResponse struct {
+	Valid        bool                   `json:"valid"`
+	Unrecognized []airports.UnknownCode `json:"unrecognized"`
+}
+
+// handleValidateFlight checks a flight's airport codes against the embedded IATA dataset without saving it
+func (s *Server) handleValidateFlight(w http.ResponseWriter, r *http.Request) {
+	var flight cosmosdb.BoardingPass
+	if !decodeJSONBody(w, r, &flight) {
+		return
+	}
+
+	unknown := airports.ValidateRoute(flight.FromAirport, flight.ToAirport)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ValidateResponse{
+		Valid:        len(unknown) == 0,
+		Unrecognized: unknown,
+	})
+}
+
+// MilesResponse summarizes the distance covered by a user's flights
+type MilesResponse struct {
+	TotalMiles           int     `json:"totalMiles"`
+	EstimatedFlightHours float64 `json:"estimatedFlightHours"`
+	FlightsCounted       int     `json:"flightsCounted"`
+	FlightsSkipped       int     `json:"flightsSkipped"` // Flights with an unknown airport code
+}
+
+// handleFlightMiles returns the total great-circle distance across a user's flights.
+// Flights whose airports aren't in the embedded dataset are skipped.
+func (s *Server) handleFlightMiles(w http.ResponseWriter, r *http.Request) {
+	email := r.URL.Query().Get("email")
+	if email == "" {
+		http.Error(w, "email query parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	flights, err := s.store.ListFlights(r.Context(), email)
+	if err != nil {
+		log.Printf("Failed to list flights: %v", err)
+		http.Error(w, "Failed to list flights: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var resp MilesResponse
+	var totalMiles float64
+	var totalTime time.Duration
+	for _, flight := range flights {
+		miles, err := airports.Distance(flight.FromAirport, flight.ToAirport)
+		if err != nil {
+			resp.FlightsSkipped++
+			continue
+		}
+		totalMiles += miles
+		totalTime += airports.EstimatedFlightTime(miles)
+		resp.FlightsCounted++
+	}
+	resp.TotalMiles = int(math.Round(totalMiles))
+	resp.EstimatedFlightHours = math.Round(totalTime.Hours()*10) / 10
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleRepeatFlight returns (without saving) a pre-filled copy of a flight for the client to edit and save:
+// ?type=return swaps the airports for the return leg, and ?type=repeat (the default) copies the flight
+// to ?date=YYYY-MM-DD. A date given with type=return is used as the return's departure date.
+func (s *Server) handleRepeatFlight(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	query := r.URL.Query()
+	email := query.Get("email")
+	if email == "" {
+		http.Error(w, "email query parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	date := query.Get("date")
+	if date != "" {
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			http.Error(w, "date must be in YYYY-MM-DD format", http.StatusBadRequest)
+			return
+		}
+	}
+	kind := query.Get("type")
+	switch kind {
+	case "", "repeat":
+		if date == "" {
+			http.Error(w, "date query parameter is required to repeat a flight", http.StatusBadRequest)
+			return
+		}
+	case "return":
+	default:
+		http.Error(w, "type must be repeat or return", http.StatusBadRequest)
+		return
+	}
+
+	flight, err := s.store.GetFlight(r.Context(), id, email)
+	if errors.Is(err, cosmosdb.ErrNotFound) {
+		http.Error(w, "Flight not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to get flight: %v", err)
+		http.Error(w, "Failed to get flight: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var draft *cosmosdb.BoardingPass
+	if kind == "return" {
+		draft = flight.MakeReturn()
+		draft.DepartureDate = date
+	} else {
+		draft = flight.MakeRepeat(date)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(draft)
+}
+
+// handleListRoutes returns the user's distinct routes with flight counts, most flown first,
+// for drawing a route map alongside the airport coordinates
+func (s *Server) handleListRoutes(w http.ResponseWriter, r *http.Request) {
+	email := r.URL.Query().Get("email")
+	if email == "" {
+		http.Error(w, "email query parameter is required", http.StatusBadRequest)
+		return
 	}
 
-	// Remove leading slash and "static/" prefix if present
-	filePath := strings.TrimPrefix(path, "/")
-	if strings.HasPrefix(filePath, "static/") {
-		filePath = strings.TrimPrefix(filePath, "static/")
+	routes, err := s.store.ListRoutes(r.Context(), email)
+	if err != nil {
+		log.Printf("Failed to list routes: %v", err)
+		http.Error(w, "Failed to list routes: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(routes)
+}
+
+// handleListPassengers returns the distinct passenger names on the user's flights, so accounts
+// holding a family's flights can switch between passengers with ?passenger= on GET /api/flights
+func (s *Server) handleListPassengers(w http.ResponseWriter, r *http.Request) {
+	email := r.URL.Query().Get("email")
+	if email == "" {
+		http.Error(w, "email query parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	passengers, err := s.store.ListPassengers(r.Context(), email)
+	if err != nil {
+		log.Printf("Failed to list passengers: %v", err)
+		http.Error(w, "Failed to list passengers: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(passengers)
+}
+
+// FlightStats is the response of GET /api/stats
+type FlightStats struct {
+	Flights int                 `json:"flights"`
+	Seats   *cosmosdb.SeatStats `json:"seats"`
+}
+
+// handleStats returns a user's flight count and seating habits: window, middle, and aisle counts
+// under the configured cabin layout and their most frequent seat
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	email := r.URL.Query().Get("email")
+	if email == "" {
+		http.Error(w, "email query parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	flights, err := s.store.ListFlights(r.Context(), email)
+	if err != nil {
+		log.Printf("Failed to list flights: %v", err)
+		http.Error(w, "Failed to list flights: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(FlightStats{
+		Flights: len(flights),
+		Seats:   cosmosdb.SeatPreferences(flights, s.cfg.SeatLayout),
+	})
+}
+
+// handleStatsTimeline returns the number of flights per departure month or year (?bucket=month|year, default month)
+func (s *Server) handleStatsTimeline(w http.ResponseWriter, r *http.Request) {
+	email := r.URL.Query().Get("email")
+	if email == "" {
+		http.Error(w, "email query parameter is required", http.StatusBadRequest)
+		return
+	}
+	bucket := r.URL.Query().Get("bucket")
+	if bucket == "" {
+		bucket = cosmosdb.BucketMonth
+	}
+
+	flights, err := s.store.ListFlights(r.Context(), email)
+	if err != nil {
+		log.Printf("Failed to list flights: %v", err)
+		http.Error(w, "Failed to list flights: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	timeline, err := cosmosdb.FlightsByPeriod(flights, bucket)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(timeline)
+}
+
+// handleListFlights returns recent flights for a user.
+// Optional airline, from, to, after, before, and tag query parameters filter the list server-side.
+// With any of sort, order, page, or size, one page of the sorted list is returned instead of all
+// of it. The number of matching flights is sent in the X-Total-Count header either way.
+func (s *Server) handleListFlights(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	email := query.Get("email")
+	if email == "" {
+		http.Error(w, "email query parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	filter := cosmosdb.FlightFilter{
+		Airline:     strings.TrimSpace(query.Get("airline")),
+		FromAirport: strings.TrimSpace(query.Get("from")),
+		ToAirport:   strings.TrimSpace(query.Get("to")),
+		After:       query.Get("after"),
+		Before:      query.Get("before"),
+		Tag:         strings.TrimSpace(query.Get("tag")),
+		Passenger:   strings.TrimSpace(query.Get("passenger")),
+	}
+	if err := filter.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, paged, err := parseFlightPage(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Show recent flights in the main UI (sorted by most recent first)
+	var flights []cosmosdb.BoardingPass
+	var total int
+	switch {
+	case paged:
+		flights, total, err = s.store.ListFlightsPage(r.Context(), email, filter, page)
+	case filter == (cosmosdb.FlightFilter{}):
+		flights, err = s.store.ListFlights(r.Context(), email)
+		total = len(flights)
+	default:
+		flights, err = s.store.ListFlightsFiltered(r.Context(), email, filter)
+		total = len(flights)
 	}
+	if errors.Is(err, cosmosdb.ErrInvalidPage) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to list flights: %v", err)
+		http.Error(w, "Failed to list flights: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	json.NewEncoder(w).Encode(flights)
+}
+
+// parseFlightPage reads the sort, order, page, and size query parameters, reporting whether any was
+// given. An empty sort is departureDate; an empty order is descending when sort is also empty (the
+// unpaged list's order) and ascending otherwise.
+func parseFlightPage(query url.Values) (cosmosdb.FlightPage, bool, error) {
+	page := cosmosdb.FlightPage{Sort: query.Get("sort"), Order: query.Get("order")}
+	paged := page.Sort != "" || page.Order != "" || query.Has("page") || query.Has("size")
+	if page.Order == "" && page.Sort != "" {
+		page.Order = cosmosdb.OrderAsc
+	}
+	for name, dst := range map[string]*int{"page": &page.Page, "size": &page.Size} {
+		v := query.Get(name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return page, paged, fmt.Errorf("%s must be a positive integer", name)
+		}
+		*dst = n
+	}
+	return page, paged, page.Validate()
+}
+
+// streamFlushEvery is how many items handleListAllFlights writes between flushes
+const streamFlushEvery = 100
+
+// handleListAllFlights returns all flights for a user (for the expandable section)
+func (s *Server) handleListAllFlights(w http.ResponseWriter, r *http.Request) {
+	email := r.URL.Query().Get("email")
+	if email == "" {
+		http.Error(w, "email query parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	// Stream the array item by item so large histories aren't buffered in memory
+	flusher, _ := w.(http.Flusher)
+	written := 0
+	err := s.store.StreamFlights(r.Context(), email, func(flight cosmosdb.BoardingPass) error {
+		item, err := json.Marshal(flight)
+		if err != nil {
+			return err
+		}
+		if written == 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte("["))
+		} else {
+			w.Write([]byte(","))
+		}
+		if _, err := w.Write(item); err != nil {
+			return err
+		}
+		written++
+		if flusher != nil && written%streamFlushEvery == 0 {
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to list all flights after %d items: %v", written, err)
+		if written == 0 {
+			http.Error(w, "Failed to list flights: "+err.Error(), http.StatusInternalServerError)
+		}
+		// Once the array has started the status is already sent; the truncated body signals the failure
+		return
+	}
+
+	if written == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("[]"))
+		return
+	}
+	w.Write([]byte("]"))
+}
+
+// handleSearchFlights matches a term against airline, airports, passenger, and flight number without the AI
+func (s *Server) handleSearchFlights(w http.ResponseWriter, r *http.Request) {
+	email := r.URL.Query().Get("email")
+	if email == "" {
+		http.Error(w, "email query parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	term := r.URL.Query().Get("q")
+	if strings.TrimSpace(term) == "" {
+		http.Error(w, "q query parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	flights, err := s.store.SearchFlights(r.Context(), email, term)
+	if err != nil {
+		log.Printf("Failed to search flights: %v", err)
+		http.Error(w, "Failed to search flights: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(flights)
+}
+
+// handleListUpcomingFlights returns flights departing today or later (in the departure airport's timezone), soonest first
+func (s *Server) handleListUpcomingFlights(w http.ResponseWriter, r *http.Request) {
+	email := r.URL.Query().Get("email")
+	if email == "" {
+		http.Error(w, "email query parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	flights, err := s.store.ListUpcomingFlights(r.Context(), email, time.Now(), s.cfg.Location)
+	if err != nil {
+		log.Printf("Failed to list upcoming flights: %v", err)
+		http.Error(w, "Failed to list flights: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(flights)
+}
+
+// handleNextFlight returns the single soonest upcoming flight, or 204 No Content when there is none.
+// It is meant for frequent polling (e.g. a home-screen widget), so it runs one small query.
+func (s *Server) handleNextFlight(w http.ResponseWriter, r *http.Request) {
+	email := r.URL.Query().Get("email")
+	if email == "" {
+		http.Error(w, "email query parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	flight, err := s.store.NextFlight(r.Context(), email, time.Now(), s.cfg.Location)
+	if errors.Is(err, cosmosdb.ErrNotFound) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to get next flight: %v", err)
+		http.Error(w, "Failed to get next flight: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(flight)
+}
+
+// handleListPastFlights returns flights that departed before today (in the departure airport's timezone), most recent first
+func (s *Server) handleListPastFlights(w http.ResponseWriter, r *http.Request) {
+	email := r.URL.Query().Get("email")
+	if email == "" {
+		http.Error(w, "email query parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	flights, err := s.store.ListPastFlights(r.Context(), email, time.Now(), s.cfg.Location)
+	if err != nil {
+		log.Printf("Failed to list past flights: %v", err)
+		http.Error(w, "Failed to list flights: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(flights)
+}
 
-	// Construct full path
-	fullPath := filepath.Join("static", filePath)
+// today returns the current date (YYYY-MM-DD) in the configured timezone
+func (s *Server) today() string {
+	return time.Now().In(s.cfg.Location).Format("2006-01-02")
+}
 
-	// Check if file exists
-	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-		http.NotFound(w, r)
+// handleUpdateFlight replaces a flight's details.
+// An If-Match header with the flight's ETag rejects the update with 412 if it was changed since it was read.
+func (s *Server) handleUpdateFlight(w http.ResponseWriter, r *http.Request) {
+	var flight cosmosdb.BoardingPass
+	if !decodeJSONBody(w, r, &flight) {
 		return
 	}
 
-	// Set content type based on extension
-	ext := filepath.Ext(fullPath)
-	switch ext {
-	case ".html":
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	case ".css":
-		w.Header().Set("Content-Type", "text/css; charset=utf-8")
-	case ".js":
-		w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+	id := r.PathValue("id")
+	if flight.ID != "" && flight.ID != id {
+		http.Error(w, "Flight ID in body does not match path", http.StatusBadRequest)
+		return
 	}
+	flight.ID = id
 
-	http.ServeFile(w, r, fullPath)
-}
-
-// handleExtract handles boarding pass image upload and extraction via SSE
-func (s *Server) handleExtract(w http.ResponseWriter, r *http.Request) {
-	// Get email from header
-	email := r.Header.Get("X-User-Email")
-	if email == "" {
-		http.Error(w, "X-User-Email header is required", http.StatusBadRequest)
+	if !claimOwner(w, r, &flight.Email) {
 		return
 	}
-
-	// Parse multipart form (max 10MB)
-	if err := r.ParseMultipartForm(10 << 20); err != nil {
-		http.Error(w, "Failed to parse form: "+err.Error(), http.StatusBadRequest)
+	if flight.Email == "" {
+		http.Error(w, "Email is required", http.StatusBadRequest)
 		return
 	}
-
-	// Get model from form (optional, defaults to server default)
-	model := r.FormValue("model")
-	if model == "" {
-		model = s.defaultModel
+	if err := validateEmail(flight.Email); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
-	// log.Printf("[EXTRACT] Request | User: %s | Model: %s", email, model)
-
-	// Get uploaded file
-	file, header, err := r.FormFile("image")
-	if err != nil {
-		http.Error(w, "Failed to get image: "+err.Error(), http.StatusBadRequest)
+	if err := flight.ValidateAnnotations(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	defer file.Close()
 
-	// Save to temp file
-	// Use UPLOAD_DIR if set (Docker Compose: shared volume with CLI container), else system temp
-	uploadDir := os.Getenv("UPLOAD_DIR")
-	if uploadDir == "" {
-		uploadDir = os.TempDir()
+	updated, err := s.store.UpdateFlight(r.Context(), &flight, r.Header.Get("If-Match"))
+	if errors.Is(err, cosmosdb.ErrNotFound) {
+		http.Error(w, "Flight not found", http.StatusNotFound)
+		return
 	}
-	tempFile := filepath.Join(uploadDir, "boarding-pass-"+uuid.New().String()+filepath.Ext(header.Filename))
-	out, err := os.Create(tempFile)
-	if err != nil {
-		http.Error(w, "Failed to save image: "+err.Error(), http.StatusInternalServerError)
+	if errors.Is(err, cosmosdb.ErrPreconditionFailed) {
+		http.Error(w, "Flight was modified by another request; reload and try again", http.StatusPreconditionFailed)
 		return
 	}
-	defer os.Remove(tempFile)
-
-	if _, err := io.Copy(out, file); err != nil {
-		out.Close()
-		http.Error(w, "Failed to save image: "+err.Error(), http.StatusInternalServerError)
+	if err != nil {
+		log.Printf("Failed to update flight: %v", err)
+		http.Error(w, "Failed to update flight: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	out.Close()
+	s.chatHandler.InvalidateCache(updated.Email)
 
-	// Set up SSE headers
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", updated.ETag)
+	json.NewEncoder(w).Encode(updated)
+}
 
-	flusher, ok := w.(http.Flusher)
-	if !ok {
-		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+// handlePatchFlight updates only the fields present in the JSON body, e.g. {"seat": "14C"}.
+// The owning user is identified by the email query parameter.
+func (s *Server) handlePatchFlight(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	email := r.URL.Query().Get("email")
+	if id == "" || email == "" {
+		http.Error(w, "id path parameter and email query parameter are required", http.StatusBadRequest)
 		return
 	}
 
-	// Send initial step (Step 1: Image uploaded)
-	sendSSE(w, flusher, "step", `{"step":1,"status":"completed"}`)
-
-	// Create callback for extraction progress
-	callback := func(eventType, data string) {
-		sendSSE(w, flusher, eventType, data)
+	var fields map[string]any
+	if !decodeJSONBody(w, r, &fields) {
+		return
 	}
 
-	// Extract flight data using Copilot
-	flight, err := s.extractor.Extract(r.Context(), tempFile, email, model, callback)
+	err := s.store.PatchFlight(r.Context(), id, email, fields, "")
+	if errors.Is(err, cosmosdb.ErrInvalidPatch) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, cosmosdb.ErrNotFound) {
+		http.Error(w, "Flight not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
-		sendSSE(w, flusher, "error", err.Error())
+		log.Printf("Failed to patch flight: %v", err)
+		http.Error(w, "Failed to update flight: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	s.chatHandler.InvalidateCache(email)
 
-	// Send extracted data
-	flightJSON, _ := json.Marshal(flight)
-	sendSSE(w, flusher, "extracted", string(flightJSON))
-	sendSSE(w, flusher, "done", "")
+	w.WriteHeader(http.StatusNoContent)
 }
 
-// sendSSE sends a Server-Sent Event
-func sendSSE(w http.ResponseWriter, flusher http.Flusher, event, data string) {
-	fmt.Fprintf(w, "event: %s\n", event)
-	fmt.Fprintf(w, "data: %s\n\n", data)
-	flusher.Flush()
-}
+// handleDeleteFlight moves a flight to the trash, or removes it permanently with ?purge=true.
+// The response body is the deleted flight, which can be re-POSTed to undo.
+func (s *Server) handleDeleteFlight(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	email := r.URL.Query().Get("email")
 
-// handleCreateFlight saves a confirmed flight to Cosmos DB
-func (s *Server) handleCreateFlight(w http.ResponseWriter, r *http.Request) {
-	var flight cosmosdb.BoardingPass
-	if err := json.NewDecoder(r.Body).Decode(&flight); err != nil {
-		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+	if id == "" || email == "" {
+		http.Error(w, "id path parameter and email query parameter are required", http.StatusBadRequest)
 		return
 	}
 
-	// Validate required fields
-	if flight.Email == "" {
-		http.Error(w, "Email is required", http.StatusBadRequest)
+	var deleted *cosmosdb.BoardingPass
+	var err error
+	purge := r.URL.Query().Get("purge") == "true"
+	if purge {
+		deleted, err = s.store.PurgeFlight(r.Context(), id, email)
+	} else {
+		deleted, err = s.store.DeleteFlight(r.Context(), id, email, "")
+	}
+	if errors.Is(err, cosmosdb.ErrNotFound) {
+		http.Error(w, "Flight not found", http.StatusNotFound)
 		return
 	}
-
-	// Save to Cosmos DB
-	saved, err := s.cosmos.SaveFlight(r.Context(), &flight)
 	if err != nil {
-		log.Printf("Failed to save flight: %v", err)
-		http.Error(w, "Failed to save flight: "+err.Error(), http.StatusInternalServerError)
+		log.Printf("Failed to delete flight: %v", err)
+		http.Error(w, "Failed to delete flight: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if purge {
+		s.deleteImage(r.Context(), deleted)
+	}
+	s.chatHandler.InvalidateCache(email)
 
+	// Return the deleted flight so the client can undo by POSTing it back to /api/flights
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(saved)
+	json.NewEncoder(w).Encode(deleted)
 }
 
-// handleListFlights returns recent flights for a user
-func (s *Server) handleListFlights(w http.ResponseWriter, r *http.Request) {
+// handleRestoreFlight moves a flight out of the trash
+func (s *Server) handleRestoreFlight(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
 	email := r.URL.Query().Get("email")
-	if email == "" {
-		http.Error(w, "email query parameter is required", http.StatusBadRequest)
+
+	if id == "" || email == "" {
+		http.Error(w, "id path parameter and email query parameter are required", http.StatusBadRequest)
 		return
 	}
 
-	// Show recent flights in the main UI (sorted by most recent first)
-	flights, err := s.cosmos.ListFlights(r.Context(), email)
+	flight, err := s.store.RestoreFlight(r.Context(), id, email)
+	if errors.Is(err, cosmosdb.ErrNotFound) {
+		http.Error(w, "Flight not found in trash", http.StatusNotFound)
+		return
+	}
 	if err != nil {
-		log.Printf("Failed to list flights: %v", err)
-		http.Error(w, "Failed to list flights: "+err.Error(), http.StatusInternalServerError)
+		log.Printf("Failed to restore flight: %v", err)
+		http.Error(w, "Failed to restore flight: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	s.chatHandler.InvalidateCache(email)
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(flights)
+	json.NewEncoder(w).Encode(flight)
 }
 
-// handleListAllFlights returns all flights for a user (for the expandable section)
-func (s *Server) handleListAllFlights(w http.ResponseWriter, r *http.Request) {
+// handleListDeletedFlights returns the user's trashed flights. Flights past the trash retention
+// are removed by sweepTrash, not here.
+func (s *Server) handleListDeletedFlights(w http.ResponseWriter, r *http.Request) {
 	email := r.URL.Query().Get("email")
 	if email == "" {
 		http.Error(w, "email query parameter is required", http.StatusBadRequest)
 		return
 	}
 
-	flights, err := s.cosmos.ListFlights(r.Context(), email)
+	flights, err := s.store.ListDeletedFlights(r.Context(), email)
 	if err != nil {
-		log.Printf("Failed to list all flights: %v", err)
-		http.Error(w, "Failed to list flights: "+err.Error(), http.StatusInternalServerError)
+		log.Printf("Failed to list deleted flights: %v", err)
+		http.Error(w, "Failed to list deleted flights: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -280,23 +1218,41 @@ func (s *Server) handleListAllFlights(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(flights)
 }
 
-// handleDeleteFlight removes a flight from Cosmos DB
-func (s *Server) handleDeleteFlight(w http.ResponseWriter, r *http.Request) {
-	id := r.PathValue("id")
-	email := r.URL.Query().Get("email")
-
-	if id == "" || email == "" {
-		http.Error(w, "id path parameter and email query parameter are required", http.StatusBadRequest)
-		return
+// trashSweepInterval is how often sweepTrash purges flights past the trash retention
+const trashSweepInterval = time.Hour
+
+// sweepTrash permanently removes flights trashed longer than Config.TrashRetention ago, along with
+// their stored images, every trashSweepInterval until the server's context is done
+func (s *Server) sweepTrash() {
+	ticker := time.NewTicker(trashSweepInterval)
+	defer ticker.Stop()
+	for {
+		s.purgeExpiredTrash()
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		}
 	}
+}
 
-	if err := s.cosmos.DeleteFlight(r.Context(), id, email); err != nil {
-		log.Printf("Failed to delete flight: %v", err)
-		http.Error(w, "Failed to delete flight: "+err.Error(), http.StatusInternalServerError)
-		return
+// purgeExpiredTrash runs one trash sweep
+func (s *Server) purgeExpiredTrash() {
+	purged, err := s.store.PurgeDeletedFlights(s.ctx, time.Now().Add(-s.cfg.TrashRetention))
+	if err != nil {
+		log.Printf("Failed to purge expired trash: %v", err)
+	}
+	emails := make(map[string]bool)
+	for i := range purged {
+		s.deleteImage(s.ctx, &purged[i])
+		emails[purged[i].Email] = true
+	}
+	for email := range emails {
+		s.chatHandler.InvalidateCache(email)
+	}
+	if len(purged) > 0 {
+		log.Printf("Purged %d flights from trash older than %v", len(purged), s.cfg.TrashRetention)
 	}
-
-	w.WriteHeader(http.StatusNoContent)
 }
 
 // handleLoadSampleData inserts sample flights for demo purposes
@@ -315,32 +1271,44 @@ func (s *Server) handleLoadSampleData(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Determine how many flights to select (default: 30, configurable via ?count=N)
-	count := 30
+	// Determine how many flights to select (SAMPLE_DEFAULT_COUNT, overridable via ?count=N up to the max)
+	maxCount := len(templates)
+	if s.cfg.SampleMaxCount > 0 {
+		// Flights are picked without repeats, so never more than the templates
+		maxCount = min(s.cfg.SampleMaxCount, maxCount)
+	}
+	count := min(s.cfg.SampleDefaultCount, maxCount)
 	if countParam := r.URL.Query().Get("count"); countParam != "" {
-		if n, err := strconv.Atoi(countParam); err == nil && n > 0 {
-			count = n
+		n, err := strconv.Atoi(countParam)
+		if err != nil || n <= 0 {
+			http.Error(w, "count must be a positive integer", http.StatusBadRequest)
+			return
 		}
+		if n > maxCount {
+			http.Error(w, fmt.Sprintf("count must not exceed %d", maxCount), http.StatusBadRequest)
+			return
+		}
+		count = n
 	}
 
-	// Shuffle templates randomly and select up to 'count' flights
+	// Shuffle templates randomly and select 'count' flights
 	rand.Shuffle(len(templates), func(i, j int) {
 		templates[i], templates[j] = templates[j], templates[i]
 	})
-	if count > len(templates) {
-		count = len(templates)
-	}
 	selected := templates[:count]
 
 	// Derive passenger name from email prefix
 	passengerName := formatNameFromEmail(email)
 
+	// Sample flights expire automatically if the container has TTL enabled
+	ttl := int(s.cfg.SampleDataTTL.Seconds())
+
 	// Convert templates to BoardingPass with dynamic dates
 	now := time.Now()
-	saved := make([]cosmosdb.BoardingPass, 0, len(selected))
+	flights := make([]*cosmosdb.BoardingPass, 0, len(selected))
 	for _, tmpl := range selected {
 		departureDate := now.AddDate(0, 0, tmpl.DepartureDayOffset).Format("2006-01-02")
-		flight := cosmosdb.BoardingPass{
+		flights = append(flights, &cosmosdb.BoardingPass{
 			FlightNumber:  tmpl.FlightNumber,
 			Airline:       tmpl.Airline,
 			FromAirport:   tmpl.FromAirport,
@@ -351,20 +1319,86 @@ func (s *Server) handleLoadSampleData(w http.ResponseWriter, r *http.Request) {
 			Gate:          tmpl.Gate,
 			Passenger:     passengerName,
 			Email:         email,
-		}
-		f, err := s.cosmos.SaveFlight(r.Context(), &flight)
+			TTL:           ttl,
+		})
+	}
+
+	// Flights the user already has (same flight number and date) are skipped, or with ?replace=true
+	// deleted and re-inserted so repeated loads refresh the demo set instead of stacking duplicates
+	replace := r.URL.Query().Get("replace") == "true"
+	pending := make([]*cosmosdb.BoardingPass, 0, len(flights))
+	skipped, replaced := 0, 0
+	for _, flight := range flights {
+		existing, err := s.store.FindDuplicate(r.Context(), flight)
 		if err != nil {
-			log.Printf("Failed to save sample flight: %v", err)
+			log.Printf("Failed to check for existing sample flight: %v", err)
+			http.Error(w, "Failed to load sample data", http.StatusInternalServerError)
+			return
+		}
+		if existing == nil {
+			pending = append(pending, flight)
 			continue
 		}
-		saved = append(saved, *f)
+		if !replace {
+			skipped++
+			continue
+		}
+		if _, err := s.store.PurgeFlight(r.Context(), existing.ID, email); err != nil && !errors.Is(err, cosmosdb.ErrNotFound) {
+			log.Printf("Failed to replace sample flight %s: %v", existing.ID, err)
+			http.Error(w, "Failed to load sample data", http.StatusInternalServerError)
+			return
+		}
+		replaced++
+		pending = append(pending, flight)
+	}
+
+	// All sample flights share the user's partition, so they can be written in transactional batches.
+	// Items that fail (e.g. duplicates added concurrently) are skipped rather than failing the load.
+	saved, err := s.store.SaveFlightsBatch(r.Context(), pending)
+	s.chatHandler.InvalidateCache(email)
+	if ctxErr := r.Context().Err(); ctxErr != nil {
+		// Stop early if the client disconnected or the server is shutting down
+		log.Printf("Sample data load cancelled after %d flights: %v", len(saved), ctxErr)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to save some sample flights: %v", err)
+	}
+	if saved == nil {
+		http.Error(w, "Failed to load sample data: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if len(saved) < count {
+		log.Printf("Loaded %d of %d requested sample flights (%d already existed)", len(saved), count, skipped)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(saved)
+	json.NewEncoder(w).Encode(SampleDataResponse{
+		Requested: count,
+		Created:   len(saved),
+		Skipped:   skipped,
+		Replaced:  replaced,
+		Flights:   saved,
+	})
+}
+
+// SampleDataResponse summarizes a sample data load; Created is less than Requested if some saves failed
+type SampleDataResponse struct {
+	Requested int                     `json:"requested"`
+	Created   int                     `json:"created"`
+	Skipped   int                     `json:"skipped"`  // Already existed and left as is
+	Replaced  int                     `json:"replaced"` // Already existed and re-created (?replace=true); included in Created
+	Flights   []cosmosdb.BoardingPass `json:"flights"`
 }
 
+// defaultSampleCount is the number of sample flights loaded when ?count is not given
+const defaultSampleCount = 30
+
+// defaultSampleDataTTL is how long sample flights live when the container has TTL enabled
+const defaultSampleDataTTL = 24 * time.Hour
+
 // formatNameFromEmail extracts and formats a name from an email prefix
 // e.g., "john.doe@example.com" -> "John Doe"
 // e.g., "jane_smith@example.com" -> "Jane Smith"
@@ -399,6 +1433,37 @@ func formatNameFromEmail(email string) string {
 type ChatRequest struct {
 	Message string `json:"message"`
 	Model   string `json:"model"`
+	Locale  string `json:"locale,omitempty"` // BCP 47 tag for dates in the answer; defaults to Accept-Language
+}
+
+// chatLocale returns the requested locale, falling back to the client's preferred Accept-Language tag
+func chatLocale(req ChatRequest, r *http.Request) string {
+	if req.Locale != "" {
+		return req.Locale
+	}
+	return preferredLanguage(r.Header.Get("Accept-Language"))
+}
+
+// preferredLanguage returns the highest-weighted tag in an Accept-Language header, ignoring "*"
+func preferredLanguage(header string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q > bestQ {
+			best, bestQ = tag, q
+		}
+	}
+	return best
 }
 
 // handleChat processes natural language queries about flights via SSE
@@ -410,10 +1475,13 @@ func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !s.requireCopilot(w) {
+		return
+	}
+
 	// Parse request body
 	var req ChatRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+	if !decodeJSONBody(w, r, &req) {
 		return
 	}
 
@@ -425,50 +1493,83 @@ func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
 	// Get model (default to server default if not provided)
 	model := req.Model
 	if model == "" {
-		model = s.defaultModel
+		model = s.getDefaultModel()
 	}
-	// log.Printf("[CHAT] Request | User: %s | Model: %s | Message: %s", email, model, req.Message)
-
-	// Set up SSE headers
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+	slog.Debug("[CHAT] Request", "user", redact.Email(email), "model", model, "message", redact.Text(req.Message))
 
-	flusher, ok := w.(http.Flusher)
+	// Set up the SSE stream; writes are serialized since SDK callbacks run on other goroutines
+	sse, ok := newSSEWriter(w)
 	if !ok {
 		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
 		return
 	}
+	defer sse.Close()
+	send := sse.Send
 
-	// Create callback for streaming updates
-	callback := func(eventType, data string) {
-		sendSSE(w, flusher, eventType, data)
-	}
+	// Keep the connection alive while the model is working
+	sse.StartHeartbeat(r.Context())
 
-	// Process the chat query
-	response, err := s.chatHandler.Chat(r.Context(), req.Message, email, model, callback)
+	// Process the chat query, streaming updates via the callback
+	s.recordUsage(email, model)
+	response, err := s.chatHandler.Chat(r.Context(), req.Message, email, model, chatLocale(req, r), send)
+	s.recordChat(model, err)
 	if err != nil {
-		sendSSE(w, flusher, "error", err.Error())
+		// Replay the attempted query (if any) so the UI can show what was tried
+		if response != nil {
+			responseJSON, _ := json.Marshal(response)
+			send("response", string(responseJSON))
+		}
+		send("error", newErrorEvent(err).String())
 		return
 	}
 
 	// Send final response
 	responseJSON, _ := json.Marshal(response)
-	sendSSE(w, flusher, "response", string(responseJSON))
-	sendSSE(w, flusher, "done", "")
+	send("response", string(responseJSON))
+	send("done", "")
+}
+
+// ExplainRequest represents a query cost preview request
+type ExplainRequest struct {
+	Query string `json:"query"`
+}
+
+// handleExplainQuery previews the RU cost and index usage of a query without returning its results
+func (s *Server) handleExplainQuery(w http.ResponseWriter, r *http.Request) {
+	// Get email from header
+	email := r.Header.Get("X-User-Email")
+	if email == "" {
+		http.Error(w, "X-User-Email header is required", http.StatusBadRequest)
+		return
+	}
+
+	var req ExplainRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	if req.Query == "" {
+		http.Error(w, "Query is required", http.StatusBadRequest)
+		return
+	}
+
+	plan, err := s.store.ExplainQuery(r.Context(), req.Query, email)
+	if err != nil {
+		log.Printf("Failed to explain query: %v", err)
+		http.Error(w, "Failed to explain query: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(plan)
 }
 
 // handleListSamples returns a list of available sample boarding pass images
 func (s *Server) handleListSamples(w http.ResponseWriter, r *http.Request) {
-	samplesDir := "static/samples"
-
-	// Read directory
-	entries, err := os.ReadDir(samplesDir)
+	entries, err := fs.ReadDir(static.FS, "samples")
 	if err != nil {
 		// If directory doesn't exist, return empty array
-		w.Header().Set("Content-Type", "application/json")
-		w.Write([]byte("[]"))
+		writeCachedJSON(w, r, []string{}, samplesMaxAge)
 		return
 	}
 
@@ -485,11 +1586,10 @@ func (s *Server) handleListSamples(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(samples)
+	writeCachedJSON(w, r, samples, samplesMaxAge)
 }
 
-// handleSampleImage serves sample boarding pass images
+// handleSampleImage serves sample boarding pass images from the embedded samples directory
 func (s *Server) handleSampleImage(w http.ResponseWriter, r *http.Request) {
 	// Get filename from path
 	filename := strings.TrimPrefix(r.URL.Path, "/samples/")
@@ -504,28 +1604,16 @@ func (s *Server) handleSampleImage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	fullPath := filepath.Join("static", "samples", filename)
-
-	// Check if file exists
-	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
+	// ServeFileFS answers If-None-Match with 304 once the ETag header is set
+	name := "samples/" + filename
+	etag, err := staticETags.get(name)
+	if err != nil {
 		http.NotFound(w, r)
 		return
 	}
+	setCacheHeaders(w, r, etag, sampleImageMaxAge)
 
-	// Set content type based on extension
-	ext := strings.ToLower(filepath.Ext(filename))
-	switch ext {
-	case ".png":
-		w.Header().Set("Content-Type", "image/png")
-	case ".jpg", ".jpeg":
-		w.Header().Set("Content-Type", "image/jpeg")
-	case ".gif":
-		w.Header().Set("Content-Type", "image/gif")
-	case ".webp":
-		w.Header().Set("Content-Type", "image/webp")
-	}
-
-	http.ServeFile(w, r, fullPath)
+	http.ServeFileFS(w, r, static.FS, name)
 }
 
 // ============================================================================
@@ -534,31 +1622,92 @@ func (s *Server) handleSampleImage(w http.ResponseWriter, r *http.Request) {
 
 // ModelResponse represents a model for the frontend
 type ModelResponse struct {
-	ID         string  `json:"id"`
-	Name       string  `json:"name"`
-	Vision     bool    `json:"vision"`
-	Multiplier float64 `json:"multiplier"`
-	CostLabel  string  `json:"costLabel"`
+	ID         string       `json:"id"`
+	Name       string       `json:"name"`
+	Vision     bool         `json:"vision"`
+	Multiplier float64      `json:"multiplier"`
+	CostLabel  string       `json:"costLabel"`
+	Health     *ModelHealth `json:"health,omitempty"` // Set in /api/models for models with recent failures
 }
 
 // ModelsListResponse is the response from /api/models
 type ModelsListResponse struct {
 	Models       []ModelResponse `json:"models"`
 	DefaultModel string          `json:"defaultModel"`
+	RefreshedAt  string          `json:"refreshedAt,omitempty"` // RFC3339; empty if models were never loaded
+}
+
+const (
+	// listModelsTimeout bounds each ListModels call so startup can't hang on Copilot
+	listModelsTimeout = 10 * time.Second
+	// modelsRetryInterval is how often a failed model load is retried in the background
+	modelsRetryInterval = 30 * time.Second
+	// defaultModelsRefreshInterval is how often the cached model list is refreshed
+	defaultModelsRefreshInterval = time.Hour
+	// fallbackModel is used when no models could be loaded
+	fallbackModel = "gpt-4.1"
+)
+
+// fallbackModels is the built-in catalog served until Copilot returns a non-empty model list,
+// so the frontend always gets a default it can select. All are free and vision-capable.
+var fallbackModels = []ModelResponse{
+	{ID: fallbackModel, Name: "GPT-4.1", Vision: true, CostLabel: "Free"},
+	{ID: "gpt-4o", Name: "GPT-4o", Vision: true, CostLabel: "Free"},
+	{ID: "gpt-5-mini", Name: "GPT-5 mini", Vision: true, CostLabel: "Free"},
+}
+
+// errNoModels is returned by loadModels when Copilot lists no models without an error
+var errNoModels = errors.New("copilot returned no models")
+
+// useFallbackModels installs the built-in catalog if no models have been loaded yet.
+// A previously loaded list is kept, since it is more accurate than the catalog.
+func (s *Server) useFallbackModels() {
+	s.modelsMu.Lock()
+	defer s.modelsMu.Unlock()
+	if len(s.models) == 0 {
+		s.models = slices.Clone(fallbackModels)
+		s.defaultModel = selectDefaultModel(s.models)
+	}
+}
+
+// listModels calls ListModels with a timeout, since the SDK call doesn't take a context
+func (s *Server) listModels() ([]sdk.ModelInfo, error) {
+	type result struct {
+		models []sdk.ModelInfo
+		err    error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		models, err := s.copilotClient.ListModels()
+		ch <- result{models, err}
+	}()
+
+	select {
+	case res := <-ch:
+		return res.models, res.err
+	case <-s.ctx.Done():
+		return nil, s.ctx.Err()
+	case <-time.After(listModelsTimeout):
+		return nil, fmt.Errorf("timed out after %v", listModelsTimeout)
+	}
 }
 
 // loadModels fetches available models from Copilot SDK and caches them
-func (s *Server) loadModels() {
-	models, err := s.copilotClient.ListModels()
+func (s *Server) loadModels() error {
+	models, err := s.listModels()
 	if err != nil {
 		log.Printf("[MODELS] Failed to fetch models: %v", err)
-		// Set a fallback default
-		s.defaultModel = "gpt-4.1"
-		return
+		s.useFallbackModels()
+		return err
+	}
+	if len(models) == 0 {
+		log.Printf("[MODELS] WARNING: Copilot returned no models; using the built-in catalog until the next refresh")
+		s.useFallbackModels()
+		return errNoModels
 	}
 
 	var visionCount, freeCount int
-	s.models = make([]ModelResponse, 0, len(models))
+	loaded := make([]ModelResponse, 0, len(models))
 
 	for _, m := range models {
 		multiplier := 0.0
@@ -581,7 +1730,7 @@ func (s *Server) loadModels() {
 			visionCount++
 		}
 
-		s.models = append(s.models, ModelResponse{
+		loaded = append(loaded, ModelResponse{
 			ID:         m.ID,
 			Name:       m.Name,
 			Vision:     vision,
@@ -592,13 +1741,113 @@ func (s *Server) loadModels() {
 
 	// Sort: free models first, then by multiplier ascending
 	// Within same multiplier, prefer vision-capable
-	sortModels(s.models)
+	sortModels(loaded)
 
 	// Select default: prefer gpt-4.1 if free+vision, else first free+vision
-	s.defaultModel = selectDefaultModel(s.models)
+	defaultModel := selectDefaultModel(loaded)
+
+	s.modelsMu.Lock()
+	s.models = loaded
+	s.defaultModel = defaultModel
+	s.modelsLoadedAt = time.Now()
+	s.modelsMu.Unlock()
 
 	log.Printf("[MODELS] Loaded %d models, %d vision-capable, %d free. Default: %s",
-		len(s.models), visionCount, freeCount, s.defaultModel)
+		len(loaded), visionCount, freeCount, defaultModel)
+	return nil
+}
+
+// refreshModels re-runs loadModels every interval until the server's context is done.
+// After a failed load it retries sooner, every modelsRetryInterval.
+func (s *Server) refreshModels(interval time.Duration, failed bool) {
+	for {
+		wait := interval
+		if failed {
+			wait = modelsRetryInterval
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-s.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			failed = s.loadModels() != nil
+		}
+	}
+}
+
+// extractionModels returns model followed by the models to fall back to if it fails.
+// Config.FallbackModels sets the fallbacks; by default they are the free vision-capable
+// models, in the same order as the model list. Demoted fallbacks are tried last.
+func (s *Server) extractionModels(model string) []string {
+	fallbacks := s.cfg.FallbackModels
+	if fallbacks == nil {
+		s.modelsMu.RLock()
+		for _, m := range s.models {
+			if m.Multiplier == 0 && m.Vision {
+				fallbacks = append(fallbacks, m.ID)
+			}
+		}
+		s.modelsMu.RUnlock()
+	}
+
+	models := []string{model}
+	var demoted []string
+	for _, id := range fallbacks {
+		switch {
+		case id == "" || id == model:
+		case !s.health.healthy(id):
+			demoted = append(demoted, id)
+		default:
+			models = append(models, id)
+		}
+	}
+	return append(models, demoted...)
+}
+
+// modelVision looks up whether a model accepts image input in the cached model list
+func (s *Server) modelVision(model string) (vision, known bool) {
+	s.modelsMu.RLock()
+	defer s.modelsMu.RUnlock()
+	for _, m := range s.models {
+		if m.ID == model {
+			return m.Vision, true
+		}
+	}
+	return false, false
+}
+
+// checkVisionModel writes a 400 and returns false when model can't read images and no vision-capable
+// fallback is configured. Otherwise extraction proceeds, substituting a fallback if needed.
+func (s *Server) checkVisionModel(w http.ResponseWriter, model string) bool {
+	if len(s.extractor.VisionModels(s.extractionModels(model))) == 0 {
+		http.Error(w, fmt.Sprintf("model %s does not support image input", model), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// getDefaultModel returns the current default model ID, passing over a demoted default
+func (s *Server) getDefaultModel() string {
+	s.modelsMu.RLock()
+	defer s.modelsMu.RUnlock()
+	return s.healthyDefault(s.models, s.defaultModel)
+}
+
+// healthyDefault returns def unless it is demoted, in which case the best healthy model in models
+// is picked instead. If every model is demoted, def is kept.
+func (s *Server) healthyDefault(models []ModelResponse, def string) string {
+	if s.health.healthy(def) {
+		return def
+	}
+	healthy := slices.DeleteFunc(slices.Clone(models), func(m ModelResponse) bool {
+		return !s.health.healthy(m.ID)
+	})
+	if len(healthy) == 0 {
+		return def
+	}
+	return selectDefaultModel(healthy)
 }
 
 // sortModels sorts models: free first, then by multiplier, vision-capable preferred
@@ -644,14 +1893,83 @@ func selectDefaultModel(models []ModelResponse) string {
 	if len(models) > 0 {
 		return models[0].ID
 	}
-	return "gpt-4.1"
+	return fallbackModel
+}
+
+// modelFilter narrows the model list by capability
+type modelFilter struct {
+	vision bool // Only vision-capable models
+	free   bool // Only models with a 0× multiplier
+}
+
+// parseModelFilter reads ?vision=true, ?free=true, and ?capability=vision|chat.
+// capability=chat imposes no restriction since any model can chat.
+func parseModelFilter(r *http.Request) (modelFilter, error) {
+	q := r.URL.Query()
+	f := modelFilter{
+		vision: q.Get("vision") == "true",
+		free:   q.Get("free") == "true",
+	}
+	switch q.Get("capability") {
+	case "", "chat":
+	case "vision":
+		f.vision = true
+	default:
+		return f, fmt.Errorf("unknown capability %q (expected vision or chat)", q.Get("capability"))
+	}
+	return f, nil
+}
+
+// apply returns the models matching the filter, preserving order
+func (f modelFilter) apply(models []ModelResponse) []ModelResponse {
+	filtered := make([]ModelResponse, 0, len(models))
+	for _, m := range models {
+		if f.vision && !m.Vision {
+			continue
+		}
+		if f.free && m.Multiplier != 0 {
+			continue
+		}
+		filtered = append(filtered, m)
+	}
+	return filtered
 }
 
-// handleModels returns the list of available models
+// handleModels returns the list of available models, optionally filtered by capability.
+// When filtered, the default model is chosen from the filtered set.
 func (s *Server) handleModels(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(ModelsListResponse{
+	filter, err := parseModelFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.modelsMu.RLock()
+	resp := ModelsListResponse{
 		Models:       s.models,
 		DefaultModel: s.defaultModel,
-	})
+	}
+	if !s.modelsLoadedAt.IsZero() {
+		resp.RefreshedAt = s.modelsLoadedAt.UTC().Format(time.RFC3339)
+	}
+	s.modelsMu.RUnlock()
+
+	if filter.vision || filter.free {
+		resp.Models = filter.apply(resp.Models)
+		resp.DefaultModel = selectDefaultModel(resp.Models)
+	}
+	resp.DefaultModel = s.healthyDefault(resp.Models, resp.DefaultModel)
+
+	// Report recent failures; the cached list is shared, so annotate a copy
+	resp.Models = slices.Clone(resp.Models)
+	for i := range resp.Models {
+		resp.Models[i].Health = s.health.health(resp.Models[i].ID)
+	}
+	// Never offer a default the client can't select, e.g. when the filter matched nothing
+	if !slices.ContainsFunc(resp.Models, func(m ModelResponse) bool { return m.ID == resp.DefaultModel }) {
+		resp.DefaultModel = ""
+	}
+
+	// The ETag hashes the response, so it changes whenever the background refresh updates the list
+	writeCachedJSON(w, r, resp, modelsMaxAge)
 }
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// heartbeatInterval is how often an SSE comment is written to keep idle streams alive
+const heartbeatInterval = 15 * time.Second
+
+// sseWriter serializes Server-Sent Event writes. SDK callbacks, the heartbeat, and the handler
+// all write from different goroutines, and http.ResponseWriter is not safe for concurrent use,
+// so unsynchronized writes can interleave bytes and corrupt event frames. Writes after Close are
+// dropped, since SDK callbacks can still fire once the handler has returned.
+type sseWriter struct {
+	mu      sync.Mutex
+	w       http.ResponseWriter
+	flusher http.Flusher
+	closed  bool
+	done    chan struct{} // Closed by Close to stop the heartbeat
+}
+
+// newSSEWriter sets the SSE response headers and wraps w.
+// It returns false if the ResponseWriter doesn't support streaming.
+func newSSEWriter(w http.ResponseWriter) (*sseWriter, bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, false
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	return &sseWriter{
+		w:       w,
+		flusher: flusher,
+		done:    make(chan struct{}),
+	}, true
+}
+
+// Send writes a single event. It is safe to call from multiple goroutines and matches ai.ProgressCallback.
+func (s *sseWriter) Send(event, data string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	sendSSE(s.w, s.flusher, event, data)
+}
+
+// StartHeartbeat writes a ": heartbeat" SSE comment every heartbeatInterval so proxies and
+// browsers don't drop the connection while the model is working. Comments are ignored by
+// EventSource parsers. The heartbeat stops when ctx is done or Close is called.
+func (s *sseWriter) StartHeartbeat(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.done:
+				return
+			case <-ticker.C:
+				s.mu.Lock()
+				if !s.closed {
+					fmt.Fprint(s.w, ": heartbeat\n\n")
+					s.flusher.Flush()
+				}
+				s.mu.Unlock()
+			}
+		}
+	}()
+}
+
+// Close stops the heartbeat and drops any later writes. It waits for an in-progress write
+// to finish, so the handler can safely return afterwards.
+func (s *sseWriter) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	close(s.done)
+}
+
+// sendSSE sends a Server-Sent Event. Callers writing from several goroutines should use sseWriter.
+func sendSSE(w http.ResponseWriter, flusher http.Flusher, event, data string) {
+	fmt.Fprintf(w, "event: %s\n", event)
+	fmt.Fprintf(w, "data: %s\n\n", data)
+	flusher.Flush()
+}
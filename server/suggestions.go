@@ -0,0 +1,60 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// maxSuggestions caps the number of suggested chat questions
+const maxSuggestions = 5
+
+// handleChatSuggestions returns example chat questions tailored to the user's flights.
+// It runs one cheap projection query and templates the questions without calling the model,
+// since the UI loads it on page open.
+func (s *Server) handleChatSuggestions(w http.ResponseWriter, r *http.Request) {
+	// Get email from header
+	email := r.Header.Get("X-User-Email")
+	if email == "" {
+		http.Error(w, "X-User-Email header is required", http.StatusBadRequest)
+		return
+	}
+
+	summary, err := s.store.Summarize(r.Context(), email, s.today())
+	if err != nil {
+		log.Printf("Failed to summarize flights: %v", err)
+		http.Error(w, "Failed to load suggestions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var suggestions []string
+	if summary.TotalFlights == 0 {
+		// Nothing to tailor yet; point new users at the basics
+		suggestions = []string{
+			"How many flights have I taken?",
+			"What's my next flight?",
+			"Which airlines have I flown?",
+		}
+	} else {
+		suggestions = append(suggestions, "How many flights have I taken?")
+		if summary.UpcomingFlights > 0 {
+			suggestions = append(suggestions, "What's my next flight?")
+		} else {
+			suggestions = append(suggestions, "When was my last flight?")
+		}
+		if summary.TopAirline != "" {
+			suggestions = append(suggestions, fmt.Sprintf("Show my flights on %s", summary.TopAirline))
+		}
+		if summary.TopDestination != "" {
+			suggestions = append(suggestions, fmt.Sprintf("When did I last fly to %s?", summary.TopDestination))
+		}
+		suggestions = append(suggestions, "What destinations have I visited?")
+	}
+	if len(suggestions) > maxSuggestions {
+		suggestions = suggestions[:maxSuggestions]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(suggestions)
+}
@@ -0,0 +1,159 @@
+package server
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// uploadTTL is how long a kept upload stays available for re-extraction
+	uploadTTL = 10 * time.Minute
+	// uploadSweepInterval is how often expired uploads are removed from disk
+	uploadSweepInterval = time.Minute
+)
+
+// cachedUpload is a boarding pass image kept on disk for re-extraction
+type cachedUpload struct {
+	path    string
+	email   string
+	expires time.Time
+}
+
+// uploadStore keeps uploaded images around for a short time so extraction can be
+// re-run against a different model without re-uploading. Entries are keyed by UUID.
+type uploadStore struct {
+	mu      sync.Mutex
+	uploads map[string]*cachedUpload
+	ttl     time.Duration
+}
+
+// newUploadStore creates an upload store and starts its background sweeper, which runs until ctx is done
+func newUploadStore(ctx context.Context, ttl time.Duration) *uploadStore {
+	store := &uploadStore{
+		uploads: make(map[string]*cachedUpload),
+		ttl:     ttl,
+	}
+	go store.sweep(ctx, uploadSweepInterval)
+	return store
+}
+
+// Put registers a file owned by email and returns its upload ID
+func (u *uploadStore) Put(path, email string) string {
+	id := uuid.New().String()
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.uploads[id] = &cachedUpload{
+		path:    path,
+		email:   email,
+		expires: time.Now().Add(u.ttl),
+	}
+	return id
+}
+
+// Get returns the file path for an upload owned by email, extending its expiry
+func (u *uploadStore) Get(id, email string) (string, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	upload, ok := u.uploads[id]
+	if !ok || !strings.EqualFold(upload.email, email) || time.Now().After(upload.expires) {
+		return "", false
+	}
+	upload.expires = time.Now().Add(u.ttl)
+	// Refresh the mod time so SweepOrphanedUploads doesn't treat an in-use upload as orphaned
+	now := time.Now()
+	os.Chtimes(upload.path, now, now)
+	return upload.path, true
+}
+
+// sweep periodically deletes expired uploads from disk until ctx is done
+func (u *uploadStore) sweep(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			u.removeExpired()
+		}
+	}
+}
+
+// removeExpired deletes all uploads whose TTL has elapsed
+func (u *uploadStore) removeExpired() {
+	now := time.Now()
+
+	u.mu.Lock()
+	var expired []string
+	for id, upload := range u.uploads {
+		if now.After(upload.expires) {
+			expired = append(expired, upload.path)
+			delete(u.uploads, id)
+		}
+	}
+	u.mu.Unlock()
+
+	for _, path := range expired {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Printf("[UPLOADS] Failed to remove expired upload %s: %v", path, err)
+		}
+	}
+}
+
+// uploadFilePrefix is the name prefix of temp files the server writes to Config.UploadDir.
+// Normalized copies from ai.NormalizeImage keep the original's name, so they match too.
+const uploadFilePrefix = "boarding-pass-"
+
+// SweepOrphanedUploads periodically deletes upload temp files in dir older than maxAge, until ctx is done.
+// Requests remove their own files, so this only catches files left behind when the process was
+// killed mid-extraction. Age is measured from the mod time, so files still being written are kept.
+func SweepOrphanedUploads(ctx context.Context, dir string, interval, maxAge time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		removeOrphanedUploads(dir, maxAge)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// removeOrphanedUploads deletes upload temp files in dir last modified more than maxAge ago
+func removeOrphanedUploads(dir string, maxAge time.Duration) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		log.Printf("[UPLOADS] Failed to scan %s for orphaned uploads: %v", dir, err)
+		return
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !strings.HasPrefix(entry.Name(), uploadFilePrefix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Printf("[UPLOADS] Failed to remove orphaned upload %s: %v", path, err)
+			continue
+		}
+		log.Printf("[UPLOADS] Removed orphaned upload %s", path)
+	}
+}
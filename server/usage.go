@@ -0,0 +1,106 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// unknownModelMultiplier is charged for models missing from the model list, assuming premium
+const unknownModelMultiplier = 1.0
+
+// ModelUsage is the estimated spend on one model
+type ModelUsage struct {
+	Model string  `json:"model"`
+	Calls int     `json:"calls"`
+	Cost  float64 `json:"cost"` // Sum of the model's billing multiplier over calls
+}
+
+// UsageResponse is the response from /api/usage
+type UsageResponse struct {
+	Email  string       `json:"email"`
+	Calls  int          `json:"calls"`
+	Cost   float64      `json:"cost"`   // Relative cost: multiplier × calls, summed over models
+	Models []ModelUsage `json:"models"` // Highest cost first
+}
+
+// usageTracker accumulates a relative cost per user: each extraction or chat adds the selected
+// model's billing multiplier. Token counts aren't available for every call, so this is an
+// approximation meant for comparing spend. It is kept in memory only and resets on restart.
+type usageTracker struct {
+	mu     sync.Mutex
+	byUser map[string]map[string]*ModelUsage // email → model → usage
+}
+
+func newUsageTracker() *usageTracker {
+	return &usageTracker{byUser: make(map[string]map[string]*ModelUsage)}
+}
+
+// add records one call to model costing multiplier
+func (t *usageTracker) add(email, model string, multiplier float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	models := t.byUser[email]
+	if models == nil {
+		models = make(map[string]*ModelUsage)
+		t.byUser[email] = models
+	}
+	usage := models[model]
+	if usage == nil {
+		usage = &ModelUsage{Model: model}
+		models[model] = usage
+	}
+	usage.Calls++
+	usage.Cost += multiplier
+}
+
+// get returns the user's accumulated usage
+func (t *usageTracker) get(email string) UsageResponse {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	resp := UsageResponse{Email: email, Models: []ModelUsage{}}
+	for _, usage := range t.byUser[email] {
+		resp.Calls += usage.Calls
+		resp.Cost += usage.Cost
+		resp.Models = append(resp.Models, *usage)
+	}
+	sort.Slice(resp.Models, func(i, j int) bool {
+		if resp.Models[i].Cost != resp.Models[j].Cost {
+			return resp.Models[i].Cost > resp.Models[j].Cost
+		}
+		return resp.Models[i].Model < resp.Models[j].Model
+	})
+	return resp
+}
+
+// recordUsage charges one extraction or chat on model to the user
+func (s *Server) recordUsage(email, model string) {
+	s.usage.add(email, model, s.modelMultiplier(model))
+}
+
+// modelMultiplier returns the model's billing multiplier from the model list
+func (s *Server) modelMultiplier(model string) float64 {
+	s.modelsMu.RLock()
+	defer s.modelsMu.RUnlock()
+	for _, m := range s.models {
+		if m.ID == model {
+			return m.Multiplier
+		}
+	}
+	return unknownModelMultiplier
+}
+
+// handleUsage returns the user's estimated relative model spend since the server started
+func (s *Server) handleUsage(w http.ResponseWriter, r *http.Request) {
+	email := r.URL.Query().Get("email")
+	if email == "" {
+		http.Error(w, "email query parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.usage.get(email))
+}
@@ -0,0 +1,214 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/abhirockzz/flight-log-app/redact"
+)
+
+const (
+	// watcherBuffer is how many events a watcher may fall behind before it is dropped
+	watcherBuffer = 64
+	// maxTopicHistory caps the events replayed to watchers that join mid-extraction
+	maxTopicHistory = 256
+)
+
+// hubEvent is one SSE event relayed to watchers
+type hubEvent struct {
+	event, data string
+}
+
+// extractionTopic is the event stream of the extractions running on one upload
+type extractionTopic struct {
+	email      string
+	publishers int        // Extractions still running; the topic closes when this drops to 0
+	history    []hubEvent // Replayed to new watchers so they see the steps they missed
+	watchers   map[chan hubEvent]struct{}
+	cancels    map[int]func() // Stop each running extraction, keyed by the order it started
+	nextCancel int
+}
+
+// extractionHub fans out the events of extractions on kept uploads to watchers, so several screens
+// can follow one extraction, and lets the owner cancel them. Topics are keyed by upload ID and removed when their last extraction
+// finishes, which also ends every watcher's stream. It is kept in memory only.
+type extractionHub struct {
+	mu     sync.Mutex
+	topics map[string]*extractionTopic
+}
+
+func newExtractionHub() *extractionHub {
+	return &extractionHub{topics: make(map[string]*extractionTopic)}
+}
+
+// start registers an extraction on the upload id owned by email; cancel stops it when the owner
+// cancels the upload's extractions. Events passed to publish are sent to the upload's watchers;
+// finish must be called when the extraction ends.
+func (h *extractionHub) start(id, email string, cancel func()) (publish func(event, data string), finish func()) {
+	h.mu.Lock()
+	topic := h.topics[id]
+	if topic == nil {
+		topic = &extractionTopic{
+			email:    email,
+			watchers: make(map[chan hubEvent]struct{}),
+			cancels:  make(map[int]func()),
+		}
+		h.topics[id] = topic
+	}
+	topic.publishers++
+	key := topic.nextCancel
+	topic.nextCancel++
+	topic.cancels[key] = cancel
+	h.mu.Unlock()
+
+	publish = func(event, data string) {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		ev := hubEvent{event, data}
+		if len(topic.history) < maxTopicHistory {
+			topic.history = append(topic.history, ev)
+		}
+		for ch := range topic.watchers {
+			select {
+			case ch <- ev:
+			default:
+				// Never block the extraction on a slow watcher
+				log.Printf("[WATCH] Dropping a watcher of upload %s that fell behind", id)
+				delete(topic.watchers, ch)
+				close(ch)
+			}
+		}
+	}
+
+	var once sync.Once
+	finish = func() {
+		once.Do(func() {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			delete(topic.cancels, key)
+			topic.publishers--
+			if topic.publishers > 0 {
+				return
+			}
+			for ch := range topic.watchers {
+				close(ch)
+			}
+			// SDK callbacks can still publish after the extraction returns; they now reach no one
+			clear(topic.watchers)
+			delete(h.topics, id)
+		})
+	}
+	return publish, finish
+}
+
+// subscribe returns the events of the extraction running on upload id, starting with those already
+// sent. The channel is closed when the extraction finishes. It returns false if no extraction owned
+// by email is running on the upload.
+func (h *extractionHub) subscribe(id, email string) (events <-chan hubEvent, unsubscribe func(), ok bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	topic := h.topics[id]
+	if topic == nil || !strings.EqualFold(topic.email, email) {
+		return nil, nil, false
+	}
+
+	ch := make(chan hubEvent, len(topic.history)+watcherBuffer)
+	for _, ev := range topic.history {
+		ch <- ev
+	}
+	topic.watchers[ch] = struct{}{}
+
+	unsubscribe = func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := topic.watchers[ch]; ok {
+			delete(topic.watchers, ch)
+			close(ch)
+		}
+	}
+	return ch, unsubscribe, true
+}
+
+// cancel stops every extraction running on upload id. It returns false if no extraction owned by
+// email is running on the upload.
+func (h *extractionHub) cancel(id, email string) bool {
+	h.mu.Lock()
+	topic := h.topics[id]
+	if topic == nil || !strings.EqualFold(topic.email, email) {
+		h.mu.Unlock()
+		return false
+	}
+	cancels := make([]func(), 0, len(topic.cancels))
+	for _, cancel := range topic.cancels {
+		cancels = append(cancels, cancel)
+	}
+	h.mu.Unlock()
+
+	for _, cancel := range cancels {
+		cancel()
+	}
+	return true
+}
+
+// handleCancelExtraction stops the extraction running on a kept upload, e.g. after the user picked
+// the wrong image, so it doesn't keep spending model quota. The extraction's own stream (and its
+// watchers) get a cancelled event and end.
+func (s *Server) handleCancelExtraction(w http.ResponseWriter, r *http.Request) {
+	email := r.Header.Get("X-User-Email")
+	if email == "" {
+		http.Error(w, "X-User-Email header is required", http.StatusBadRequest)
+		return
+	}
+
+	uploadID := r.PathValue("uploadId")
+	if !s.hub.cancel(uploadID, email) {
+		http.Error(w, "No extraction in progress for this upload", http.StatusNotFound)
+		return
+	}
+	log.Printf("[EXTRACT] Cancelled extraction of upload %s | Email: %s", uploadID, redact.Email(email))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleWatchExtraction streams the events of an extraction running on a kept upload (started with
+// keepUpload=true, or a re-extraction) to an observer, e.g. another screen in a kiosk. Observers get
+// the same SSE events as the uploading client, from the start, until the extraction ends.
+// Email comes from the X-User-Email header or the email query parameter (EventSource can't set headers).
+func (s *Server) handleWatchExtraction(w http.ResponseWriter, r *http.Request) {
+	email := r.Header.Get("X-User-Email")
+	if email == "" {
+		email = r.URL.Query().Get("email")
+	}
+	if email == "" {
+		http.Error(w, "X-User-Email header or email query parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	events, unsubscribe, ok := s.hub.subscribe(r.PathValue("uploadId"), email)
+	if !ok {
+		http.Error(w, "No extraction in progress for this upload", http.StatusNotFound)
+		return
+	}
+	defer unsubscribe()
+
+	sse, ok := newSSEWriter(w)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	defer sse.Close()
+	sse.StartHeartbeat(r.Context())
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			sse.Send(ev.event, ev.data)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+)
+
+// WSEvent is a JSON frame sent to WebSocket chat clients.
+// Type mirrors the SSE event names (delta, query, error, response, done).
+type WSEvent struct {
+	Type string `json:"type"`
+	Data any    `json:"data,omitempty"`
+}
+
+// handleChatWS is a WebSocket alternative to handleChat for clients behind proxies that buffer SSE.
+// The client sends ChatRequest frames and receives WSEvent frames for each chat.
+// Email comes from the X-User-Email header or the email query parameter (browsers can't set WS headers).
+func (s *Server) handleChatWS(w http.ResponseWriter, r *http.Request) {
+	email := r.Header.Get("X-User-Email")
+	if email == "" {
+		email = r.URL.Query().Get("email")
+	}
+	if email == "" {
+		http.Error(w, "X-User-Email header or email query parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	if !s.requireCopilot(w) {
+		return
+	}
+
+	conn, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		log.Printf("[CHAT-WS] Failed to accept WebSocket: %v", err)
+		return
+	}
+	defer conn.CloseNow()
+
+	// Cancel any in-flight chat when the client disconnects
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	// Read requests in the background so disconnects are noticed while a chat is running
+	requests := make(chan ChatRequest)
+	go func() {
+		defer cancel()
+		defer close(requests)
+		for {
+			var req ChatRequest
+			if err := wsjson.Read(ctx, conn, &req); err != nil {
+				if websocket.CloseStatus(err) == -1 && !errors.Is(err, context.Canceled) {
+					log.Printf("[CHAT-WS] Read failed: %v", err)
+				}
+				return
+			}
+			select {
+			case requests <- req:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	send := func(eventType string, data any) {
+		if err := wsjson.Write(ctx, conn, WSEvent{Type: eventType, Data: data}); err != nil {
+			cancel()
+		}
+	}
+
+	for req := range requests {
+		if req.Message == "" {
+			send("error", "Message is required")
+			continue
+		}
+
+		// Get model (default to server default if not provided)
+		model := req.Model
+		if model == "" {
+			model = s.getDefaultModel()
+		}
+
+		// Adapt the SSE-style progress callback to WebSocket frames
+		callback := func(eventType, data string) {
+			send(eventType, data)
+		}
+
+		s.recordUsage(email, model)
+		response, err := s.chatHandler.Chat(ctx, req.Message, email, model, chatLocale(req, r), callback)
+		s.recordChat(model, err)
+		if err != nil {
+			if response != nil {
+				send("response", response)
+			}
+			send("error", newErrorEvent(err))
+			continue
+		}
+
+		send("response", response)
+		send("done", nil)
+	}
+
+	conn.Close(websocket.StatusNormalClosure, "")
+}
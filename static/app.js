@@ -6,6 +6,8 @@
     // State
     let userEmail = localStorage.getItem('flightlog_email') || '';
     let extractedFlight = null;
+    let extractedUploadId = null; // Kept upload of the extracted image, stored with the flight on save
+    let extractionWarnings = []; // Non-blocking issues reported during extraction (e.g. unknown airport codes)
     let currentImageFile = null;
     let selectedModel = localStorage.getItem('flightlog_model') || '';
     let availableModels = [];
@@ -64,6 +66,7 @@
         if (userEmail) {
             showApp();
             loadFlights();
+            loadSuggestions();
         } else {
             showEmailScreen();
         }
@@ -218,6 +221,7 @@
             localStorage.setItem('flightlog_email', email);
             showApp();
             loadFlights();
+            loadSuggestions();
         }
     }
 
@@ -259,6 +263,21 @@
         updateFlightCount(0);
     }
 
+    // Outline the flight cards and table rows the chat answer matched, scrolling to the first one
+    function highlightFlights(ids) {
+        document.querySelectorAll('.flight-highlight').forEach(el => el.classList.remove('flight-highlight'));
+        if (!ids || ids.length === 0) return;
+
+        let first = null;
+        ids.forEach(id => {
+            document.querySelectorAll(`[data-id="${CSS.escape(id)}"]`).forEach(el => {
+                el.classList.add('flight-highlight');
+                if (!first) first = el;
+            });
+        });
+        if (first) first.scrollIntoView({ behavior: 'smooth', block: 'center' });
+    }
+
     // Clear query results (used on sign out to prevent cross-user data leakage)
     function clearQueryResults() {
         const queryResult = document.getElementById('queryResult');
@@ -297,6 +316,7 @@
         extractionStatus.classList.remove('active');
         extractedData.classList.remove('active');
         extractedFlight = null;
+        extractionWarnings = [];
         currentImageFile = null;
         fileInput.value = '';
         
@@ -385,6 +405,9 @@
         const formData = new FormData();
         formData.append('image', file);
         formData.append('model', selectedModel);
+        // Keep the upload so the image can be stored with the flight on save
+        formData.append('keepUpload', 'true');
+        extractedUploadId = null;
 
         try {
             const response = await fetch('/api/extract', {
@@ -435,7 +458,13 @@
         if (eventType === 'step') {
             try {
                 const stepData = JSON.parse(data);
-                updateProgressStep(stepData.step, stepData.status, stepData.detail);
+                // Show how long extraction has been running, so slower models are explained
+                let detail = stepData.detail;
+                if (stepData.elapsedMs > 0) {
+                    const elapsed = `${(stepData.elapsedMs / 1000).toFixed(1)}s`;
+                    detail = detail ? `${detail} · ${elapsed}` : elapsed;
+                }
+                updateProgressStep(stepData.step, stepData.status, detail);
             } catch (e) {
                 console.error('Failed to parse step data:', e);
             }
@@ -443,7 +472,69 @@
         }
 
         if (eventType === 'error') {
-            showExtractionError(data);
+            showExtractionError(formatErrorEvent(data));
+            return;
+        }
+
+        if (eventType === 'upload') {
+            try {
+                extractedUploadId = JSON.parse(data).uploadId;
+            } catch (e) {
+                console.error('Failed to parse upload data:', e);
+            }
+            return;
+        }
+
+        if (eventType === 'fallback') {
+            try {
+                const fallback = JSON.parse(data);
+                updateProgressStep(2, 'active', `${fallback.from} failed, retrying with ${fallback.to}`);
+            } catch (e) {
+                console.error('Failed to parse fallback data:', e);
+            }
+            return;
+        }
+
+        if (eventType === 'substituted') {
+            try {
+                const substituted = JSON.parse(data);
+                updateProgressStep(2, 'active', `${substituted.requested} can't read images, using ${substituted.model}`);
+            } catch (e) {
+                console.error('Failed to parse substituted data:', e);
+            }
+            return;
+        }
+
+        if (eventType === 'warning') {
+            try {
+                const warning = JSON.parse(data);
+                // Schedule mismatches carry their own message; other warnings are unknown airport codes
+                extractionWarnings.push(warning.message || `Unrecognized airport code ${warning.code}`);
+            } catch (e) {
+                console.error('Failed to parse warning data:', e);
+            }
+            return;
+        }
+
+        if (eventType === 'timing') {
+            try {
+                const timing = JSON.parse(data);
+                const secs = ms => `${(ms / 1000).toFixed(1)}s`;
+                updateProgressStep(4, 'active',
+                    `Upload ${secs(timing.uploadMs)} · Analysis ${secs(timing.analysisMs)} · Capture ${secs(timing.captureMs)}`);
+            } catch (e) {
+                console.error('Failed to parse timing data:', e);
+            }
+            return;
+        }
+
+        if (eventType === 'field') {
+            try {
+                const fieldData = JSON.parse(data);
+                updateProgressStep(3, 'active', `Found ${fieldData.field}: ${fieldData.value}`);
+            } catch (e) {
+                console.error('Failed to parse field data:', e);
+            }
             return;
         }
 
@@ -518,6 +609,20 @@
         continueBtn.addEventListener('click', handleClick);
     }
 
+    // Turn an error event payload into a message, appending the server's hint when present.
+    // Plain-text errors (e.g. from session events) are returned unchanged.
+    function formatErrorEvent(data) {
+        try {
+            const parsed = JSON.parse(data);
+            if (parsed.kind) {
+                return parsed.hint ? parsed.error + ' — ' + parsed.hint : parsed.error;
+            }
+        } catch {
+            // Not JSON
+        }
+        return data;
+    }
+
     function showExtractionError(message) {
         // Mark all incomplete steps as failed
         const steps = extractionStatus.querySelectorAll('.progress-step:not(.completed)');
@@ -546,15 +651,27 @@
         extractedData.classList.add('active');
 
         document.getElementById('extractedFlight').textContent = flight.flightNumber || '-';
-        document.getElementById('extractedRoute').textContent = 
+        const routeEl = document.getElementById('extractedRoute');
+        routeEl.textContent = 
             (flight.fromAirport && flight.toAirport) 
                 ? `${flight.fromAirport} → ${flight.toAirport}` 
                 : '-';
+        // Flag unrecognized airport codes without blocking the save
+        if (extractionWarnings.length > 0) {
+            routeEl.textContent += ' ⚠';
+            routeEl.title = extractionWarnings.join('\n');
+        } else {
+            routeEl.title = '';
+        }
         document.getElementById('extractedDate').textContent = formatDate(flight.departureDate) || '-';
         document.getElementById('extractedTime').textContent = flight.departureTime || '-';
         document.getElementById('extractedSeat').textContent = flight.seat || '-';
         document.getElementById('extractedGate').textContent = flight.gate || '-';
         document.getElementById('extractedPassenger').textContent = flight.passenger || '-';
+        document.getElementById('extractedCabin').textContent = flight.cabinClass || '-';
+        document.getElementById('extractedBoardingGroup').textContent = flight.boardingGroup || '-';
+        document.getElementById('extractedConfirmationCode').textContent = flight.confirmationCode || '-';
+        document.getElementById('extractedFrequentFlyerNumber').textContent = flight.frequentFlyerNumber || '-';
     }
 
     // API: Save Flight
@@ -565,17 +682,34 @@
         saveFlight.textContent = 'Saving...';
 
         try {
-            const response = await fetch('/api/flights', {
+            const body = JSON.stringify({
+                ...extractedFlight,
+                email: userEmail
+            });
+            const uploadParam = extractedUploadId ? `uploadId=${encodeURIComponent(extractedUploadId)}` : '';
+            let response = await fetch(`/api/flights${uploadParam ? '?' + uploadParam : ''}`, {
                 method: 'POST',
                 headers: {
                     'Content-Type': 'application/json'
                 },
-                body: JSON.stringify({
-                    ...extractedFlight,
-                    email: userEmail
-                })
+                body
             });
 
+            // Duplicate flight: let the user decide whether to save anyway
+            if (response.status === 409) {
+                if (!confirm('This flight is already in your log. Save it again anyway?')) {
+                    closeModalHandler();
+                    return;
+                }
+                response = await fetch(`/api/flights?force=true${uploadParam ? '&' + uploadParam : ''}`, {
+                    method: 'POST',
+                    headers: {
+                        'Content-Type': 'application/json'
+                    },
+                    body
+                });
+            }
+
             if (!response.ok) {
                 throw new Error('Failed to save flight');
             }
@@ -608,6 +742,36 @@
         }
     }
 
+    // API: Load chat suggestions tailored to the user's flights (keeps the static examples on failure)
+    async function loadSuggestions() {
+        const container = document.querySelector('.query-examples');
+        if (!container) return;
+
+        try {
+            const response = await fetch('/api/chat/suggestions', {
+                method: 'POST',
+                headers: { 'X-User-Email': userEmail }
+            });
+            if (!response.ok) {
+                throw new Error('Failed to load suggestions');
+            }
+
+            const suggestions = await response.json();
+            if (!suggestions || suggestions.length === 0) return;
+
+            container.innerHTML = '';
+            suggestions.forEach(question => {
+                const chip = document.createElement('span');
+                chip.className = 'query-example';
+                chip.dataset.query = question;
+                chip.textContent = question;
+                container.appendChild(chip);
+            });
+        } catch (error) {
+            console.error('Suggestions error:', error);
+        }
+    }
+
     // API: Delete Flight
     async function deleteFlight(id) {
         if (!confirm('Are you sure you want to delete this flight?')) return;
@@ -620,10 +784,22 @@
             if (!response.ok) {
                 throw new Error('Failed to delete flight');
             }
+            const deleted = await response.json();
 
             loadFlights();
             // Refresh All Flights if section is visible
             await refreshAllFlightsIfVisible();
+
+            // Offer undo by re-saving the deleted flight (same ID and createdAt)
+            if (confirm(`Deleted ${deleted.flightNumber || 'flight'}. Undo?`)) {
+                await fetch('/api/flights?force=true', {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/json' },
+                    body: JSON.stringify(deleted)
+                });
+                loadFlights();
+                await refreshAllFlightsIfVisible();
+            }
         } catch (error) {
             console.error('Delete error:', error);
             alert('Failed to delete flight: ' + error.message);
@@ -652,6 +828,11 @@
             if (!response.ok) {
                 throw new Error('Failed to load sample data');
             }
+            const summary = await response.json();
+            if (summary.created < summary.requested) {
+                const skipped = summary.skipped ? ` (${summary.skipped} already in your log)` : '';
+                alert(`Loaded ${summary.created} of ${summary.requested} sample flights${skipped}.`);
+            }
 
             loadFlights();
             // Refresh All Flights if section is visible
@@ -724,6 +905,7 @@
                                 ${flight.airline ? `<span class="flight-airline">${flight.airline}</span>` : ''}
                                 ${flight.departureTime ? `<span class="flight-time">✈ ${flight.departureTime}</span>` : ''}
                                 ${flight.passenger ? `<span class="flight-passenger">${flight.passenger}</span>` : ''}
+                                ${flight.imageUrl ? `<a class="flight-image-link" href="/api/flights/${flight.id}/image?email=${encodeURIComponent(userEmail)}" target="_blank" rel="noopener">🖼 Boarding pass</a>` : ''}
                             </div>
                         </div>
                         <div class="flight-actions">
@@ -772,10 +954,13 @@
     const queryResult = document.getElementById('queryResult');
     const queryResultContent = document.getElementById('queryResultContent');
     const queryResultClose = document.getElementById('queryResultClose');
+    const queryResultMeta = document.getElementById('queryResultMeta');
+    const queryClarify = document.getElementById('queryClarify');
     const queryGeneratedSQL = document.getElementById('queryGeneratedSQL');
     const querySQLCode = document.getElementById('querySQLCode');
     const queryLoading = document.getElementById('queryLoading');
-    const queryExamples = document.querySelectorAll('.query-example');
+    const queryLoadingText = document.getElementById('queryLoadingText');
+    const queryExamples = document.querySelector('.query-examples');
     const queryHeaderToggle = document.getElementById('queryHeaderToggle');
 
     // Toggle collapsible AI section
@@ -793,13 +978,15 @@
         }
     }
 
-    // Example query clicks
-    queryExamples.forEach(example => {
-        example.addEventListener('click', () => {
+    // Example query clicks (delegated, since suggestions replace the examples after login)
+    if (queryExamples) {
+        queryExamples.addEventListener('click', (e) => {
+            const example = e.target.closest('.query-example');
+            if (!example) return;
             queryInput.value = example.dataset.query;
             submitQuery();
         });
-    });
+    }
 
     // Query submit
     if (querySubmit) {
@@ -824,6 +1011,7 @@
         queryInput.disabled = true;
         querySubmit.disabled = true;
         queryResult.classList.add('hidden');
+        queryLoadingText.textContent = 'Searching...';
         queryLoading.classList.remove('hidden');
 
         try {
@@ -845,6 +1033,13 @@
             const decoder = new TextDecoder();
             let aiResponse = '';
             let generatedQuery = '';
+            let queryError = '';
+            let chatError = '';
+            let chatWarning = '';
+            let matchedIds = [];
+            let meta = null;
+            let clarify = null;
+            let confirm = null;
 
             while (true) {
                 const { done, value } = await reader.read();
@@ -865,9 +1060,29 @@
                         // Check for final response
                         try {
                             const parsed = JSON.parse(data);
-                            if (parsed.message) {
-                                aiResponse = parsed.message;
+                            if (parsed.message || parsed.query) {
+                                aiResponse = parsed.message || aiResponse;
                                 generatedQuery = parsed.query || generatedQuery;
+                                queryError = parsed.error || queryError;
+                                matchedIds = parsed.matchedIds || matchedIds;
+                                meta = parsed.meta || meta;
+                                confirm = parsed.confirm || confirm;
+                                if (parsed.applied) {
+                                    // The user confirmed a change the chat proposed
+                                    loadFlights();
+                                }
+                            } else if (Array.isArray(parsed.options)) {
+                                // The chat paused to ask which airport was meant
+                                clarify = parsed;
+                            } else if (parsed.warning) {
+                                chatWarning = parsed.warning;
+                            } else if (typeof parsed.rowCount === 'number') {
+                                // Query results arrived; the model is still writing its answer
+                                const noun = parsed.rowCount === 1 ? 'result' : 'results';
+                                queryLoadingText.textContent = `Found ${parsed.rowCount} ${noun}, summarizing...`;
+                            } else if (parsed.kind) {
+                                // Classified failure from the server (model unavailable, query failed, timeout)
+                                chatError = formatErrorEvent(data);
                             }
                         } catch {
                             // Not JSON, might be a delta
@@ -878,8 +1093,22 @@
 
             // Show result
             queryLoading.classList.add('hidden');
-            queryResultContent.textContent = aiResponse || 'No response received';
+            queryResultContent.textContent = aiResponse || chatError || 'No response received';
+            if (queryError) {
+                queryResultContent.textContent += '\n\nTried: ' + generatedQuery + ' — failed because: ' + queryError;
+            }
+            if (chatWarning) {
+                queryResultContent.textContent += '\n\n(' + chatWarning + ')';
+            }
+            renderClarify(clarify || confirmOptions(confirm));
             
+            if (meta) {
+                queryResultMeta.textContent = formatChatMeta(meta);
+                queryResultMeta.classList.remove('hidden');
+            } else {
+                queryResultMeta.classList.add('hidden');
+            }
+
             if (generatedQuery) {
                 querySQLCode.textContent = generatedQuery;
                 queryGeneratedSQL.classList.remove('hidden');
@@ -888,11 +1117,13 @@
             }
             
             queryResult.classList.remove('hidden');
+            highlightFlights(matchedIds);
 
         } catch (error) {
             console.error('Query error:', error);
             queryLoading.classList.add('hidden');
             queryResultContent.textContent = 'Sorry, I encountered an error processing your request. Please try again.';
+            renderClarify(null);
             queryGeneratedSQL.classList.add('hidden');
             queryResult.classList.remove('hidden');
         } finally {
@@ -903,6 +1134,47 @@
         }
     }
 
+    // Show the options of a clarifying question; picking one sends it as the next message
+    function renderClarify(clarify) {
+        queryClarify.innerHTML = '';
+        if (!clarify) {
+            queryClarify.classList.add('hidden');
+            return;
+        }
+        for (const option of clarify.options) {
+            const btn = document.createElement('button');
+            btn.type = 'button';
+            btn.className = 'query-example';
+            btn.textContent = option.label;
+            btn.addEventListener('click', () => {
+                queryInput.value = option.value;
+                submitQuery();
+            });
+            queryClarify.appendChild(btn);
+        }
+        queryClarify.classList.remove('hidden');
+    }
+
+    // Offer yes/no buttons for a change the chat wants the user to confirm
+    function confirmOptions(confirm) {
+        if (!confirm) {
+            return null;
+        }
+        const verb = confirm.action === 'delete' ? 'Yes, delete it' : 'Yes, change it';
+        return { options: [{ label: verb, value: 'yes' }, { label: 'No', value: 'no' }] };
+    }
+
+    // Summarize how the answer was produced, e.g. "Answered by gpt-4.1 · 1 query · 240 tokens"
+    function formatChatMeta(meta) {
+        const parts = [`Answered by ${meta.model}`];
+        parts.push(`${meta.queryCount} ${meta.queryCount === 1 ? 'query' : 'queries'}`);
+        const tokens = (meta.inputTokens || 0) + (meta.outputTokens || 0);
+        if (tokens > 0) {
+            parts.push(`${tokens} tokens`);
+        }
+        return parts.join(' · ');
+    }
+
     // Show query section when flights are loaded
     function showQuerySection() {
         if (querySection) {
@@ -1033,7 +1305,7 @@
         }
 
         allFlightsTable.innerHTML = flights.map(flight => `
-            <tr>
+            <tr data-id="${flight.id}">
                 <td>${flight.departureDate || '-'}</td>
                 <td>${flight.flightNumber || '-'}</td>
                 <td>${flight.fromAirport || '-'} → ${flight.toAirport || '-'}</td>
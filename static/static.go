@@ -0,0 +1,10 @@
+// Package static embeds the web UI and the sample boarding pass images, so the server binary
+// serves them without the static directory on disk
+package static
+
+import "embed"
+
+// FS holds index.html, app.js, and the samples directory
+//
+//go:embed index.html app.js samples
+var FS embed.FS
@@ -0,0 +1,84 @@
+// Package tracing sets up OpenTelemetry tracing. Spans are exported over OTLP/HTTP when an
+// endpoint is configured with the standard OTEL_EXPORTER_OTLP_* variables; otherwise the
+// global no-op tracer stays in place and instrumented code pays almost nothing.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// defaultServiceName is reported when OTEL_SERVICE_NAME is not set
+const defaultServiceName = "flight-log-app"
+
+// Enabled reports whether spans should be exported: an OTLP endpoint is configured and
+// neither OTEL_SDK_DISABLED=true nor OTEL_TRACES_EXPORTER=none turns tracing off
+func Enabled() bool {
+	if os.Getenv("OTEL_SDK_DISABLED") == "true" || os.Getenv("OTEL_TRACES_EXPORTER") == "none" {
+		return false
+	}
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// Setup installs the global tracer provider and W3C trace context propagator when Enabled.
+// The returned shutdown flushes buffered spans and must be called before exit; it is a no-op
+// when tracing is disabled.
+func Setup(ctx context.Context) (shutdown func(context.Context) error, err error) {
+	noop := func(context.Context) error { return nil }
+	if !Enabled() {
+		return noop, nil
+	}
+	if p := os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL"); p != "" && p != "http/protobuf" {
+		return noop, errors.New("only the http/protobuf OTLP protocol is supported")
+	}
+
+	// The exporter reads the endpoint, headers, timeout, and TLS settings from OTEL_EXPORTER_OTLP_*
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return noop, err
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the default service name
+	res, err := resource.Merge(
+		resource.NewSchemaless(semconv.ServiceName(defaultServiceName)),
+		resource.Environment(),
+	)
+	if err != nil {
+		return noop, err
+	}
+
+	// The sampler honors OTEL_TRACES_SAMPLER and OTEL_TRACES_SAMPLER_ARG, defaulting to parent-based always-on
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+// End records err on the span, if any, and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, firstLine(err.Error()))
+	}
+	span.End()
+}
+
+// firstLine keeps span statuses short for errors that join several failures
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}